@@ -0,0 +1,50 @@
+package v1
+
+import (
+	"time"
+)
+
+const (
+	// MaxScopes specifies maximum number of scopes of API key
+	MaxScopes = 16
+	// MaxScopeLen specifies maximum length of API key scope
+	MaxScopeLen = 256
+)
+
+// APIKey provides API key information, the secret is never returned,
+// except when the key is created or rotated
+type APIKey struct {
+	ID string `json:"id"`
+	// Prefix specifies the first part of the key, to identify it
+	Prefix string `json:"prefix"`
+	// Owner specifies the identity name the key is issued to
+	Owner string `json:"owner"`
+	Role  string `json:"role"`
+	// Scopes specifies URI paths allowed for the key,
+	// all paths allowed for the role are allowed if empty
+	Scopes     []string   `json:"scopes,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest specifies API key to create
+type CreateAPIKeyRequest struct {
+	Owner     string     `json:"owner"`
+	Role      string     `json:"role"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponse provides response for API key create or rotate request
+type APIKeyResponse struct {
+	APIKey *APIKey `json:"api_key"`
+	// Key specifies the secret, which is returned only once
+	Key string `json:"key,omitempty"`
+}
+
+// ListAPIKeysResponse provides response for API keys list request
+type ListAPIKeysResponse struct {
+	APIKeys []*APIKey `json:"api_keys"`
+}
@@ -29,6 +29,7 @@ type Authorization struct {
 	Email       string    `json:"email"`
 	UserName    string    `json:"user_name"`
 	Role        string    `json:"role"`
+	TokenID     string    `json:"token_id"`
 	TokenType   string    `json:"token_type"`
 	AccessToken string    `json:"access_token"`
 	ExpiresAt   time.Time `json:"expires_at"`
@@ -39,3 +40,53 @@ type AuthTokenRefreshResponse struct {
 	Authorization *Authorization `json:"authorization"`
 	Profile       *UserInfo      `json:"profile"`
 }
+
+// JSONWebKey provides the public key to verify tokens, RFC 7517
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	// N and E specify RSA modulus and exponent
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Curve, X and Y specify EC curve and point
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet provides response for JWKS request
+type JSONWebKeySet struct {
+	Keys []*JSONWebKey `json:"keys"`
+}
+
+// Revocation specifies revoked tokens
+type Revocation struct {
+	ID string `json:"id"`
+	// TokenID specifies ID of the revoked token
+	TokenID string `json:"token_id,omitempty"`
+	// DeviceID specifies the device, whose tokens issued before RevokedAt are revoked
+	DeviceID string `json:"device_id,omitempty"`
+	// User specifies email of the user, whose tokens issued before RevokedAt are revoked
+	User      string    `json:"user,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	RevokedAt time.Time `json:"revoked_at"`
+	// ExpiresAt specifies the time when the revoked tokens expire,
+	// and the revocation can be purged
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RevokeTokensRequest specifies tokens to revoke,
+// exactly one of TokenID, DeviceID or User must be provided
+type RevokeTokensRequest struct {
+	TokenID  string `json:"token_id"`
+	DeviceID string `json:"device_id"`
+	User     string `json:"user"`
+	Reason   string `json:"reason"`
+}
+
+// RevocationsResponse provides response for revocations list request
+type RevocationsResponse struct {
+	Revocations []*Revocation `json:"revocations"`
+}
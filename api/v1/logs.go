@@ -0,0 +1,35 @@
+package v1
+
+import "time"
+
+// MaxLogLevelTTL specifies maximum time the changed log level is kept
+const MaxLogLevelTTL = 24 * time.Hour
+
+// LogLevelNames specifies the names of the log levels
+var LogLevelNames = []string{"CRITICAL", "ERROR", "WARNING", "NOTICE", "INFO", "TRACE", "DEBUG"}
+
+// LogLevel provides the log level of the package
+type LogLevel struct {
+	Repo    string `json:"repo"`
+	Package string `json:"package"`
+	Level   string `json:"level"`
+	// RevertAt specifies the time when the level changed at runtime is reverted
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// LogLevelsResponse provides response for log levels request
+type LogLevelsResponse struct {
+	Levels []*LogLevel `json:"levels"`
+}
+
+// SetLogLevelRequest specifies the request to change the log level
+type SetLogLevelRequest struct {
+	// Repo specifies the repo of the package, or * for all repos
+	Repo string `json:"repo"`
+	// Package specifies the package, or * or empty for all packages of the repo
+	Package string `json:"package"`
+	// Level specifies the log level: CRITICAL|ERROR|WARNING|NOTICE|INFO|TRACE|DEBUG
+	Level string `json:"level"`
+	// TTL specifies how long the level is kept, for example 30m, by default 15m
+	TTL string `json:"ttl"`
+}
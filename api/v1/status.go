@@ -0,0 +1,60 @@
+package v1
+
+import "time"
+
+// ServerStatus provides response about current server
+type ServerStatus struct {
+	// Name is the name of the server: HTTP|HTTPS
+	Name string `json:"name"`
+	// NodeName is the human-readable name of the cluster member
+	NodeName string `json:"nodename"`
+	// HostName is operating system's host name
+	HostName string `json:"hostname"`
+	// Port is the listening port
+	Port string `json:"port"`
+	// StartedAt is the time when the server started
+	StartedAt time.Time `json:"started_at"`
+	// Uptime is the total time elapsed since the server started
+	Uptime time.Duration `json:"uptime"`
+	// Version is the app build version
+	Version string `json:"version"`
+	// NodeID is the node ID in the cluster
+	NodeID string `json:"node_id,omitempty"`
+	// LeaderID is the node ID of the cluster leader
+	LeaderID string `json:"leader_id,omitempty"`
+	// Peers is a list of all members associated with the cluster
+	Peers []*ClusterMember `json:"peers,omitempty"`
+	// Services is a list of the services of the server
+	Services []*ServiceStatus `json:"services"`
+}
+
+// ClusterMember provides info about a member of the cluster
+type ClusterMember struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name,omitempty"`
+	ListenPeerURLs []string `json:"listen_peer_urls,omitempty"`
+}
+
+// ServiceStatus provides status of the service
+type ServiceStatus struct {
+	Name    string `json:"name"`
+	IsReady bool   `json:"ready"`
+}
+
+// StatusResponse is response for /v1/status
+type StatusResponse struct {
+	Status *ServerStatus `json:"status"`
+}
+
+// HealthCheck provides result of the health check
+type HealthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// HealthResponse is response for liveness and readiness checks
+type HealthResponse struct {
+	OK     bool           `json:"ok"`
+	Checks []*HealthCheck `json:"checks,omitempty"`
+}
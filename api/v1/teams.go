@@ -57,9 +57,45 @@ type GetTeamMembershipsResponse struct {
 	Memberships []*TeamMemberInfo `json:"memberships"`
 }
 
-// ListTeamsResponse returns list of available teams
+// ListTeamsRequest specifies a page of teams to return
+type ListTeamsRequest struct {
+	// Limit specifies the maximum number of teams to return
+	Limit int `json:"limit"`
+	// Cursor specifies NextCursor from the previous page
+	Cursor string `json:"cursor"`
+	// Sort specifies the sort order: name|-name
+	Sort string `json:"sort"`
+}
+
+// ListTeamsResponse returns list of available teams,
+// the clients should use Items, Teams is kept for the existing clients
 type ListTeamsResponse struct {
+	// Teams specifies the names of the teams in Items.
+	//
+	// Deprecated: use Items, the field will be removed in v2 API.
 	Teams []string `json:"teams"`
+	// Items specifies the teams
+	Items []*Team `json:"items"`
+	// NextCursor is set if more teams are available
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// CreateTeamRequest specifies a request to create a team
+type CreateTeamRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// UpdateTeamRequest specifies a request to update a team
+type UpdateTeamRequest struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// TeamResponse returns a created or updated team
+type TeamResponse struct {
+	Team *Team `json:"team"`
 }
 
 // FindUserRequest specifies user search request
@@ -67,9 +103,71 @@ type FindUserRequest struct {
 	Name   string `json:"name"`
 	MinAge int    `json:"min_age"`
 	MaxAge int    `json:"max_age"`
+
+	// NamePrefix specifies case-insensitive prefix of the name
+	NamePrefix string `json:"name_prefix"`
+	// NameContains specifies case-insensitive substring of the name
+	NameContains string `json:"name_contains"`
+	// EmailPrefix specifies case-insensitive prefix of the email
+	EmailPrefix string `json:"email_prefix"`
+	// EmailContains specifies case-insensitive substring of the email
+	EmailContains string `json:"email_contains"`
+	// TeamID specifies the team the users must be members of
+	TeamID string `json:"team_id"`
+	// LastLoginAfter specifies the start of last login window, inclusive
+	LastLoginAfter *time.Time `json:"last_login_after,omitempty"`
+	// LastLoginBefore specifies the end of last login window, exclusive
+	LastLoginBefore *time.Time `json:"last_login_before,omitempty"`
+
+	// Limit specifies the maximum number of users to return
+	Limit int `json:"limit"`
+	// Cursor specifies NextCursor from the previous page
+	Cursor string `json:"cursor"`
+	// Sort specifies the sort order: name|email|age, with "-" prefix for descending order
+	Sort string `json:"sort"`
 }
 
 // FindUserResponse returns list of users that match the search criteria
 type FindUserResponse struct {
 	Users []*User `json:"users"`
+	// NextCursor is set if more users are available
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// CreateUserRequest specifies a request to create a user
+type CreateUserRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Age   int    `json:"age"`
+}
+
+// UpdateUserRequest specifies a request to update a user
+type UpdateUserRequest struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Age   int    `json:"age"`
+}
+
+// UserResponse returns a created or updated user
+type UserResponse struct {
+	User *User `json:"user"`
+}
+
+// AddMembershipRequest specifies a request to add a user to a team
+type AddMembershipRequest struct {
+	TeamID string `json:"team_id"`
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// UpdateMembershipRequest specifies a request to update a user's role in a team
+type UpdateMembershipRequest struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+}
+
+// TeamMembershipResponse returns a created or updated membership
+type TeamMembershipResponse struct {
+	Membership *TeamMembership `json:"membership"`
 }
@@ -5,6 +5,10 @@ const (
 	// URIForTeams returns teams
 	//
 	// Verbs: GET
+	// Parameters:
+	//	limit		- optional, max number of teams to return
+	//	cursor		- optional, next_cursor from the previous page
+	//	sort		- optional, name|-name
 	URIForTeams = "/v1/teams"
 
 	// URIForTeamsMemberships returns teams membership for the caller
@@ -19,5 +23,172 @@ const (
 	//	name		- optional, name of the user to filter by
 	//  max_age		- optional, max age of the user to filter by
 	//  min_age		- optional, min age of the user to filter by
+	//	name_prefix		- optional, case-insensitive prefix of the name
+	//	name_contains	- optional, case-insensitive substring of the name
+	//	email_prefix	- optional, case-insensitive prefix of the email
+	//	email_contains	- optional, case-insensitive substring of the email
+	//	team_id			- optional, ID of the team the users are members of
+	//	last_login_after	- optional, RFC3339 time, inclusive
+	//	last_login_before	- optional, RFC3339 time, exclusive
+	//	limit		- optional, max number of users to return
+	//	cursor		- optional, next_cursor from the previous page
+	//	sort		- optional, name|email|age, with "-" prefix for descending order
 	URIForUsers = "/v1/users"
 )
+
+// Management API
+const (
+	// URIForTeam creates a team
+	//
+	// Verbs: POST CreateTeamRequest
+	URIForTeam = "/v1/team"
+
+	// URIForTeamByID updates or deletes a team
+	//
+	// Verbs:
+	//    PUT UpdateTeamRequest
+	//    DELETE
+	URIForTeamByID = URIForTeam + "/:team_id"
+
+	// URIForUser creates a user
+	//
+	// Verbs: POST CreateUserRequest
+	URIForUser = "/v1/user"
+
+	// URIForUserByID updates or deletes a user
+	//
+	// Verbs:
+	//    PUT UpdateUserRequest
+	//    DELETE
+	URIForUserByID = URIForUser + "/:user_id"
+
+	// URIForMembership adds a user to a team
+	//
+	// Verbs: POST AddMembershipRequest
+	URIForMembership = "/v1/membership"
+
+	// URIForMembershipByID updates or deletes a team membership
+	//
+	// Verbs:
+	//    PUT UpdateMembershipRequest
+	//    DELETE
+	URIForMembershipByID = URIForMembership + "/:membership_id"
+)
+
+// Auth API
+const (
+	// URIForAuthToken issues a token for the caller,
+	// authenticated with client certificate or API key
+	//
+	// Verbs: POST
+	// Headers:
+	//	X-Device-ID	- optional, ID of the device the token is bound to
+	// Response: AuthTokenRefreshResponse
+	URIForAuthToken = "/v1/auth/token"
+
+	// URIForAuthTokenRefresh issues a new token for the caller,
+	// authenticated with unexpired token
+	//
+	// Verbs: POST
+	// Response: AuthTokenRefreshResponse
+	URIForAuthTokenRefresh = URIForAuthToken + "/refresh"
+
+	// URIForAuthJWKS returns the public keys to verify issued tokens,
+	// symmetric keys are not published
+	//
+	// Verbs: GET
+	// Response: JSONWebKeySet
+	URIForAuthJWKS = "/v1/auth/jwks"
+
+	// URIForAuthLogout revokes the caller's token
+	//
+	// Verbs: POST
+	URIForAuthLogout = "/v1/auth/logout"
+
+	// URIForAuthRevoke revokes tokens by ID, device or user
+	//
+	// Verbs: POST RevokeTokensRequest
+	// Response: Revocation
+	URIForAuthRevoke = "/v1/auth/revoke"
+
+	// URIForAuthRevocations returns the revocations, which are not purged yet
+	//
+	// Verbs: GET
+	// Response: RevocationsResponse
+	URIForAuthRevocations = "/v1/auth/revocations"
+)
+
+// API keys management API
+const (
+	// URIForAPIKeys lists or creates API keys
+	//
+	// Verbs:
+	//	GET
+	//	POST CreateAPIKeyRequest
+	// Parameters:
+	//	owner		- optional, owner of the keys to list
+	// Response: ListAPIKeysResponse or APIKeyResponse
+	URIForAPIKeys = "/v1/apikeys"
+
+	// URIForAPIKeyByID returns or revokes API key
+	//
+	// Verbs:
+	//	GET
+	//	DELETE
+	// Response: APIKeyResponse
+	URIForAPIKeyByID = URIForAPIKeys + "/:key_id"
+
+	// URIForAPIKeyRotate issues a new secret for API key
+	//
+	// Verbs: POST
+	// Response: APIKeyResponse
+	URIForAPIKeyRotate = URIForAPIKeyByID + "/rotate"
+)
+
+// Status service API
+const (
+	// URIForStatus provides information about the server
+	//
+	// Verbs: GET
+	// Response: StatusResponse
+	URIForStatus = "/v1/status"
+
+	// URIForStatusServer provides information about the server and its services
+	//
+	// Verbs: GET
+	// Response: StatusResponse
+	URIForStatusServer = "/v1/status/server"
+
+	// URIForStatusLive returns 200 if the process is able to serve requests
+	//
+	// Verbs: GET
+	// Response: HealthResponse
+	URIForStatusLive = "/v1/status/live"
+
+	// URIForStatusReady returns 200 if the services are ready and the datahub is reachable,
+	// or 503 otherwise
+	//
+	// Verbs: GET
+	// Response: HealthResponse
+	URIForStatusReady = "/v1/status/ready"
+)
+
+// Metrics API
+const (
+	// URIForMetrics returns the metrics in Prometheus text exposition format
+	//
+	// Verbs: GET
+	URIForMetrics = "/metrics"
+)
+
+// Logs API
+const (
+	// URIForLogLevels lists or changes the log levels of the packages,
+	// the changed level is reverted after TTL
+	//
+	// Verbs:
+	//	GET
+	//	PUT SetLogLevelRequest
+	// Response: LogLevelsResponse
+	URIForLogLevels = "/v1/logs/levels"
+)
@@ -0,0 +1,229 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp/httperror"
+)
+
+const (
+	// MaxUserAge specifies maximum value for user's age
+	MaxUserAge = 150
+	// MaxDescriptionLen specifies maximum length for team's description
+	MaxDescriptionLen = 256
+	// MaxRoleLen specifies maximum length for membership role
+	MaxRoleLen = 64
+	// MaxListLimit specifies maximum number of items returned in a page
+	MaxListLimit = 1000
+)
+
+// Validator is implemented by requests that check their fields
+type Validator interface {
+	// Validate returns *httperror.ManyError with all invalid fields,
+	// or nil if the request is valid
+	Validate() error
+}
+
+// NewValidationError returns an empty error to collect invalid fields
+func NewValidationError() *httperror.ManyError {
+	return httperror.NewMany(http.StatusBadRequest, httperror.InvalidRequest, "invalid request")
+}
+
+// validator collects invalid fields, keyed by JSON name
+type validator struct {
+	errs *httperror.ManyError
+}
+
+func (v *validator) add(field, msgFormat string, vals ...interface{}) {
+	if v.errs == nil {
+		v.errs = NewValidationError()
+	}
+	if _, exists := v.errs.Errors[field]; !exists {
+		v.errs.Add(field, httperror.WithInvalidParam(msgFormat, vals...))
+	}
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.add(field, "%s is required", field)
+	}
+}
+
+func (v *validator) maxLen(field, value string, max int) {
+	if len(value) > max {
+		v.add(field, "%s must not exceed %d characters", field, max)
+	}
+}
+
+func (v *validator) between(field string, value, min, max int) {
+	if value < min || value > max {
+		v.add(field, "%s must be between %d and %d", field, min, max)
+	}
+}
+
+func (v *validator) email(field, value string) {
+	if value != "" && !strings.Contains(value, "@") {
+		v.add(field, "%s is not a valid email", field)
+	}
+}
+
+func (v *validator) err() error {
+	if v.errs == nil {
+		return nil
+	}
+	return v.errs
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *ListTeamsRequest) Validate() error {
+	v := new(validator)
+	v.between("limit", r.Limit, 0, MaxListLimit)
+	return v.err()
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *FindUserRequest) Validate() error {
+	v := new(validator)
+	v.maxLen("name", r.Name, MaxUserNameLen)
+	v.maxLen("name_prefix", r.NamePrefix, MaxUserNameLen)
+	v.maxLen("name_contains", r.NameContains, MaxUserNameLen)
+	v.maxLen("email_prefix", r.EmailPrefix, MaxEmailNameLen)
+	v.maxLen("email_contains", r.EmailContains, MaxEmailNameLen)
+	if r.LastLoginAfter != nil && r.LastLoginBefore != nil && !r.LastLoginAfter.Before(*r.LastLoginBefore) {
+		v.add("last_login_after", "last_login_after must be before last_login_before")
+	}
+	v.between("min_age", r.MinAge, 0, MaxUserAge)
+	v.between("max_age", r.MaxAge, 0, MaxUserAge)
+	if r.MinAge > 0 && r.MaxAge > 0 && r.MinAge > r.MaxAge {
+		v.add("min_age", "min_age must not be greater than max_age")
+	}
+	v.between("limit", r.Limit, 0, MaxListLimit)
+	return v.err()
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *CreateTeamRequest) Validate() error {
+	v := new(validator)
+	v.required("name", r.Name)
+	v.maxLen("name", r.Name, MaxTeamNameLen)
+	v.maxLen("description", r.Description, MaxDescriptionLen)
+	return v.err()
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *UpdateTeamRequest) Validate() error {
+	v := new(validator)
+	v.required("id", r.ID)
+	v.required("name", r.Name)
+	v.maxLen("name", r.Name, MaxTeamNameLen)
+	v.maxLen("description", r.Description, MaxDescriptionLen)
+	return v.err()
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *CreateUserRequest) Validate() error {
+	v := new(validator)
+	v.required("name", r.Name)
+	v.maxLen("name", r.Name, MaxUserNameLen)
+	v.required("email", r.Email)
+	v.maxLen("email", r.Email, MaxEmailNameLen)
+	v.email("email", r.Email)
+	v.between("age", r.Age, 0, MaxUserAge)
+	return v.err()
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *UpdateUserRequest) Validate() error {
+	v := new(validator)
+	v.required("id", r.ID)
+	v.required("name", r.Name)
+	v.maxLen("name", r.Name, MaxUserNameLen)
+	v.required("email", r.Email)
+	v.maxLen("email", r.Email, MaxEmailNameLen)
+	v.email("email", r.Email)
+	v.between("age", r.Age, 0, MaxUserAge)
+	return v.err()
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *AddMembershipRequest) Validate() error {
+	v := new(validator)
+	v.required("team_id", r.TeamID)
+	v.required("user_id", r.UserID)
+	v.required("role", r.Role)
+	v.maxLen("role", r.Role, MaxRoleLen)
+	return v.err()
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *UpdateMembershipRequest) Validate() error {
+	v := new(validator)
+	v.required("id", r.ID)
+	v.required("role", r.Role)
+	v.maxLen("role", r.Role, MaxRoleLen)
+	return v.err()
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *RevokeTokensRequest) Validate() error {
+	v := new(validator)
+	n := 0
+	for _, val := range []string{r.TokenID, r.DeviceID, r.User} {
+		if val != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		v.add("token_id", "exactly one of token_id, device_id or user is required")
+	}
+	v.maxLen("user", r.User, MaxEmailNameLen)
+	v.maxLen("reason", r.Reason, MaxDescriptionLen)
+	return v.err()
+}
+
+// Validate returns *httperror.ManyError with all invalid fields
+func (r *CreateAPIKeyRequest) Validate() error {
+	v := new(validator)
+	v.required("owner", r.Owner)
+	v.maxLen("owner", r.Owner, MaxEmailNameLen)
+	v.required("role", r.Role)
+	v.maxLen("role", r.Role, MaxRoleLen)
+	if len(r.Scopes) > MaxScopes {
+		v.add("scopes", "scopes must not exceed %d items", MaxScopes)
+	}
+	for _, scope := range r.Scopes {
+		if !strings.HasPrefix(scope, "/") || len(scope) > MaxScopeLen {
+			v.add("scopes", "scopes must be URI paths, not exceeding %d characters", MaxScopeLen)
+		}
+	}
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		v.add("expires_at", "expires_at must be in the future")
+	}
+	return v.err()
+}
+
+// Validate returns error if the request is not valid
+func (r *SetLogLevelRequest) Validate() error {
+	v := new(validator)
+	v.required("repo", r.Repo)
+
+	valid := false
+	for _, name := range LogLevelNames {
+		if r.Level == name {
+			valid = true
+		}
+	}
+	if !valid {
+		v.add("level", "level must be one of %s", strings.Join(LogLevelNames, "|"))
+	}
+
+	if r.TTL != "" {
+		ttl, err := time.ParseDuration(r.TTL)
+		if err != nil || ttl <= 0 || ttl > MaxLogLevelTTL {
+			v.add("ttl", "ttl must be a duration up to %v", MaxLogLevelTTL)
+		}
+	}
+	return v.err()
+}
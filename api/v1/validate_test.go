@@ -0,0 +1,90 @@
+package v1_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func invalidFields(t *testing.T, err error) []string {
+	if err == nil {
+		return nil
+	}
+	many, ok := err.(*httperror.ManyError)
+	require.True(t, ok, "expected ManyError: %T", err)
+	assert.Equal(t, 400, many.HTTPStatus)
+	assert.Equal(t, httperror.InvalidRequest, many.Code)
+
+	var fields []string
+	for field, e := range many.Errors {
+		assert.Equal(t, httperror.InvalidParam, e.Code)
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func Test_FindUserRequest(t *testing.T) {
+	now := time.Now()
+	tcases := []struct {
+		req    v1.FindUserRequest
+		fields []string
+	}{
+		{req: v1.FindUserRequest{}},
+		{req: v1.FindUserRequest{Name: "denis", MinAge: 10, MaxAge: 10, Limit: 10}},
+		{req: v1.FindUserRequest{MinAge: 30, MaxAge: 20}, fields: []string{"min_age"}},
+		{req: v1.FindUserRequest{MinAge: -1, MaxAge: v1.MaxUserAge + 1}, fields: []string{"min_age", "max_age"}},
+		{
+			req:    v1.FindUserRequest{Name: strings.Repeat("n", v1.MaxUserNameLen+1), Limit: v1.MaxListLimit + 1},
+			fields: []string{"name", "limit"},
+		},
+		{
+			req: v1.FindUserRequest{
+				NamePrefix:      strings.Repeat("n", v1.MaxUserNameLen+1),
+				EmailContains:   strings.Repeat("e", v1.MaxEmailNameLen+1),
+				LastLoginAfter:  &now,
+				LastLoginBefore: &now,
+			},
+			fields: []string{"name_prefix", "email_contains", "last_login_after"},
+		},
+	}
+
+	for _, tc := range tcases {
+		assert.ElementsMatch(t, tc.fields, invalidFields(t, tc.req.Validate()), "%+v", tc.req)
+	}
+}
+
+func Test_ManagementRequests(t *testing.T) {
+	tcases := []struct {
+		req    v1.Validator
+		fields []string
+	}{
+		{req: &v1.ListTeamsRequest{Limit: -1}, fields: []string{"limit"}},
+		{req: &v1.CreateTeamRequest{Name: "devs"}},
+		{req: &v1.CreateTeamRequest{Description: strings.Repeat("d", v1.MaxDescriptionLen+1)}, fields: []string{"name", "description"}},
+		{req: &v1.UpdateTeamRequest{Name: strings.Repeat("n", v1.MaxTeamNameLen+1)}, fields: []string{"id", "name"}},
+		{req: &v1.CreateUserRequest{Name: "john", Email: "john@ekspand.com", Age: 21}},
+		{req: &v1.CreateUserRequest{Email: "john", Age: -1}, fields: []string{"name", "email", "age"}},
+		{req: &v1.UpdateUserRequest{ID: "a001", Name: "john", Email: strings.Repeat("e", v1.MaxEmailNameLen) + "@"}, fields: []string{"email"}},
+		{req: &v1.AddMembershipRequest{}, fields: []string{"team_id", "user_id", "role"}},
+		{req: &v1.UpdateMembershipRequest{ID: "m001", Role: "owner"}},
+		{req: &v1.UpdateMembershipRequest{Role: strings.Repeat("r", v1.MaxRoleLen+1)}, fields: []string{"id", "role"}},
+		{req: &v1.CreateAPIKeyRequest{Owner: "ci", Role: "dolly-client", Scopes: []string{"/v1/teams"}}},
+		{req: &v1.CreateAPIKeyRequest{Scopes: []string{"v1"}, ExpiresAt: &time.Time{}}, fields: []string{"owner", "role", "scopes", "expires_at"}},
+		{req: &v1.RevokeTokensRequest{DeviceID: "device1"}},
+		{req: &v1.RevokeTokensRequest{}, fields: []string{"token_id"}},
+		{req: &v1.RevokeTokensRequest{TokenID: "t1", User: "denis@ekspand.com"}, fields: []string{"token_id"}},
+		{req: &v1.RevokeTokensRequest{User: "denis@ekspand.com", Reason: strings.Repeat("r", v1.MaxDescriptionLen+1)}, fields: []string{"reason"}},
+		{req: &v1.SetLogLevelRequest{Repo: "*", Level: "DEBUG", TTL: "30m"}},
+		{req: &v1.SetLogLevelRequest{Level: "VERBOSE", TTL: "25h"}, fields: []string{"repo", "level", "ttl"}},
+		{req: &v1.SetLogLevelRequest{Repo: "github.com/go-phorce/dolly", Level: "INFO", TTL: "-1m"}, fields: []string{"ttl"}},
+	}
+
+	for _, tc := range tcases {
+		assert.ElementsMatch(t, tc.fields, invalidFields(t, tc.req.Validate()), "%T: %+v", tc.req, tc.req)
+	}
+}
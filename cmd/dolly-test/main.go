@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	gocrypto "crypto"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -14,9 +17,20 @@ import (
 	"github.com/go-phorce/dolly-test/config"
 	"github.com/go-phorce/dolly-test/datahub"
 	"github.com/go-phorce/dolly-test/datahub/inmemory"
+	"github.com/go-phorce/dolly-test/datahub/sqldb"
+	"github.com/go-phorce/dolly-test/pkg/audit"
+	"github.com/go-phorce/dolly-test/pkg/drain"
+	"github.com/go-phorce/dolly-test/pkg/metrics"
+	"github.com/go-phorce/dolly-test/pkg/reload"
 	"github.com/go-phorce/dolly-test/pkg/roles"
+	"github.com/go-phorce/dolly-test/service/apikeys"
+	"github.com/go-phorce/dolly-test/service/auth"
+	"github.com/go-phorce/dolly-test/service/logs"
+	"github.com/go-phorce/dolly-test/service/prometheus"
+	"github.com/go-phorce/dolly-test/service/status"
 	"github.com/go-phorce/dolly-test/service/teams"
 	"github.com/go-phorce/dolly-test/version"
+	dollymetrics "github.com/go-phorce/dolly/metrics"
 	"github.com/go-phorce/dolly/netutil"
 	"github.com/go-phorce/dolly/rest"
 	"github.com/go-phorce/dolly/rest/tlsconfig"
@@ -33,7 +47,12 @@ import (
 var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/cmd/dolly-test", "main")
 
 var serviceFactories = map[string]func(server rest.Server) interface{}{
-	teams.ServiceName: teams.Factory,
+	teams.ServiceName:      teams.Factory,
+	auth.ServiceName:       auth.Factory,
+	apikeys.ServiceName:    apikeys.Factory,
+	prometheus.ServiceName: prometheus.Factory,
+	status.ServiceName:     status.Factory,
+	logs.ServiceName:       logs.Factory,
 }
 
 // return codes
@@ -42,6 +61,13 @@ const (
 	rcSuccess = 0
 )
 
+// commands
+const (
+	cmdServe          = "serve"
+	cmdAuditVerify    = "audit verify"
+	cmdConfigValidate = "config validate"
+)
+
 func main() {
 	rc := rcSuccess
 
@@ -71,6 +97,12 @@ type appFlags struct {
 	httpsKeyFile      *string
 	httpsCAFile       *string
 	encryptionKeyFile *string
+
+	// command specifies the selected command
+	command        string
+	auditDir       *string
+	auditPublicKey *string
+	configHost     *string
 }
 
 // app is the application container
@@ -86,6 +118,8 @@ type app struct {
 	cfg             *config.Configuration
 	peerTLS         *tls.Config
 	peerTLSReloader *tlsconfig.KeypairReloader
+	// servers are the started HTTP servers, whose TLS and CORS are applied on reload
+	servers []*httpServer
 }
 
 func newContainer(args []string) *app {
@@ -151,8 +185,21 @@ func (a *app) loadConfig() error {
 	flags.httpsCAFile = app.Flag("https-trusted-ca-file", "Path to the server TLS trusted CA file.").String()
 	flags.encryptionKeyFile = app.Flag("encryption-key-file", "Path to the RSA key file used to encrypt sensitive data.").String()
 
+	app.Command(cmdServe, "Start the service").Default()
+	cmdAudit := app.Command("audit", "Audit log commands")
+	cmdVerify := cmdAudit.Command("verify", "Verify the hash chain and signed checkpoints of the audit log")
+	flags.auditDir = cmdVerify.Flag("dir", "Audit folder, by default the folder from the configuration").String()
+	flags.auditPublicKey = cmdVerify.Flag("key", "Location of PEM-encoded certificate or public key to verify checkpoints, by default the public part of the configured signing key").String()
+	cmdConfig := app.Command("config", "Configuration commands")
+	cmdValidate := cmdConfig.Command("validate", "Validate the configuration file and print the effective configuration for the host")
+	flags.configHost = cmdValidate.Flag("host", "Host name to select overrides, by default the host name of this machine").String()
+
 	// Parse arguments
-	kp.MustParse(app.Parse(a.args))
+	flags.command = kp.MustParse(app.Parse(a.args))
+	if flags.command == cmdConfigValidate {
+		// the configuration is loaded by the command for the specified host
+		return nil
+	}
 
 	cfg, absCfgFile, err := config.LoadConfig(*flags.cfgFile)
 	if err != nil {
@@ -160,7 +207,18 @@ func (a *app) loadConfig() error {
 	}
 	logger.Infof("api=loadConfig, status=loaded, folder=%q", absCfgFile)
 	a.cfg = cfg
+	a.overrideFromFlags(cfg)
+
+	a.container.Provide(func() (*config.Configuration, *appFlags) {
+		return a.cfg, a.flags
+	})
 
+	return nil
+}
+
+// overrideFromFlags applies the command line flags to the configuration
+func (a *app) overrideFromFlags(cfg *config.Configuration) {
+	flags := a.flags
 	if *flags.hsmCfgFile != "" {
 		cfg.CryptoProv.Default = *flags.hsmCfgFile
 	}
@@ -188,11 +246,6 @@ func (a *app) loadConfig() error {
 	if *flags.apikeyRolesFile != "" {
 		cfg.Authz.APIKeyMapper = *flags.apikeyRolesFile
 	}
-	a.container.Provide(func() (*config.Configuration, *appFlags) {
-		return a.cfg, a.flags
-	})
-
-	return nil
 }
 
 func (a *app) initLogs() error {
@@ -214,18 +267,7 @@ func (a *app) initLogs() error {
 		xlog.SetFormatter(formatter)
 	}
 
-	// Set log levels for each repo
-	if cfg.LogLevels != nil {
-		for _, ll := range cfg.LogLevels {
-			l, _ := xlog.ParseLevel(ll.Level)
-			if ll.Repo == "*" {
-				xlog.SetGlobalLogLevel(l)
-			} else {
-				xlog.SetPackageLogLevel(ll.Repo, ll.Package, l)
-			}
-			logger.Debugf("api=start, logger=%q, level=%v", ll.Repo, l)
-		}
-	}
+	setLogLevels(cfg.LogLevels)
 
 	logger.Infof("api=initLogs, status=service_starting, version='%v', runtime='%v', args=%v, config=%q",
 		version.Current(), runtime.Version(), os.Args, *a.flags.cfgFile)
@@ -233,6 +275,46 @@ func (a *app) initLogs() error {
 	return nil
 }
 
+// setLogLevels sets log levels for each repo
+func setLogLevels(levels []config.RepoLogLevel) {
+	for _, ll := range levels {
+		l, _ := xlog.ParseLevel(ll.Level)
+		if ll.Repo == "*" {
+			xlog.SetGlobalLogLevel(l)
+		} else {
+			xlog.SetPackageLogLevel(ll.Repo, ll.Package, l)
+		}
+		logger.Debugf("api=setLogLevels, logger=%q, level=%v", ll.Repo, l)
+	}
+}
+
+func (a *app) initMetrics() error {
+	cfg := a.cfg
+
+	var tags []dollymetrics.Tag
+	if cfg.Datacenter != "" {
+		tags = append(tags, dollymetrics.Tag{Name: metrics.TagDatacenter, Value: cfg.Datacenter})
+	}
+	if cfg.Environment != "" {
+		tags = append(tags, dollymetrics.Tag{Name: metrics.TagEnvironment, Value: cfg.Environment})
+	}
+
+	pipeline, err := metrics.New(&metrics.Config{
+		ServiceName: cfg.ServiceName,
+		Provider:    cfg.Metrics.Provider,
+		Address:     cfg.Metrics.Address,
+		Tags:        tags,
+	})
+	if err != nil {
+		return errors.Annotate(err, "failed to initialize metrics")
+	}
+	a.OnClose(pipeline)
+
+	return errors.Trace(a.container.Provide(func() *metrics.Pipeline {
+		return pipeline
+	}))
+}
+
 func (a *app) start() error {
 	cryptoprov.Register("SoftHSM", cryptoprov.Crypto11Loader)
 
@@ -241,6 +323,13 @@ func (a *app) start() error {
 		return errors.Trace(err)
 	}
 
+	switch a.flags.command {
+	case cmdAuditVerify:
+		return a.verifyAudit()
+	case cmdConfigValidate:
+		return a.validateConfig()
+	}
+
 	err = a.initLogs()
 	if err != nil {
 		return errors.Trace(err)
@@ -258,6 +347,10 @@ func (a *app) start() error {
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
+		// HSM providers may hold sessions, which must be released on exit
+		if closer, ok := cry.Default().(io.Closer); ok {
+			a.OnClose(closer)
+		}
 
 		return cry, nil
 	})
@@ -265,45 +358,112 @@ func (a *app) start() error {
 		return errors.Trace(err)
 	}
 
-	err = a.container.Provide(func(cfg *config.Configuration) (datahub.Datahub, datahub.UsersManager, error) {
-		db, err := inmemory.NewUsersManager()
+	tracker := drain.New()
+	err = a.container.Provide(func() *drain.Tracker {
+		return tracker
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = a.container.Provide(func(cfg *config.Configuration, crypto *cryptoprov.Crypto) (rest.Auditor, error) {
+		if cfg.Audit.Directory == "" {
+			return nil, nil
+		}
+		auditor, err := audit.New(cfg.Audit.Directory, cfg.Audit.MaxAgeDays, cfg.Audit.MaxSizeMb)
 		if err != nil {
-			return nil, nil, errors.Trace(err)
+			return nil, errors.Annotate(err, "failed to initialize auditor")
 		}
+		a.OnClose(auditor)
 
-		return db, db, nil
+		if cfg.Audit.SigningKey != "" {
+			signer, err := audit.LoadSigner(cfg.Audit.SigningKey, crypto)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			auditor.StartCheckpoints(signer, cfg.Audit.GetCheckpointInterval().TimeDuration())
+		}
+		return auditor, nil
 	})
 	if err != nil {
 		return errors.Trace(err)
 	}
 
-	err = a.container.Provide(func(cfg *config.Configuration) (rest.Authz, error) {
-		var azp rest.Authz
-		if len(cfg.Authz.Allow) > 0 ||
-			len(cfg.Authz.AllowAny) > 0 ||
-			len(cfg.Authz.AllowAnyRole) > 0 {
-			azp, err = authz.New(&authz.Config{
-				Allow:        cfg.Authz.Allow,
-				AllowAny:     cfg.Authz.AllowAny,
-				AllowAnyRole: cfg.Authz.AllowAnyRole,
-				LogAllowed:   cfg.Authz.GetLogAllowed(),
-				LogDenied:    cfg.Authz.GetLogDenied(),
-			})
+	err = a.initMetrics()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = a.container.Provide(func(cfg *config.Configuration) (datahub.Datahub, datahub.UsersManager, error) {
+		var db datahub.Datahub
+		switch cfg.Datahub.Provider {
+		case "", "inmemory":
+			mem, err := inmemory.New()
 			if err != nil {
-				return nil, errors.Trace(err)
+				return nil, nil, errors.Trace(err)
 			}
-
-			p, err := roles.New(
-				cfg.Authz.JWTMapper,
-				cfg.Authz.APIKeyMapper,
-				cfg.Authz.CertMapper,
-			)
+			db = mem
+		case sqldb.ProviderName:
+			sqlDB, err := sqldb.New(sqldb.ProviderName, cfg.Datahub.DataSource)
 			if err != nil {
+				return nil, nil, errors.Trace(err)
+			}
+			a.OnClose(sqlDB)
+			db = sqlDB
+		default:
+			return nil, nil, errors.NotSupportedf("datahub provider %q", cfg.Datahub.Provider)
+		}
+
+		db = datahub.WithMetrics(db)
+		return db, db, nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = a.container.Provide(func(cfg *config.Configuration, crypto *cryptoprov.Crypto, db datahub.Datahub) (*roles.Provider, error) {
+		p, err := roles.New(
+			cfg.Authz.JWTMapper,
+			cfg.Authz.APIKeyMapper,
+			cfg.Authz.CertMapper,
+			crypto,
+		)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if len(cfg.Authz.IdentityProviders) > 0 {
+			if err = p.SetProviders(cfg.Authz.IdentityProviders); err != nil {
 				return nil, errors.Trace(err)
 			}
-			identity.SetGlobalIdentityMapper(p.IdentityMapper)
 		}
-		return azp, nil
+		if err = p.SetRequiredProviders(cfg.Authz.RequireProviders); err != nil {
+			return nil, errors.Trace(err)
+		}
+		p.SetRevocationChecker(db)
+		p.SetAPIKeyStore(db)
+		p.Watch(roles.DefaultWatchInterval)
+		a.OnClose(p)
+		return p, nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = a.container.Provide(func(cfg *config.Configuration, p *roles.Provider, auditor rest.Auditor) (*reload.Authz, error) {
+		azp, err := newAuthz(&cfg.Authz, p, auditor)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		// the allow lists are replaced on SIGHUP
+		return reload.NewAuthz(azp), nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = a.container.Provide(func(azp *reload.Authz) rest.Authz {
+		// in-flight requests are counted regardless of the authorization
+		return tracker.NewAuthz(azp)
 	})
 	if err != nil {
 		return errors.Trace(err)
@@ -325,24 +485,30 @@ func (a *app) start() error {
 			return errors.Trace(err)
 		}
 		servers = append(servers, httpServer)
+		a.servers = append(a.servers, httpServer)
 	}
 
 	// register for signals, and wait to be shutdown
-	signal.Notify(a.sigs, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGUSR2, syscall.SIGABRT)
+	signal.Notify(a.sigs, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGUSR2, syscall.SIGABRT, syscall.SIGHUP)
 	// Block until a signal is received.
 	sig := <-a.sigs
+	for sig == syscall.SIGHUP {
+		a.reload()
+		sig = <-a.sigs
+	}
 	logger.Warningf("api=start, status='shuting down from signal request', sig=%v", sig)
 
-	stopServers(servers)
-
-	// let to stop
-	time.Sleep(time.Second * 3)
+	a.shutdown(tracker, func() { stopServers(servers) })
 
 	// SIGUSR2 is triggered by the upstart pre-stop script, we don't want
 	// to actually exit the process in that case until upstart sends SIGTERM
 	if sig == syscall.SIGUSR2 {
+		upstartWait := a.cfg.Shutdown.GetUpstartWait().TimeDuration()
+		if upstartWait <= 0 {
+			upstartWait = drain.DefaultUpstartWait
+		}
 		select {
-		case <-time.After(time.Second * 15):
+		case <-time.After(upstartWait):
 			logger.Info("api=start, status='service shutdown from SIGUSR2 complete, waiting for SIGTERM to exit'")
 		case sig = <-a.sigs:
 			logger.Infof("api=start, status=exiting, reason=received_signal, sig=%v", sig)
@@ -352,69 +518,215 @@ func (a *app) start() error {
 	return nil
 }
 
-var tlsStrToClientAuthMap = map[string]tls.ClientAuthType{
-	"NoClientCert":               tls.NoClientCert,
-	"RequestClientCert":          tls.RequestClientCert,
-	"RequireAnyClientCert":       tls.RequireAnyClientCert,
-	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
-	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+// shutdown reports the servers as not ready, drains in-flight requests
+// within the configured deadline, and stops the servers
+func (a *app) shutdown(tracker *drain.Tracker, stop func()) {
+	cfg := a.cfg.Shutdown
+
+	tracker.Start()
+	if delay := cfg.GetReadinessDelay().TimeDuration(); delay > 0 {
+		logger.Infof("api=shutdown, status=not_ready, delay=%v", delay)
+		time.Sleep(delay)
+	}
+
+	timeout := cfg.GetTimeout().TimeDuration()
+	if timeout <= 0 {
+		timeout = drain.DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	started := time.Now()
+	remaining := tracker.Wait(ctx)
+	logger.Infof("api=shutdown, status=drained, timeout=%v, elapsed=%v, remaining=%d", timeout, time.Since(started), remaining)
+
+	stop()
+
+	if cut := tracker.InFlight(); cut > 0 {
+		logger.Warningf("api=shutdown, reason=timeout, cut_off=%d", cut)
+	} else {
+		logger.Info("api=shutdown, status=completed, cut_off=0")
+	}
+}
+
+// validateConfig validates the configuration file,
+// and prints the effective configuration with the host overrides applied,
+// the values interpolated from ${ENV:} and ${FILE:} are masked
+func (a *app) validateConfig() error {
+	f, err := config.DefaultFactory()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	cfg, absCfgFile, err := f.LoadConfigForHostName(*a.flags.cfgFile, *a.flags.configHost)
+	if err != nil {
+		if verr, ok := errors.Cause(err).(*config.ValidationError); ok {
+			for _, p := range verr.Problems {
+				fmt.Fprintln(os.Stderr, p)
+			}
+			return errors.Errorf("configuration %q has %d problem(s)", *a.flags.cfgFile, len(verr.Problems))
+		}
+		return errors.Annotatef(err, "failed to load configuration %q", *a.flags.cfgFile)
+	}
+	a.overrideFromFlags(cfg)
+	// the interpolated and overridden values may contain secrets
+	cfg.Mask(f.Interpolated())
+	cfg.Mask(f.Overridden())
+
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	fmt.Println(string(b))
+	fmt.Fprintf(os.Stderr, "configuration %q is valid\n", absCfgFile)
+	return nil
+}
+
+// verifyAudit verifies the audit log and prints the result
+func (a *app) verifyAudit() error {
+	cfg := a.cfg
+
+	dir := *a.flags.auditDir
+	if dir == "" {
+		dir = cfg.Audit.Directory
+	}
+	if dir == "" {
+		return errors.New("audit folder is not specified")
+	}
+
+	var pub gocrypto.PublicKey
+	var err error
+	if *a.flags.auditPublicKey != "" {
+		pub, err = audit.LoadPublicKey(*a.flags.auditPublicKey)
+	} else if cfg.Audit.SigningKey != "" {
+		var prov *cryptoprov.Crypto
+		prov, err = cryptoprov.Load(cfg.CryptoProv.Default, cfg.CryptoProv.Providers)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		var signer gocrypto.Signer
+		signer, err = audit.LoadSigner(cfg.Audit.SigningKey, prov)
+		if err == nil {
+			pub = signer.Public()
+		}
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	res, err := audit.Verify(dir, pub)
+	if res != nil {
+		fmt.Printf("files: %d\nrecords: %d\ncheckpoints: %d\nunsigned: %d\n",
+			res.Files, res.Records, res.Checkpoints, res.Unsigned)
+	}
+	if err != nil {
+		return errors.Annotate(err, "audit log verification failed")
+	}
+	fmt.Println("audit log is valid")
+	return nil
+}
+
+// clientAuthType returns the client authentication policy of the server
+func clientAuthType(cfg *config.TLSInfo) tls.ClientAuthType {
+	if ct, ok := config.ClientAuthTypes[cfg.GetClientCertAuth()]; ok {
+		return ct
+	}
+	return tls.VerifyClientCertIfGiven
+}
+
+// corsOptions returns CORS options of the server,
+// or nil if CORS is not enabled
+func corsOptions(cfg *config.CORS) *rest.CORSOptions {
+	if !cfg.GetEnabled() {
+		return nil
+	}
+	return &rest.CORSOptions{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		MaxAge:           cfg.MaxAge,
+		AllowCredentials: cfg.GetAllowCredentials(),
+		Debug:            cfg.GetDebug(),
+	}
+}
+
+// newAuthz returns the authorization provider for the allow lists,
+// or nil if the authorization is not configured
+func newAuthz(cfg *config.Authz, p *roles.Provider, auditor rest.Auditor) (rest.Authz, error) {
+	if len(cfg.Allow) == 0 &&
+		len(cfg.AllowAny) == 0 &&
+		len(cfg.AllowAnyRole) == 0 {
+		return nil, nil
+	}
+
+	azp, err := authz.New(&authz.Config{
+		Allow:        cfg.Allow,
+		AllowAny:     cfg.AllowAny,
+		AllowAnyRole: cfg.AllowAnyRole,
+		LogAllowed:   cfg.GetLogAllowed(),
+		LogDenied:    cfg.GetLogDenied(),
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	identity.SetGlobalIdentityMapper(p.IdentityMapper)
+
+	var az rest.Authz = azp
+	if auditor != nil {
+		az = audit.NewAuthz(az, auditor)
+	}
+	return metrics.NewAuthz(az), nil
 }
 
 func createHTTPServer(
 	ipaddr string,
 	cfgHTTPServer *config.HTTPServer,
 	container *dig.Container,
-) (rest.Server, error) {
+) (*httpServer, error) {
 	var err error
 	var server rest.Server
 	var tlsCfg *tls.Config
-	var tlsloader *tlsconfig.KeypairReloader
+	var serverTLS *reload.TLS
+
+	cors := reload.NewCORS(corsOptions(&cfgHTTPServer.CORS))
 
 	err = container.Invoke(func(
 		cfg *config.Configuration,
 		azp rest.Authz,
+		auditor rest.Auditor,
 	) error {
 		if cfgHTTPServer.ServerTLS.KeyFile != "" && cfgHTTPServer.ServerTLS.CertFile != "" {
-			clientauthType := tls.VerifyClientCertIfGiven
-			if ct, ok := tlsStrToClientAuthMap[cfgHTTPServer.ServerTLS.GetClientCertAuth()]; ok {
-				clientauthType = ct
-			}
-			tlsCfg, err = tlsconfig.NewServerTLSFromFiles(
+			serverTLS, err = reload.NewTLS(
 				cfgHTTPServer.ServerTLS.CertFile,
 				cfgHTTPServer.ServerTLS.KeyFile,
 				cfgHTTPServer.ServerTLS.TrustedCAFile,
-				clientauthType)
-			if err != nil {
-				return errors.Trace(err)
-			}
-
-			tlsloader, err = tlsconfig.NewKeypairReloader(
-				cfgHTTPServer.ServerTLS.CertFile,
-				cfgHTTPServer.ServerTLS.KeyFile,
-				5*time.Second)
+				clientAuthType(&cfgHTTPServer.ServerTLS))
 			if err != nil {
-				return errors.Annotatef(err, "api=createHTTPServer, reason=NewKeypairReloader, cert=%q, key=%q",
+				return errors.Annotatef(err, "api=createHTTPServer, reason=NewTLS, cert=%q, key=%q",
 					cfgHTTPServer.ServerTLS.CertFile, cfgHTTPServer.ServerTLS.KeyFile)
 			}
-			tlsCfg.GetCertificate = tlsloader.GetKeypairFunc()
+			tlsCfg = serverTLS.Config()
 		}
 
-		server, err = rest.New(version.Current().String(), ipaddr, cfgHTTPServer, tlsCfg, nil, azp, nil, nil)
+		server, err = rest.New(version.Current().String(), ipaddr, cfgHTTPServer, tlsCfg, auditor, cors.NewAuthz(azp), nil, nil)
 		if err != nil {
 			return errors.Annotatef(err, "api=createHTTPServer, reason=unable_initialize_service, name=%q", cfgHTTPServer.ServiceName)
 		}
 		return nil
 	})
 	if err != nil {
-		if tlsloader != nil {
-			tlsloader.Close()
+		if serverTLS != nil {
+			serverTLS.Close()
 		}
 		return nil, errors.Trace(err)
 	}
 
 	server.OnEvent(rest.ServerStoppedEvent, func(evt rest.ServerEvent) {
-		if tlsloader != nil {
-			tlsloader.Close()
+		if serverTLS != nil {
+			serverTLS.Close()
 		}
 	})
 
@@ -435,5 +747,10 @@ func createHTTPServer(
 			cfgHTTPServer.ServiceName)
 	}
 
-	return server, nil
+	return &httpServer{
+		Server: server,
+		cfg:    cfgHTTPServer,
+		tls:    serverTLS,
+		cors:   cors,
+	}, nil
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/go-phorce/dolly-test/config"
+	"github.com/go-phorce/dolly-test/pkg/reload"
+	"github.com/go-phorce/dolly-test/pkg/roles"
+	"github.com/go-phorce/dolly-test/service/logs"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/juju/errors"
+)
+
+// httpServer is the started HTTP server,
+// whose TLS and CORS are applied on reload
+type httpServer struct {
+	rest.Server
+	cfg  *config.HTTPServer
+	tls  *reload.TLS
+	cors *reload.CORS
+}
+
+// authzLists specifies the fields of the allow lists
+var authzLists = []string{
+	"Authz.Allow",
+	"Authz.AllowAny",
+	"Authz.AllowAnyRole",
+	"Authz.LogAllowed",
+	"Authz.LogDenied",
+}
+
+// mapperFiles specifies the fields of the role mapper files
+var mapperFiles = []string{
+	"Authz.CertMapper",
+	"Authz.JWTMapper",
+	"Authz.APIKeyMapper",
+}
+
+// reload loads the configuration again, and applies the changes,
+// which do not require restart: log levels, authz allow lists, role mapper files,
+// CORS and TLS files of the servers.
+// The other changes are rejected, and the running configuration is kept.
+func (a *app) reload() {
+	cfg, absCfgFile, err := config.LoadConfig(*a.flags.cfgFile)
+	if err != nil {
+		logger.Errorf("api=reload, reason=LoadConfig, file=%q, err=[%v]", *a.flags.cfgFile, errors.ErrorStack(err))
+		return
+	}
+	a.overrideFromFlags(cfg)
+	logger.Noticef("api=reload, status=loaded, file=%q", absCfgFile)
+
+	changed := config.Diff(a.cfg, cfg)
+	applied := map[string]bool{}
+
+	if has(changed, "LogLevels") {
+		for _, s := range a.servers {
+			if svc, ok := s.Service(logs.ServiceName).(*logs.Service); ok {
+				svc.Reset(cfg.LogLevels)
+			}
+		}
+		setLogLevels(cfg.LogLevels)
+		a.cfg.LogLevels = cfg.LogLevels
+		applied["LogLevels"] = true
+	}
+
+	err = a.container.Invoke(func(azp *reload.Authz, p *roles.Provider, auditor rest.Auditor) {
+		if has(changed, mapperFiles...) {
+			// the auth service uses JWT mapper, so it is removed on restart only
+			jwtMapper := cfg.Authz.JWTMapper
+			if jwtMapper == "" {
+				jwtMapper = a.cfg.Authz.JWTMapper
+			}
+			err := p.SetFiles(jwtMapper, cfg.Authz.APIKeyMapper, cfg.Authz.CertMapper)
+			if err != nil {
+				logger.Errorf("api=reload, reason=SetFiles, err=[%v]", errors.ErrorStack(err))
+			} else {
+				a.cfg.Authz.CertMapper = cfg.Authz.CertMapper
+				a.cfg.Authz.JWTMapper = jwtMapper
+				a.cfg.Authz.APIKeyMapper = cfg.Authz.APIKeyMapper
+				setApplied(applied, "Authz.CertMapper", "Authz.APIKeyMapper")
+				if jwtMapper == cfg.Authz.JWTMapper {
+					setApplied(applied, "Authz.JWTMapper")
+				}
+			}
+		} else if err := p.Reload(); err != nil {
+			logger.Errorf("api=reload, reason=Reload, err=[%v]", errors.ErrorStack(err))
+		}
+
+		if has(changed, authzLists...) {
+			az, err := newAuthz(&cfg.Authz, p, auditor)
+			if err == nil {
+				err = azp.Set(az)
+			}
+			if err != nil {
+				logger.Errorf("api=reload, reason=authz, err=[%v]", errors.ErrorStack(err))
+			} else {
+				a.cfg.Authz.Allow = cfg.Authz.Allow
+				a.cfg.Authz.AllowAny = cfg.Authz.AllowAny
+				a.cfg.Authz.AllowAnyRole = cfg.Authz.AllowAnyRole
+				a.cfg.Authz.LogAllowed = cfg.Authz.LogAllowed
+				a.cfg.Authz.LogDenied = cfg.Authz.LogDenied
+				setApplied(applied, authzLists...)
+			}
+		}
+	})
+	if err != nil {
+		logger.Errorf("api=reload, reason=Invoke, err=[%v]", errors.ErrorStack(err))
+	}
+
+	for _, s := range a.servers {
+		section, loaded := "HTTPS", &cfg.HTTPS
+		if s.cfg == &a.cfg.HTTP {
+			section, loaded = "HTTP", &cfg.HTTP
+		}
+		a.reloadServer(s, section, loaded, changed, applied)
+	}
+
+	rejected := 0
+	for _, field := range changed {
+		if !applied[field] {
+			rejected++
+			logger.Errorf("api=reload, reason=restart_required, field=%q, status=rejected", field)
+		}
+	}
+	logger.Noticef("api=reload, status=completed, changed=%d, rejected=%d", len(changed), rejected)
+}
+
+// reloadServer applies CORS and TLS files of the server
+func (a *app) reloadServer(s *httpServer, section string, loaded *config.HTTPServer, changed []string, applied map[string]bool) {
+	cors := fieldsOf(changed, section+".CORS")
+	if len(cors) > 0 {
+		s.cors.Set(corsOptions(&loaded.CORS))
+		s.cfg.CORS = loaded.CORS
+		setApplied(applied, cors...)
+	}
+
+	files := fieldsOf(changed, section+".ServerTLS")
+	if len(files) == 0 {
+		return
+	}
+	// the listener is not changed between HTTP and HTTPS
+	if s.tls == nil || loaded.ServerTLS.CertFile == "" || loaded.ServerTLS.KeyFile == "" {
+		return
+	}
+	err := s.tls.Set(
+		loaded.ServerTLS.CertFile,
+		loaded.ServerTLS.KeyFile,
+		loaded.ServerTLS.TrustedCAFile,
+		clientAuthType(&loaded.ServerTLS))
+	if err != nil {
+		logger.Errorf("api=reload, reason=TLS, service=%s, err=[%v]", s.cfg.ServiceName, errors.ErrorStack(err))
+		return
+	}
+	s.cfg.ServerTLS = loaded.ServerTLS
+	setApplied(applied, files...)
+}
+
+// has returns true if any of the fields is changed
+func has(changed []string, fields ...string) bool {
+	for _, f := range fields {
+		for _, c := range changed {
+			if c == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldsOf returns the changed fields of the section
+func fieldsOf(changed []string, section string) []string {
+	var list []string
+	for _, c := range changed {
+		if c == section || strings.HasPrefix(c, section+".") {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+func setApplied(applied map[string]bool, fields ...string) {
+	for _, f := range fields {
+		applied[f] = true
+	}
+}
@@ -59,6 +59,73 @@ func (d Duration) TimeDuration() time.Duration {
 	return time.Duration(d)
 }
 
+// Audit contains configuration of the hash-chained audit log.
+type Audit struct {
+
+	// Directory contains where to store the audit files.
+	Directory string
+
+	// MaxAgeDays controls how old files are before deletion.
+	MaxAgeDays int
+
+	// MaxSizeMb contols how large a single audit file can be before its rotated.
+	MaxSizeMb int
+
+	// SigningKey specifies location of the file with PEM-encoded key or PKCS#11 URI of the key to sign checkpoints.
+	SigningKey string
+
+	// CheckpointInterval specifies how often the signed checkpoints are written, default is 1h.
+	CheckpointInterval Duration
+}
+
+func (c *Audit) overrideFrom(o *Audit) {
+	overrideString(&c.Directory, &o.Directory)
+	overrideInt(&c.MaxAgeDays, &o.MaxAgeDays)
+	overrideInt(&c.MaxSizeMb, &o.MaxSizeMb)
+	overrideString(&c.SigningKey, &o.SigningKey)
+	overrideDuration(&c.CheckpointInterval, &o.CheckpointInterval)
+
+}
+
+// AuditConfig contains configuration of the hash-chained audit log.
+type AuditConfig interface {
+	// Directory contains where to store the audit files.
+	GetDirectory() string
+	// MaxAgeDays controls how old files are before deletion.
+	GetMaxAgeDays() int
+	// MaxSizeMb contols how large a single audit file can be before its rotated.
+	GetMaxSizeMb() int
+	// SigningKey specifies location of the file with PEM-encoded key or PKCS#11 URI of the key to sign checkpoints.
+	GetSigningKey() string
+	// CheckpointInterval specifies how often the signed checkpoints are written, default is 1h.
+	GetCheckpointInterval() Duration
+}
+
+// GetDirectory contains where to store the audit files.
+func (c *Audit) GetDirectory() string {
+	return c.Directory
+}
+
+// GetMaxAgeDays controls how old files are before deletion.
+func (c *Audit) GetMaxAgeDays() int {
+	return c.MaxAgeDays
+}
+
+// GetMaxSizeMb contols how large a single audit file can be before its rotated.
+func (c *Audit) GetMaxSizeMb() int {
+	return c.MaxSizeMb
+}
+
+// GetSigningKey specifies location of the file with PEM-encoded key or PKCS#11 URI of the key to sign checkpoints.
+func (c *Audit) GetSigningKey() string {
+	return c.SigningKey
+}
+
+// GetCheckpointInterval specifies how often the signed checkpoints are written, default is 1h.
+func (c *Audit) GetCheckpointInterval() Duration {
+	return c.CheckpointInterval
+}
+
 // Authz contains configuration for the authorization module.
 type Authz struct {
 
@@ -85,6 +152,15 @@ type Authz struct {
 
 	// JWTMapper specifies location of the config file for JWT based identity.
 	JWTMapper string
+
+	// JWTExpiry specifies the lifetime of JWT issued by the auth service.
+	JWTExpiry Duration
+
+	// IdentityProviders specifies the order of identity providers, by default: jwt,apikey,cert.
+	IdentityProviders []string
+
+	// RequireProviders specifies identity providers, all of which are required for this path and its children, in format: ${path}:${provider},${provider}. The identities must have the same name.
+	RequireProviders []string
 }
 
 func (c *Authz) overrideFrom(o *Authz) {
@@ -96,6 +172,9 @@ func (c *Authz) overrideFrom(o *Authz) {
 	overrideString(&c.CertMapper, &o.CertMapper)
 	overrideString(&c.APIKeyMapper, &o.APIKeyMapper)
 	overrideString(&c.JWTMapper, &o.JWTMapper)
+	overrideDuration(&c.JWTExpiry, &o.JWTExpiry)
+	overrideStrings(&c.IdentityProviders, &o.IdentityProviders)
+	overrideStrings(&c.RequireProviders, &o.RequireProviders)
 
 }
 
@@ -117,6 +196,12 @@ type AuthzConfig interface {
 	GetAPIKeyMapper() string
 	// JWTMapper specifies location of the config file for JWT based identity.
 	GetJWTMapper() string
+	// JWTExpiry specifies the lifetime of JWT issued by the auth service.
+	GetJWTExpiry() Duration
+	// IdentityProviders specifies the order of identity providers, by default: jwt,apikey,cert.
+	GetIdentityProviders() []string
+	// RequireProviders specifies identity providers, all of which are required for this path and its children, in format: ${path}:${provider},${provider}. The identities must have the same name.
+	GetRequireProviders() []string
 }
 
 // GetAllow will allow the specified roles access to this path and its children, in format: ${path}:${role},${role}.
@@ -159,6 +244,21 @@ func (c *Authz) GetJWTMapper() string {
 	return c.JWTMapper
 }
 
+// GetJWTExpiry specifies the lifetime of JWT issued by the auth service.
+func (c *Authz) GetJWTExpiry() Duration {
+	return c.JWTExpiry
+}
+
+// GetIdentityProviders specifies the order of identity providers, by default: jwt,apikey,cert.
+func (c *Authz) GetIdentityProviders() []string {
+	return c.IdentityProviders
+}
+
+// GetRequireProviders specifies identity providers, all of which are required for this path and its children, in format: ${path}:${provider},${provider}. The identities must have the same name.
+func (c *Authz) GetRequireProviders() []string {
+	return c.RequireProviders
+}
+
 // CORS contains configuration for CORS.
 type CORS struct {
 
@@ -292,14 +392,20 @@ type Configuration struct {
 	Authz Authz
 
 	// Audit contains configuration for the audit logger.
-	Audit Logger
+	Audit Audit
 
 	// CryptoProv specifies the configuration for crypto providers.
 	CryptoProv CryptoProv
 
+	// Datahub specifies the configuration for the data storage.
+	Datahub Datahub
+
 	// Metrics specifies the metrics pipeline configuration.
 	Metrics Metrics
 
+	// Shutdown specifies the graceful shutdown configuration.
+	Shutdown Shutdown
+
 	// Logger contains configuration for the logger.
 	Logger Logger
 
@@ -319,7 +425,9 @@ func (c *Configuration) overrideFrom(o *Configuration) {
 	c.Authz.overrideFrom(&o.Authz)
 	c.Audit.overrideFrom(&o.Audit)
 	c.CryptoProv.overrideFrom(&o.CryptoProv)
+	c.Datahub.overrideFrom(&o.Datahub)
 	c.Metrics.overrideFrom(&o.Metrics)
+	c.Shutdown.overrideFrom(&o.Shutdown)
 	c.Logger.overrideFrom(&o.Logger)
 	overrideRepoLogLevelSlice(&c.LogLevels, &o.LogLevels)
 	overrideString(&c.RootCA, &o.RootCA)
@@ -342,6 +450,22 @@ func (c *CryptoProv) overrideFrom(o *CryptoProv) {
 
 }
 
+// Datahub specifies the configuration for the data storage.
+type Datahub struct {
+
+	// Provider specifies the datahub provider: inmemory|sqlite3.
+	Provider string
+
+	// DataSource specifies the provider specific data source name, for example the path to SQLite file.
+	DataSource string
+}
+
+func (c *Datahub) overrideFrom(o *Datahub) {
+	overrideString(&c.Provider, &o.Provider)
+	overrideString(&c.DataSource, &o.DataSource)
+
+}
+
 // HTTPServer contains the configuration of the HTTPS API Service.
 type HTTPServer struct {
 
@@ -523,12 +647,16 @@ func (c *Logger) GetMaxSizeMb() int {
 // Metrics specifies the metrics pipeline configuration.
 type Metrics struct {
 
-	// Provider specifies the metrics provider.
+	// Provider specifies the metrics provider: inmemory|statsd|datadog|prometheus.
 	Provider string
+
+	// Address specifies the address of statsd or datadog agent, default is 127.0.0.1:8125.
+	Address string
 }
 
 func (c *Metrics) overrideFrom(o *Metrics) {
 	overrideString(&c.Provider, &o.Provider)
+	overrideString(&c.Address, &o.Address)
 
 }
 
@@ -552,6 +680,51 @@ func (c *RepoLogLevel) overrideFrom(o *RepoLogLevel) {
 
 }
 
+// Shutdown specifies the graceful shutdown configuration.
+type Shutdown struct {
+
+	// Timeout specifies the deadline to drain in-flight requests, default is 30s.
+	Timeout Duration
+
+	// ReadinessDelay specifies how long the readiness check reports not ready before draining, to let load balancers stop routing requests.
+	ReadinessDelay Duration
+
+	// UpstartWait specifies how long to wait for SIGTERM after SIGUSR2, default is 15s.
+	UpstartWait Duration
+}
+
+func (c *Shutdown) overrideFrom(o *Shutdown) {
+	overrideDuration(&c.Timeout, &o.Timeout)
+	overrideDuration(&c.ReadinessDelay, &o.ReadinessDelay)
+	overrideDuration(&c.UpstartWait, &o.UpstartWait)
+
+}
+
+// ShutdownConfig specifies the graceful shutdown configuration.
+type ShutdownConfig interface {
+	// Timeout specifies the deadline to drain in-flight requests, default is 30s.
+	GetTimeout() Duration
+	// ReadinessDelay specifies how long the readiness check reports not ready before draining, to let load balancers stop routing requests.
+	GetReadinessDelay() Duration
+	// UpstartWait specifies how long to wait for SIGTERM after SIGUSR2, default is 15s.
+	GetUpstartWait() Duration
+}
+
+// GetTimeout specifies the deadline to drain in-flight requests, default is 30s.
+func (c *Shutdown) GetTimeout() Duration {
+	return c.Timeout
+}
+
+// GetReadinessDelay specifies how long the readiness check reports not ready before draining, to let load balancers stop routing requests.
+func (c *Shutdown) GetReadinessDelay() Duration {
+	return c.ReadinessDelay
+}
+
+// GetUpstartWait specifies how long to wait for SIGTERM after SIGUSR2, default is 15s.
+func (c *Shutdown) GetUpstartWait() Duration {
+	return c.UpstartWait
+}
+
 // TLSInfo contains configuration info for the TLS.
 type TLSInfo struct {
 
@@ -614,6 +787,12 @@ func overrideBool(d, o **bool) {
 	}
 }
 
+func overrideDuration(d, o *Duration) {
+	if *o != 0 {
+		*d = *o
+	}
+}
+
 func overrideInt(d, o *int) {
 	if *o != 0 {
 		*d = *o
@@ -85,6 +85,16 @@ func Test_overrideBool(t *testing.T) {
 	require.Equal(t, d, o, "overrideBool should of overriden the value but didn't. value %v, expecting %v", d, o)
 }
 
+func Test_overrideDuration(t *testing.T) {
+	d := Duration(time.Second)
+	var zero Duration
+	overrideDuration(&d, &zero)
+	require.NotEqual(t, d, zero, "overrideDuration shouldn't have overriden the value as the override is the default/zero value. value now %v", d)
+	o := Duration(time.Minute)
+	overrideDuration(&d, &o)
+	require.Equal(t, d, o, "overrideDuration should of overriden the value but didn't. value %v, expecting %v", d, o)
+}
+
 func Test_overrideInt(t *testing.T) {
 	d := -42
 	var zero int
@@ -135,29 +145,88 @@ func Test_overrideStrings(t *testing.T) {
 	require.Equal(t, d, o, "overrideStrings should of overriden the value but didn't. value %v, expecting %v", d, o)
 }
 
+func TestAudit_overrideFrom(t *testing.T) {
+	orig := Audit{
+		Directory:          "one",
+		MaxAgeDays:         -42,
+		MaxSizeMb:          -42,
+		SigningKey:         "one",
+		CheckpointInterval: Duration(time.Second)}
+	dest := orig
+	var zero Audit
+	dest.overrideFrom(&zero)
+	require.Equal(t, dest, orig, "Audit.overrideFrom shouldn't have overriden the value as the override is the default/zero value. value now %#v", dest)
+	o := Audit{
+		Directory:          "two",
+		MaxAgeDays:         42,
+		MaxSizeMb:          42,
+		SigningKey:         "two",
+		CheckpointInterval: Duration(time.Minute)}
+	dest.overrideFrom(&o)
+	require.Equal(t, dest, o, "Audit.overrideFrom should have overriden the value as the override. value now %#v, expecting %#v", dest, o)
+	o2 := Audit{
+		Directory: "one"}
+	dest.overrideFrom(&o2)
+	exp := o
+
+	exp.Directory = o2.Directory
+	require.Equal(t, dest, exp, "Audit.overrideFrom should have overriden the field Directory. value now %#v, expecting %#v", dest, exp)
+}
+
+func TestAudit_Getters(t *testing.T) {
+	orig := Audit{
+		Directory:          "one",
+		MaxAgeDays:         -42,
+		MaxSizeMb:          -42,
+		SigningKey:         "one",
+		CheckpointInterval: Duration(time.Second)}
+
+	gv0 := orig.GetDirectory()
+	require.Equal(t, orig.Directory, gv0, "Audit.GetDirectoryCfg() does not match")
+
+	gv1 := orig.GetMaxAgeDays()
+	require.Equal(t, orig.MaxAgeDays, gv1, "Audit.GetMaxAgeDaysCfg() does not match")
+
+	gv2 := orig.GetMaxSizeMb()
+	require.Equal(t, orig.MaxSizeMb, gv2, "Audit.GetMaxSizeMbCfg() does not match")
+
+	gv3 := orig.GetSigningKey()
+	require.Equal(t, orig.SigningKey, gv3, "Audit.GetSigningKeyCfg() does not match")
+
+	gv4 := orig.GetCheckpointInterval()
+	require.Equal(t, orig.CheckpointInterval, gv4, "Audit.GetCheckpointIntervalCfg() does not match")
+
+}
+
 func TestAuthz_overrideFrom(t *testing.T) {
 	orig := Authz{
-		Allow:        []string{"a"},
-		AllowAny:     []string{"a"},
-		AllowAnyRole: []string{"a"},
-		LogAllowed:   &trueVal,
-		LogDenied:    &trueVal,
-		CertMapper:   "one",
-		APIKeyMapper: "one",
-		JWTMapper:    "one"}
+		Allow:             []string{"a"},
+		AllowAny:          []string{"a"},
+		AllowAnyRole:      []string{"a"},
+		LogAllowed:        &trueVal,
+		LogDenied:         &trueVal,
+		CertMapper:        "one",
+		APIKeyMapper:      "one",
+		JWTMapper:         "one",
+		JWTExpiry:         Duration(time.Second),
+		IdentityProviders: []string{"a"},
+		RequireProviders:  []string{"a"}}
 	dest := orig
 	var zero Authz
 	dest.overrideFrom(&zero)
 	require.Equal(t, dest, orig, "Authz.overrideFrom shouldn't have overriden the value as the override is the default/zero value. value now %#v", dest)
 	o := Authz{
-		Allow:        []string{"b", "b"},
-		AllowAny:     []string{"b", "b"},
-		AllowAnyRole: []string{"b", "b"},
-		LogAllowed:   &falseVal,
-		LogDenied:    &falseVal,
-		CertMapper:   "two",
-		APIKeyMapper: "two",
-		JWTMapper:    "two"}
+		Allow:             []string{"b", "b"},
+		AllowAny:          []string{"b", "b"},
+		AllowAnyRole:      []string{"b", "b"},
+		LogAllowed:        &falseVal,
+		LogDenied:         &falseVal,
+		CertMapper:        "two",
+		APIKeyMapper:      "two",
+		JWTMapper:         "two",
+		JWTExpiry:         Duration(time.Minute),
+		IdentityProviders: []string{"b", "b"},
+		RequireProviders:  []string{"b", "b"}}
 	dest.overrideFrom(&o)
 	require.Equal(t, dest, o, "Authz.overrideFrom should have overriden the value as the override. value now %#v, expecting %#v", dest, o)
 	o2 := Authz{
@@ -171,14 +240,17 @@ func TestAuthz_overrideFrom(t *testing.T) {
 
 func TestAuthz_Getters(t *testing.T) {
 	orig := Authz{
-		Allow:        []string{"a"},
-		AllowAny:     []string{"a"},
-		AllowAnyRole: []string{"a"},
-		LogAllowed:   &trueVal,
-		LogDenied:    &trueVal,
-		CertMapper:   "one",
-		APIKeyMapper: "one",
-		JWTMapper:    "one"}
+		Allow:             []string{"a"},
+		AllowAny:          []string{"a"},
+		AllowAnyRole:      []string{"a"},
+		LogAllowed:        &trueVal,
+		LogDenied:         &trueVal,
+		CertMapper:        "one",
+		APIKeyMapper:      "one",
+		JWTMapper:         "one",
+		JWTExpiry:         Duration(time.Second),
+		IdentityProviders: []string{"a"},
+		RequireProviders:  []string{"a"}}
 
 	gv0 := orig.GetAllow()
 	require.Equal(t, orig.Allow, gv0, "Authz.GetAllowCfg() does not match")
@@ -204,6 +276,15 @@ func TestAuthz_Getters(t *testing.T) {
 	gv7 := orig.GetJWTMapper()
 	require.Equal(t, orig.JWTMapper, gv7, "Authz.GetJWTMapperCfg() does not match")
 
+	gv8 := orig.GetJWTExpiry()
+	require.Equal(t, orig.JWTExpiry, gv8, "Authz.GetJWTExpiryCfg() does not match")
+
+	gv9 := orig.GetIdentityProviders()
+	require.Equal(t, orig.IdentityProviders, gv9, "Authz.GetIdentityProvidersCfg() does not match")
+
+	gv10 := orig.GetRequireProviders()
+	require.Equal(t, orig.RequireProviders, gv10, "Authz.GetRequireProvidersCfg() does not match")
+
 }
 
 func TestCORS_overrideFrom(t *testing.T) {
@@ -339,23 +420,36 @@ func TestConfiguration_overrideFrom(t *testing.T) {
 				OptionsPassthrough: &trueVal,
 				Debug:              &trueVal}},
 		Authz: Authz{
-			Allow:        []string{"a"},
-			AllowAny:     []string{"a"},
-			AllowAnyRole: []string{"a"},
-			LogAllowed:   &trueVal,
-			LogDenied:    &trueVal,
-			CertMapper:   "one",
-			APIKeyMapper: "one",
-			JWTMapper:    "one"},
-		Audit: Logger{
-			Directory:  "one",
-			MaxAgeDays: -42,
-			MaxSizeMb:  -42},
+			Allow:             []string{"a"},
+			AllowAny:          []string{"a"},
+			AllowAnyRole:      []string{"a"},
+			LogAllowed:        &trueVal,
+			LogDenied:         &trueVal,
+			CertMapper:        "one",
+			APIKeyMapper:      "one",
+			JWTMapper:         "one",
+			JWTExpiry:         Duration(time.Second),
+			IdentityProviders: []string{"a"},
+			RequireProviders:  []string{"a"}},
+		Audit: Audit{
+			Directory:          "one",
+			MaxAgeDays:         -42,
+			MaxSizeMb:          -42,
+			SigningKey:         "one",
+			CheckpointInterval: Duration(time.Second)},
 		CryptoProv: CryptoProv{
 			Default:   "one",
 			Providers: []string{"a"}},
+		Datahub: Datahub{
+			Provider:   "one",
+			DataSource: "one"},
 		Metrics: Metrics{
-			Provider: "one"},
+			Provider: "one",
+			Address:  "one"},
+		Shutdown: Shutdown{
+			Timeout:        Duration(time.Second),
+			ReadinessDelay: Duration(time.Second),
+			UpstartWait:    Duration(time.Second)},
 		Logger: Logger{
 			Directory:  "one",
 			MaxAgeDays: -42,
@@ -426,23 +520,36 @@ func TestConfiguration_overrideFrom(t *testing.T) {
 				OptionsPassthrough: &falseVal,
 				Debug:              &falseVal}},
 		Authz: Authz{
-			Allow:        []string{"b", "b"},
-			AllowAny:     []string{"b", "b"},
-			AllowAnyRole: []string{"b", "b"},
-			LogAllowed:   &falseVal,
-			LogDenied:    &falseVal,
-			CertMapper:   "two",
-			APIKeyMapper: "two",
-			JWTMapper:    "two"},
-		Audit: Logger{
-			Directory:  "two",
-			MaxAgeDays: 42,
-			MaxSizeMb:  42},
+			Allow:             []string{"b", "b"},
+			AllowAny:          []string{"b", "b"},
+			AllowAnyRole:      []string{"b", "b"},
+			LogAllowed:        &falseVal,
+			LogDenied:         &falseVal,
+			CertMapper:        "two",
+			APIKeyMapper:      "two",
+			JWTMapper:         "two",
+			JWTExpiry:         Duration(time.Minute),
+			IdentityProviders: []string{"b", "b"},
+			RequireProviders:  []string{"b", "b"}},
+		Audit: Audit{
+			Directory:          "two",
+			MaxAgeDays:         42,
+			MaxSizeMb:          42,
+			SigningKey:         "two",
+			CheckpointInterval: Duration(time.Minute)},
 		CryptoProv: CryptoProv{
 			Default:   "two",
 			Providers: []string{"b", "b"}},
+		Datahub: Datahub{
+			Provider:   "two",
+			DataSource: "two"},
 		Metrics: Metrics{
-			Provider: "two"},
+			Provider: "two",
+			Address:  "two"},
+		Shutdown: Shutdown{
+			Timeout:        Duration(time.Minute),
+			ReadinessDelay: Duration(time.Minute),
+			UpstartWait:    Duration(time.Minute)},
 		Logger: Logger{
 			Directory:  "two",
 			MaxAgeDays: 42,
@@ -487,6 +594,28 @@ func TestCryptoProv_overrideFrom(t *testing.T) {
 	require.Equal(t, dest, exp, "CryptoProv.overrideFrom should have overriden the field Default. value now %#v, expecting %#v", dest, exp)
 }
 
+func TestDatahub_overrideFrom(t *testing.T) {
+	orig := Datahub{
+		Provider:   "one",
+		DataSource: "one"}
+	dest := orig
+	var zero Datahub
+	dest.overrideFrom(&zero)
+	require.Equal(t, dest, orig, "Datahub.overrideFrom shouldn't have overriden the value as the override is the default/zero value. value now %#v", dest)
+	o := Datahub{
+		Provider:   "two",
+		DataSource: "two"}
+	dest.overrideFrom(&o)
+	require.Equal(t, dest, o, "Datahub.overrideFrom should have overriden the value as the override. value now %#v, expecting %#v", dest, o)
+	o2 := Datahub{
+		Provider: "one"}
+	dest.overrideFrom(&o2)
+	exp := o
+
+	exp.Provider = o2.Provider
+	require.Equal(t, dest, exp, "Datahub.overrideFrom should have overriden the field Provider. value now %#v, expecting %#v", dest, exp)
+}
+
 func TestHTTPServer_overrideFrom(t *testing.T) {
 	orig := HTTPServer{
 		ServiceName: "one",
@@ -658,13 +787,15 @@ func TestLogger_Getters(t *testing.T) {
 
 func TestMetrics_overrideFrom(t *testing.T) {
 	orig := Metrics{
-		Provider: "one"}
+		Provider: "one",
+		Address:  "one"}
 	dest := orig
 	var zero Metrics
 	dest.overrideFrom(&zero)
 	require.Equal(t, dest, orig, "Metrics.overrideFrom shouldn't have overriden the value as the override is the default/zero value. value now %#v", dest)
 	o := Metrics{
-		Provider: "two"}
+		Provider: "two",
+		Address:  "two"}
 	dest.overrideFrom(&o)
 	require.Equal(t, dest, o, "Metrics.overrideFrom should have overriden the value as the override. value now %#v, expecting %#v", dest, o)
 	o2 := Metrics{
@@ -700,6 +831,47 @@ func TestRepoLogLevel_overrideFrom(t *testing.T) {
 	require.Equal(t, dest, exp, "RepoLogLevel.overrideFrom should have overriden the field Repo. value now %#v, expecting %#v", dest, exp)
 }
 
+func TestShutdown_overrideFrom(t *testing.T) {
+	orig := Shutdown{
+		Timeout:        Duration(time.Second),
+		ReadinessDelay: Duration(time.Second),
+		UpstartWait:    Duration(time.Second)}
+	dest := orig
+	var zero Shutdown
+	dest.overrideFrom(&zero)
+	require.Equal(t, dest, orig, "Shutdown.overrideFrom shouldn't have overriden the value as the override is the default/zero value. value now %#v", dest)
+	o := Shutdown{
+		Timeout:        Duration(time.Minute),
+		ReadinessDelay: Duration(time.Minute),
+		UpstartWait:    Duration(time.Minute)}
+	dest.overrideFrom(&o)
+	require.Equal(t, dest, o, "Shutdown.overrideFrom should have overriden the value as the override. value now %#v, expecting %#v", dest, o)
+	o2 := Shutdown{
+		Timeout: Duration(time.Second)}
+	dest.overrideFrom(&o2)
+	exp := o
+
+	exp.Timeout = o2.Timeout
+	require.Equal(t, dest, exp, "Shutdown.overrideFrom should have overriden the field Timeout. value now %#v, expecting %#v", dest, exp)
+}
+
+func TestShutdown_Getters(t *testing.T) {
+	orig := Shutdown{
+		Timeout:        Duration(time.Second),
+		ReadinessDelay: Duration(time.Second),
+		UpstartWait:    Duration(time.Second)}
+
+	gv0 := orig.GetTimeout()
+	require.Equal(t, orig.Timeout, gv0, "Shutdown.GetTimeoutCfg() does not match")
+
+	gv1 := orig.GetReadinessDelay()
+	require.Equal(t, orig.ReadinessDelay, gv1, "Shutdown.GetReadinessDelayCfg() does not match")
+
+	gv2 := orig.GetUpstartWait()
+	require.Equal(t, orig.UpstartWait, gv2, "Shutdown.GetUpstartWaitCfg() does not match")
+
+}
+
 func TestTLSInfo_overrideFrom(t *testing.T) {
 	orig := TLSInfo{
 		CertFile:       "one",
@@ -805,23 +977,36 @@ func Test_LoadOverrides(t *testing.T) {
 					OptionsPassthrough: &falseVal,
 					Debug:              &falseVal}},
 			Authz: Authz{
-				Allow:        []string{"b", "b"},
-				AllowAny:     []string{"b", "b"},
-				AllowAnyRole: []string{"b", "b"},
-				LogAllowed:   &falseVal,
-				LogDenied:    &falseVal,
-				CertMapper:   "two",
-				APIKeyMapper: "two",
-				JWTMapper:    "two"},
-			Audit: Logger{
-				Directory:  "two",
-				MaxAgeDays: 42,
-				MaxSizeMb:  42},
+				Allow:             []string{"b", "b"},
+				AllowAny:          []string{"b", "b"},
+				AllowAnyRole:      []string{"b", "b"},
+				LogAllowed:        &falseVal,
+				LogDenied:         &falseVal,
+				CertMapper:        "two",
+				APIKeyMapper:      "two",
+				JWTMapper:         "two",
+				JWTExpiry:         Duration(time.Minute),
+				IdentityProviders: []string{"b", "b"},
+				RequireProviders:  []string{"b", "b"}},
+			Audit: Audit{
+				Directory:          "two",
+				MaxAgeDays:         42,
+				MaxSizeMb:          42,
+				SigningKey:         "two",
+				CheckpointInterval: Duration(time.Minute)},
 			CryptoProv: CryptoProv{
 				Default:   "two",
 				Providers: []string{"b", "b"}},
+			Datahub: Datahub{
+				Provider:   "two",
+				DataSource: "two"},
 			Metrics: Metrics{
-				Provider: "two"},
+				Provider: "two",
+				Address:  "two"},
+			Shutdown: Shutdown{
+				Timeout:        Duration(time.Minute),
+				ReadinessDelay: Duration(time.Minute),
+				UpstartWait:    Duration(time.Minute)},
 			Logger: Logger{
 				Directory:  "two",
 				MaxAgeDays: 42,
@@ -890,23 +1075,36 @@ func Test_LoadOverrides(t *testing.T) {
 						OptionsPassthrough: &trueVal,
 						Debug:              &trueVal}},
 				Authz: Authz{
-					Allow:        []string{"c", "c", "c"},
-					AllowAny:     []string{"c", "c", "c"},
-					AllowAnyRole: []string{"c", "c", "c"},
-					LogAllowed:   &trueVal,
-					LogDenied:    &trueVal,
-					CertMapper:   "three",
-					APIKeyMapper: "three",
-					JWTMapper:    "three"},
-				Audit: Logger{
-					Directory:  "three",
-					MaxAgeDays: 1234,
-					MaxSizeMb:  1234},
+					Allow:             []string{"c", "c", "c"},
+					AllowAny:          []string{"c", "c", "c"},
+					AllowAnyRole:      []string{"c", "c", "c"},
+					LogAllowed:        &trueVal,
+					LogDenied:         &trueVal,
+					CertMapper:        "three",
+					APIKeyMapper:      "three",
+					JWTMapper:         "three",
+					JWTExpiry:         Duration(time.Hour),
+					IdentityProviders: []string{"c", "c", "c"},
+					RequireProviders:  []string{"c", "c", "c"}},
+				Audit: Audit{
+					Directory:          "three",
+					MaxAgeDays:         1234,
+					MaxSizeMb:          1234,
+					SigningKey:         "three",
+					CheckpointInterval: Duration(time.Hour)},
 				CryptoProv: CryptoProv{
 					Default:   "three",
 					Providers: []string{"c", "c", "c"}},
+				Datahub: Datahub{
+					Provider:   "three",
+					DataSource: "three"},
 				Metrics: Metrics{
-					Provider: "three"},
+					Provider: "three",
+					Address:  "three"},
+				Shutdown: Shutdown{
+					Timeout:        Duration(time.Hour),
+					ReadinessDelay: Duration(time.Hour),
+					UpstartWait:    Duration(time.Hour)},
 				Logger: Logger{
 					Directory:  "three",
 					MaxAgeDays: 1234,
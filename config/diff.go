@@ -0,0 +1,37 @@
+package config
+
+import (
+	"reflect"
+)
+
+// Diff returns the list of fields changed in the configuration,
+// the nested fields are specified by the path, for example HTTPS.BindAddr.
+// The slices and maps are compared as a whole.
+func Diff(running, loaded *Configuration) []string {
+	return diff("", reflect.ValueOf(running).Elem(), reflect.ValueOf(loaded).Elem())
+}
+
+func diff(prefix string, a, b reflect.Value) []string {
+	if a.Kind() != reflect.Struct {
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	var changed []string
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		path := f.Name
+		if prefix != "" {
+			path = prefix + "." + f.Name
+		}
+		changed = append(changed, diff(path, a.Field(i), b.Field(i))...)
+	}
+	return changed
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Diff(t *testing.T) {
+	running := &Configuration{
+		ServiceName: "dolly",
+		HTTPS: HTTPServer{
+			BindAddr: ":7443",
+			CORS: CORS{
+				AllowedOrigins: []string{"*"},
+			},
+		},
+		LogLevels: []RepoLogLevel{
+			{Repo: "*", Level: "INFO"},
+		},
+	}
+	loaded := &Configuration{
+		ServiceName: "dolly",
+		HTTPS: HTTPServer{
+			BindAddr: ":7443",
+			CORS: CORS{
+				AllowedOrigins: []string{"*"},
+			},
+		},
+		LogLevels: []RepoLogLevel{
+			{Repo: "*", Level: "INFO"},
+		},
+	}
+	assert.Empty(t, Diff(running, loaded))
+
+	loaded.HTTPS.BindAddr = ":8443"
+	loaded.HTTPS.CORS.AllowedOrigins = []string{"https://*.example.com"}
+	loaded.LogLevels[0].Level = "DEBUG"
+	assert.Equal(t, []string{
+		"HTTPS.BindAddr",
+		"HTTPS.CORS.AllowedOrigins",
+		"LogLevels",
+	}, Diff(running, loaded))
+}
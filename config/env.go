@@ -0,0 +1,228 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// EnvPrefix specifies the prefix of the environment variables,
+// which override the configuration fields, for example DOLLY_HTTPS_BINDADDR
+const EnvPrefix = "DOLLY_"
+
+// LookupEnvFunc returns the value of the environment variable,
+// and true if the variable is present
+type LookupEnvFunc func(key string) (string, bool)
+
+var durationType = reflect.TypeOf(Duration(0))
+
+// OverrideFromEnv sets the fields of the configuration from the environment variables,
+// the name of the variable is the prefix followed by the upper case path of the field
+// joined with underscore, for example DOLLY_HTTPS_SERVERTLS_CERTFILE.
+// The values of []string fields are comma separated,
+// the values of the fields with struct slices are in JSON format.
+// It returns the list of the overridden fields.
+func (c *Configuration) OverrideFromEnv(prefix string, lookup LookupEnvFunc) ([]string, error) {
+	var overridden []string
+	err := overrideFromEnv(reflect.ValueOf(c).Elem(), "", prefix, lookup, &overridden)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return overridden, nil
+}
+
+func overrideFromEnv(v reflect.Value, path, envName string, lookup LookupEnvFunc, overridden *[]string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		field := f.Name
+		if path != "" {
+			field = path + "." + f.Name
+		}
+		name := envName + strings.ToUpper(f.Name)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			if err := overrideFromEnv(fv, field, name+"_", lookup, overridden); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+
+		s, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, s); err != nil {
+			return errors.Annotatef(err, "invalid value of %s for %s", name, field)
+		}
+		*overridden = append(*overridden, field)
+	}
+	return nil
+}
+
+// setValue parses the value of the environment variable into the field
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		v.SetInt(int64(i))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		v.SetBool(b)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), s); err != nil {
+			return errors.Trace(err)
+		}
+		v.Set(p)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			v.Set(reflect.ValueOf(trimAll(strings.Split(s, ","))))
+			return nil
+		}
+		p := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(s), p.Interface()); err != nil {
+			return errors.Trace(err)
+		}
+		v.Set(p.Elem())
+	default:
+		return errors.NotSupportedf("type %v", v.Type())
+	}
+	return nil
+}
+
+var interpolationRegex = regexp.MustCompile(`\$\{(ENV|FILE):([^}]+)\}`)
+
+// MaskedValue replaces the masked values of the configuration
+const MaskedValue = "******"
+
+// Interpolate replaces ${ENV:NAME} with the value of the environment variable,
+// and ${FILE:/path} with the content of the file in all string fields of the configuration.
+// The relative file paths are resolved from baseDir,
+// the trailing new line of the file content is removed.
+// It returns the list of the interpolated fields.
+func (c *Configuration) Interpolate(baseDir string, lookup LookupEnvFunc) ([]string, error) {
+	var interpolated []string
+	err := walkStrings(reflect.ValueOf(c).Elem(), "", func(field string, v reflect.Value) error {
+		if !interpolationRegex.MatchString(v.String()) {
+			return nil
+		}
+		s, err := interpolateString(v.String(), baseDir, lookup)
+		if err != nil {
+			return errors.Annotatef(err, "unable to interpolate %s", field)
+		}
+		v.SetString(s)
+		interpolated = append(interpolated, field)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return interpolated, nil
+}
+
+// Mask replaces the values of the fields with MaskedValue,
+// the interpolated or overridden values may contain secrets and must not be printed.
+// The masked slice or struct field masks all its string values.
+func (c *Configuration) Mask(fields []string) {
+	walkStrings(reflect.ValueOf(c).Elem(), "", func(field string, v reflect.Value) error {
+		for _, f := range fields {
+			if field == f || strings.HasPrefix(field, f+"[") || strings.HasPrefix(field, f+".") {
+				v.SetString(MaskedValue)
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// walkStrings calls fn for all string fields of v
+func walkStrings(v reflect.Value, field string, fn func(field string, v reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.String:
+		return fn(field, v)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := f.Name
+			if field != "" {
+				name = field + "." + f.Name
+			}
+			if err := walkStrings(v.Field(i), name, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStrings(v.Index(i), field+"["+strconv.Itoa(i)+"]", fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func interpolateString(s, baseDir string, lookup LookupEnvFunc) (string, error) {
+	var err error
+	res := interpolationRegex.ReplaceAllStringFunc(s, func(m string) string {
+		if err != nil {
+			return m
+		}
+		parts := interpolationRegex.FindStringSubmatch(m)
+		switch parts[1] {
+		case "ENV":
+			val, ok := lookup(parts[2])
+			if !ok {
+				err = errors.NotFoundf("environment variable %q", parts[2])
+			}
+			return val
+		default:
+			file := parts[2]
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(baseDir, file)
+			}
+			b, rerr := ioutil.ReadFile(file)
+			if rerr != nil {
+				err = errors.Trace(rerr)
+				return m
+			}
+			return strings.TrimRight(string(b), "\r\n")
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return res, nil
+}
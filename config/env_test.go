@@ -0,0 +1,114 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lookupFrom(env map[string]string) LookupEnvFunc {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func Test_OverrideFromEnv(t *testing.T) {
+	c := &Configuration{
+		ServiceName: "dolly",
+		HTTPS:       HTTPServer{BindAddr: ":8443"},
+	}
+
+	overridden, err := c.OverrideFromEnv(EnvPrefix, lookupFrom(map[string]string{
+		"DOLLY_HTTPS_BINDADDR":           ":9443",
+		"DOLLY_HTTPS_SERVERTLS_CERTFILE": "/tmp/cert.pem",
+		"DOLLY_HTTPS_DISABLED":           "true",
+		"DOLLY_HTTPS_HEARTBEATSECS":      "10",
+		"DOLLY_HTTPS_SERVICES":           "status, logs",
+		"DOLLY_SHUTDOWN_TIMEOUT":         "1m",
+		"DOLLY_LOGLEVELS":                `[{"Repo":"*","Level":"DEBUG"}]`,
+		"DOLLY_HOSTNAME":                 "local",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"HTTPS.Disabled",
+		"HTTPS.BindAddr",
+		"HTTPS.ServerTLS.CertFile",
+		"HTTPS.Services",
+		"HTTPS.HeartbeatSecs",
+		"Shutdown.Timeout",
+		"LogLevels",
+	}, overridden)
+
+	assert.Equal(t, "dolly", c.ServiceName)
+	assert.Equal(t, ":9443", c.HTTPS.BindAddr)
+	assert.Equal(t, "/tmp/cert.pem", c.HTTPS.ServerTLS.CertFile)
+	assert.True(t, c.HTTPS.GetDisabled())
+	assert.Equal(t, 10, c.HTTPS.HeartbeatSecs)
+	assert.Equal(t, []string{"status", "logs"}, c.HTTPS.Services)
+	assert.Equal(t, time.Minute, c.Shutdown.Timeout.TimeDuration())
+	assert.Equal(t, []RepoLogLevel{{Repo: "*", Level: "DEBUG"}}, c.LogLevels)
+
+	_, err = c.OverrideFromEnv(EnvPrefix, lookupFrom(map[string]string{
+		"DOLLY_AUDIT_MAXAGEDAYS": "week",
+	}))
+	assert.EqualError(t, err, `invalid value of DOLLY_AUDIT_MAXAGEDAYS for Audit.MaxAgeDays: strconv.Atoi: parsing "week": invalid syntax`)
+}
+
+func Test_Interpolate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "dsn"), []byte("postgres://dolly:secret@db\n"), 0600))
+
+	c := &Configuration{
+		Environment: "${ENV:DOLLY_ENV}",
+		Datahub:     Datahub{DataSource: "${FILE:dsn}"},
+		HTTPS: HTTPServer{
+			ServerTLS: TLSInfo{CertFile: "${ENV:CERTS}/${HOSTNAME}.pem"},
+			Services:  []string{"status", "${ENV:SERVICE}"},
+		},
+		LogLevels: []RepoLogLevel{{Repo: "*", Level: "${ENV:LEVEL}"}},
+	}
+	env := lookupFrom(map[string]string{
+		"DOLLY_ENV": "test",
+		"CERTS":     "/etc/certs",
+		"SERVICE":   "logs",
+		"LEVEL":     "DEBUG",
+	})
+	interpolated, err := c.Interpolate(dir, env)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Environment", "HTTPS.ServerTLS.CertFile", "HTTPS.Services[1]", "Datahub.DataSource", "LogLevels[0].Level"}, interpolated)
+	assert.Equal(t, "test", c.Environment)
+	assert.Equal(t, "postgres://dolly:secret@db", c.Datahub.DataSource)
+	assert.Equal(t, "/etc/certs/${HOSTNAME}.pem", c.HTTPS.ServerTLS.CertFile)
+	assert.Equal(t, []string{"status", "logs"}, c.HTTPS.Services)
+	assert.Equal(t, "DEBUG", c.LogLevels[0].Level)
+
+	c.Mask(interpolated)
+	assert.Equal(t, MaskedValue, c.Datahub.DataSource)
+	assert.Equal(t, []string{"status", MaskedValue}, c.HTTPS.Services)
+	assert.Equal(t, "*", c.LogLevels[0].Repo)
+
+	// the overridden slice and struct fields are masked entirely
+	c.HTTPS.Services = []string{"status", "logs"}
+	c.HTTPS.BindAddr = ":8443"
+	c.Mask([]string{"HTTPS.Services", "LogLevels"})
+	assert.Equal(t, []string{MaskedValue, MaskedValue}, c.HTTPS.Services)
+	assert.Equal(t, MaskedValue, c.LogLevels[0].Repo)
+	assert.Equal(t, ":8443", c.HTTPS.BindAddr)
+
+	c = &Configuration{RootCA: "${ENV:MISSING}"}
+	_, err = c.Interpolate(dir, env)
+	assert.EqualError(t, err, `unable to interpolate RootCA: environment variable "MISSING" not found`)
+
+	c = &Configuration{Datahub: Datahub{DataSource: "${FILE:/missing/dsn}"}}
+	_, err = c.Interpolate(dir, env)
+	assert.Error(t, err)
+}
@@ -6,6 +6,7 @@ package config
 // The implementation is primarily provided by the go-phorce/configen tool.
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -27,6 +28,11 @@ const (
 type Factory struct {
 	nodeInfo   netutil.NodeInfo
 	searchDirs []string
+	// interpolated specifies the interpolated fields of the last loaded configuration
+	interpolated []string
+	// overridden specifies the fields of the last loaded configuration,
+	// overridden from the environment variables
+	overridden []string
 }
 
 // DefaultFactory returns default configuration factory
@@ -83,16 +89,49 @@ func (f *Factory) LoadConfigForHostName(configFile, hostnameOverride string) (*C
 		return nil, "", errors.Trace(err)
 	}
 
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+	unknown, err := UnknownFields(data)
+	if err != nil {
+		return nil, "", errors.Annotatef(err, "unable to parse %s", configFile)
+	}
+	if len(unknown) > 0 {
+		problems := make([]string, len(unknown))
+		for i, field := range unknown {
+			problems[i] = "unknown field: " + field
+		}
+		return nil, "", errors.Trace(&ValidationError{Problems: problems})
+	}
+
 	c, err := Load(configFile, envHostnameKey, hostnameOverride)
 	if err != nil {
 		return nil, "", errors.Trace(err)
 	}
 
+	//
+	// Override from DOLLY_ environment variables, and interpolate ${ENV:NAME} and ${FILE:/path}
+	//
+	overridden, err := c.OverrideFromEnv(EnvPrefix, os.LookupEnv)
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+	interpolated, err := c.Interpolate(baseDir, os.LookupEnv)
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+	f.interpolated = interpolated
+	f.overridden = overridden
+
 	//
 	// Substitude ENVIRONMENT
 	// Add to this list all configs that require ${NODENAME}, ${HOSTNAME} or ${LOCALIP} substitution
 	//
 	envVarsResove := []*string{
+		&c.HTTP.ServerTLS.CertFile,
+		&c.HTTP.ServerTLS.KeyFile,
+		&c.HTTP.ServerTLS.TrustedCAFile,
 		&c.HTTPS.ServerTLS.CertFile,
 		&c.HTTPS.ServerTLS.KeyFile,
 		&c.HTTPS.ServerTLS.TrustedCAFile,
@@ -111,6 +150,9 @@ func (f *Factory) LoadConfigForHostName(configFile, hostnameOverride string) (*C
 	}
 
 	filesToResove := []*string{
+		&c.HTTP.ServerTLS.CertFile,
+		&c.HTTP.ServerTLS.KeyFile,
+		&c.HTTP.ServerTLS.TrustedCAFile,
 		&c.HTTPS.ServerTLS.CertFile,
 		&c.HTTPS.ServerTLS.KeyFile,
 		&c.HTTPS.ServerTLS.TrustedCAFile,
@@ -118,6 +160,7 @@ func (f *Factory) LoadConfigForHostName(configFile, hostnameOverride string) (*C
 		&c.Authz.CertMapper,
 		&c.Authz.APIKeyMapper,
 		&c.Authz.JWTMapper,
+		&c.Audit.SigningKey,
 	}
 
 	optionalFilesToResove := []*string{
@@ -131,11 +174,9 @@ func (f *Factory) LoadConfigForHostName(configFile, hostnameOverride string) (*C
 		}
 	}
 
+	// missing files are reported by Validate
 	for _, ptr := range filesToResove {
-		*ptr, err = resolve.File(*ptr, baseDir)
-		if err != nil {
-			return nil, "", errors.Annotatef(err, "unable to resolve file: %s", *ptr)
-		}
+		*ptr, _ = resolve.File(*ptr, baseDir)
 	}
 
 	for _, ptr := range optionalFilesToResove {
@@ -143,7 +184,23 @@ func (f *Factory) LoadConfigForHostName(configFile, hostnameOverride string) (*C
 	}
 
 	c.Datacenter = strings.ToLower(c.Datacenter)
-	return c, configFile, err
+
+	if err = c.Validate(); err != nil {
+		return nil, "", errors.Trace(err)
+	}
+	return c, configFile, nil
+}
+
+// Interpolated returns the fields of the last loaded configuration,
+// whose values are interpolated from ${ENV:NAME} and ${FILE:/path}
+func (f *Factory) Interpolated() []string {
+	return f.interpolated
+}
+
+// Overridden returns the fields of the last loaded configuration,
+// whose values are overridden from DOLLY_ environment variables
+func (f *Factory) Overridden() []string {
+	return f.overridden
 }
 
 // substitudeEnvVars replace ${HOSTNAME}, ${NODENAME} and ${LOCALIP} in input string
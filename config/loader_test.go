@@ -56,4 +56,18 @@ func Test_LoadConfig(t *testing.T) {
 	}
 	testDirAbs("HTTPS.ServerTLS.CertFile", c.HTTPS.ServerTLS.CertFile)
 	testDirAbs("HTTPS.ServerTLS.KeyFile", c.HTTPS.ServerTLS.KeyFile)
+
+	// the overridden fields are kept to be masked
+	os.Setenv("DOLLY_DATAHUB_DATASOURCE", "postgres://dolly:secret@db")
+	defer os.Unsetenv("DOLLY_DATAHUB_DATASOURCE")
+
+	f, err := DefaultFactory()
+	require.NoError(t, err)
+	c, _, err = f.LoadConfig(cfgFile)
+	require.NoError(t, err, "failed to load config: %v", cfgFile)
+	assert.Equal(t, "postgres://dolly:secret@db", c.Datahub.DataSource)
+	assert.Equal(t, []string{"Datahub.DataSource"}, f.Overridden())
+
+	c.Mask(f.Overridden())
+	assert.Equal(t, MaskedValue, c.Datahub.DataSource)
 }
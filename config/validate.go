@@ -0,0 +1,198 @@
+package config
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// ClientAuthTypes specifies the values of ClientCertAuth
+var ClientAuthTypes = map[string]tls.ClientAuthType{
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+// ValidationError provides the list of problems of the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+// Error returns the problems of the configuration
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(e.Problems, "; "))
+}
+
+// UnknownFields returns JSON paths of the keys in the configuration file,
+// which do not match any field, for example defaults.HTTPS.BindAdr
+func UnknownFields(data []byte) ([]string, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return unknownFields("", v, reflect.TypeOf(Configurations{})), nil
+}
+
+func unknownFields(path string, v interface{}, t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var list []string
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			switch t.Kind() {
+			case reflect.Struct:
+				f, ok := fieldByJSONName(t, k)
+				if !ok {
+					list = append(list, p)
+					continue
+				}
+				list = append(list, unknownFields(p, val[k], f.Type)...)
+			case reflect.Map:
+				list = append(list, unknownFields(p, val[k], t.Elem())...)
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, e := range val {
+				list = append(list, unknownFields(fmt.Sprintf("%s[%d]", path, i), e, t.Elem())...)
+			}
+		}
+	}
+	return list
+}
+
+// fieldByJSONName returns the field for the key,
+// the names are matched case-insensitive as by json.Unmarshal
+func fieldByJSONName(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Validate returns ValidationError with all problems of the configuration,
+// or nil if the configuration is valid.
+// The file locations must be resolved.
+func (c *Configuration) Validate() error {
+	v := new(validation)
+
+	for _, s := range c.Authz.Allow {
+		parts := strings.Split(s, ":")
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") || !validRoles(parts[1]) {
+			v.add("Authz.Allow: %q must be in format path:role,role", s)
+		}
+	}
+	v.paths("Authz.AllowAny", c.Authz.AllowAny)
+	v.paths("Authz.AllowAnyRole", c.Authz.AllowAnyRole)
+
+	for _, s := range []struct {
+		name string
+		cfg  *HTTPServer
+	}{
+		{"HTTP", &c.HTTP},
+		{"HTTPS", &c.HTTPS},
+	} {
+		tlsInfo := &s.cfg.ServerTLS
+		if tlsInfo.ClientCertAuth != "" {
+			if _, ok := ClientAuthTypes[tlsInfo.ClientCertAuth]; !ok {
+				v.add("%s.ServerTLS.ClientCertAuth: %q must be one of %s",
+					s.name, tlsInfo.ClientCertAuth, strings.Join(clientAuthNames(), "|"))
+			}
+		}
+		v.file(s.name+".ServerTLS.CertFile", tlsInfo.CertFile)
+		v.file(s.name+".ServerTLS.KeyFile", tlsInfo.KeyFile)
+		v.file(s.name+".ServerTLS.TrustedCAFile", tlsInfo.TrustedCAFile)
+	}
+
+	v.file("CryptoProv.Default", c.CryptoProv.Default)
+	v.file("Authz.CertMapper", c.Authz.CertMapper)
+	v.file("Authz.APIKeyMapper", c.Authz.APIKeyMapper)
+	v.file("Authz.JWTMapper", c.Authz.JWTMapper)
+	v.file("Audit.SigningKey", c.Audit.SigningKey)
+
+	return v.err()
+}
+
+// validation collects the problems of the configuration
+type validation struct {
+	problems []string
+}
+
+func (v *validation) add(format string, vals ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, vals...))
+}
+
+func (v *validation) paths(field string, list []string) {
+	for _, s := range list {
+		if !strings.HasPrefix(s, "/") {
+			v.add("%s: %q must be URI path", field, s)
+		}
+	}
+}
+
+func (v *validation) file(field, file string) {
+	if file == "" {
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		v.add("%s: file not found: %s", field, file)
+	}
+}
+
+func (v *validation) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+func validRoles(s string) bool {
+	for _, role := range strings.Split(s, ",") {
+		if strings.TrimSpace(role) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func clientAuthNames() []string {
+	names := make([]string, 0, len(ClientAuthTypes))
+	for name := range ClientAuthTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
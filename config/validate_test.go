@@ -0,0 +1,91 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_UnknownFields(t *testing.T) {
+	unknown, err := UnknownFields([]byte(`{
+		"defaults" : {
+			"ServiceName" : "dolly",
+			"https" : {
+				"BindAdr" : ":8443",
+				"ServerTLS" : { "CertFile" : "cert.pem", "ClientAuth" : "NoClientCert" }
+			},
+			"LogLevels" : [
+				{ "Repo" : "*", "Level" : "INFO" },
+				{ "Repo" : "log", "Levl" : "ERROR" }
+			],
+			"DataProtection" : {}
+		},
+		"hosts" : { "LOCAL" : "local" },
+		"overrides" : {
+			"local" : {
+				"Metrics" : { "Provider" : "statsd", "Adress" : "127.0.0.1:8125" }
+			}
+		}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"defaults.DataProtection",
+		"defaults.LogLevels[1].Levl",
+		"defaults.https.BindAdr",
+		"defaults.https.ServerTLS.ClientAuth",
+		"overrides.local.Metrics.Adress",
+	}, unknown)
+
+	_, err = UnknownFields([]byte(`{`))
+	assert.Error(t, err)
+
+	data, err := ioutil.ReadFile(filepath.Join(projFolder, "etc/dev", ConfigFileName))
+	require.NoError(t, err)
+	unknown, err = UnknownFields(data)
+	require.NoError(t, err)
+	assert.Empty(t, unknown)
+}
+
+func Test_Validate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	roles := filepath.Join(dir, "roles.yaml")
+	require.NoError(t, ioutil.WriteFile(roles, []byte("{}"), 0644))
+
+	c := &Configuration{
+		Authz: Authz{
+			Allow:      []string{"/v1/teams:dolly-admin,dolly-peer"},
+			AllowAny:   []string{"/v1/status"},
+			CertMapper: roles,
+		},
+		HTTPS: HTTPServer{
+			ServerTLS: TLSInfo{ClientCertAuth: "RequireAndVerifyClientCert"},
+		},
+	}
+	require.NoError(t, c.Validate())
+
+	c.Authz.Allow = append(c.Authz.Allow, "/v1/team", "v1/user:dolly-admin", "/v1/membership:dolly-admin,")
+	c.Authz.AllowAnyRole = []string{"v1/users"}
+	c.HTTPS.ServerTLS.ClientCertAuth = "VerifyClientCert"
+	c.HTTPS.ServerTLS.CertFile = filepath.Join(dir, "missing.pem")
+
+	err = c.Validate()
+	require.Error(t, err)
+	verr, ok := errors.Cause(err).(*ValidationError)
+	require.True(t, ok)
+	assert.Equal(t, []string{
+		`Authz.Allow: "/v1/team" must be in format path:role,role`,
+		`Authz.Allow: "v1/user:dolly-admin" must be in format path:role,role`,
+		`Authz.Allow: "/v1/membership:dolly-admin," must be in format path:role,role`,
+		`Authz.AllowAnyRole: "v1/users" must be URI path`,
+		`HTTPS.ServerTLS.ClientCertAuth: "VerifyClientCert" must be one of NoClientCert|RequestClientCert|RequireAndVerifyClientCert|RequireAnyClientCert|VerifyClientCertIfGiven`,
+		`HTTPS.ServerTLS.CertFile: file not found: ` + filepath.Join(dir, "missing.pem"),
+	}, verr.Problems)
+}
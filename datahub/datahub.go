@@ -2,17 +2,84 @@ package datahub
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-phorce/dolly-test/api/v1"
 )
 
+// Sort fields supported by UsersManager,
+// the items are ordered by ID within the same sort value
+const (
+	SortByName  = "name"
+	SortByEmail = "email"
+	SortByAge   = "age"
+)
+
 // UsersManager interface provides sample user management API
 type UsersManager interface {
-	ListTeams(ctx context.Context) (*v1.ListTeamsResponse, error)
+	ListTeams(ctx context.Context, req *v1.ListTeamsRequest) (*v1.ListTeamsResponse, error)
+	CreateTeam(ctx context.Context, req *v1.CreateTeamRequest) (*v1.Team, error)
+	UpdateTeam(ctx context.Context, req *v1.UpdateTeamRequest) (*v1.Team, error)
+	DeleteTeam(ctx context.Context, teamID string) error
+
 	FindUser(ctx context.Context, req *v1.FindUserRequest) (*v1.FindUserResponse, error)
+	// GetUser returns the user, specified by ID or email
+	GetUser(ctx context.Context, user string) (*v1.User, error)
+	// RecordLogin increments the login count and sets the last login time of the user
+	RecordLogin(ctx context.Context, userID string) (*v1.User, error)
+	CreateUser(ctx context.Context, req *v1.CreateUserRequest) (*v1.User, error)
+	UpdateUser(ctx context.Context, req *v1.UpdateUserRequest) (*v1.User, error)
+	DeleteUser(ctx context.Context, userID string) error
+
+	AddMembership(ctx context.Context, req *v1.AddMembershipRequest) (*v1.TeamMembership, error)
+	UpdateMembership(ctx context.Context, req *v1.UpdateMembershipRequest) (*v1.TeamMembership, error)
+	DeleteMembership(ctx context.Context, membershipID string) error
+	// GetUserMemberships returns memberships of the user,
+	// specified by ID or email, joined with team and user details
+	GetUserMemberships(ctx context.Context, user string) ([]*v1.TeamMemberInfo, error)
+}
+
+// RevocationsManager interface provides revocation list of issued tokens
+type RevocationsManager interface {
+	// RevokeTokens adds the revocation, and returns it with assigned ID
+	RevokeTokens(ctx context.Context, r *v1.Revocation) (*v1.Revocation, error)
+	// ListRevocations returns the revocations ordered by RevokedAt
+	ListRevocations(ctx context.Context) ([]*v1.Revocation, error)
+	// IsTokenRevoked returns true if the token issued at the time
+	// is revoked by its ID, device or user
+	IsTokenRevoked(ctx context.Context, tokenID, deviceID, user string, issuedAt time.Time) (bool, error)
+	// PurgeRevocations deletes the revocations expired before the time,
+	// and returns the number of deleted revocations
+	PurgeRevocations(ctx context.Context, before time.Time) (int, error)
+}
+
+// APIKeysManager interface provides API keys issued at runtime,
+// only the hash of the key's secret is stored
+type APIKeysManager interface {
+	// CreateAPIKey stores the key with the hash of its secret,
+	// and returns it with assigned ID
+	CreateAPIKey(ctx context.Context, key *v1.APIKey, hash string) (*v1.APIKey, error)
+	// GetAPIKey returns the key by ID
+	GetAPIKey(ctx context.Context, id string) (*v1.APIKey, error)
+	// FindAPIKey returns the key by the hash of its secret
+	FindAPIKey(ctx context.Context, hash string) (*v1.APIKey, error)
+	// ListAPIKeys returns the keys of the owner, or all keys if the owner is empty,
+	// ordered by CreatedAt
+	ListAPIKeys(ctx context.Context, owner string) ([]*v1.APIKey, error)
+	// RotateAPIKey replaces the prefix and the hash of the key's secret
+	RotateAPIKey(ctx context.Context, id, prefix, hash string) (*v1.APIKey, error)
+	// RevokeAPIKey sets the revocation time of the key
+	RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) (*v1.APIKey, error)
+	// TouchAPIKey sets the last used time of the key
+	TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error
 }
 
 // Datahub defines an interface to work with data storage
 type Datahub interface {
 	UsersManager
+	RevocationsManager
+	APIKeysManager
+
+	// Ping verifies that the storage is reachable
+	Ping(ctx context.Context) error
 }
@@ -0,0 +1,180 @@
+// Package datahubtest provides conformance tests of datahub providers
+package datahubtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the conformance tests of API keys and revocations
+// against the empty datahub
+func Run(t *testing.T, db datahub.Datahub) {
+	t.Run("APIKeys", func(t *testing.T) {
+		apiKeys(t, db)
+	})
+	t.Run("Revocations", func(t *testing.T) {
+		revocations(t, db)
+	})
+}
+
+func apiKeys(t *testing.T, db datahub.APIKeysManager) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	expires := now.Add(time.Hour)
+
+	_, err := db.CreateAPIKey(ctx, &v1.APIKey{Owner: "ci"}, "H1")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+
+	k1, err := db.CreateAPIKey(ctx, &v1.APIKey{
+		Prefix:    "p1",
+		Owner:     "ci",
+		Role:      "dolly-client",
+		Scopes:    []string{"/v1/teams", "/v1/users"},
+		CreatedAt: now,
+		ExpiresAt: &expires,
+	}, "H1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, k1.ID)
+	assert.Equal(t, []string{"/v1/teams", "/v1/users"}, k1.Scopes)
+	assert.Equal(t, now, k1.CreatedAt)
+	assert.Equal(t, expires, *k1.ExpiresAt)
+	assert.Nil(t, k1.LastUsedAt)
+	assert.Nil(t, k1.RevokedAt)
+
+	_, err = db.CreateAPIKey(ctx, &v1.APIKey{Prefix: "p1", Owner: "ci", Role: "dolly-client", CreatedAt: now}, "H1")
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	k2, err := db.CreateAPIKey(ctx, &v1.APIKey{Prefix: "p2", Owner: "deploy", Role: "dolly-admin", CreatedAt: now}, "H2")
+	require.NoError(t, err)
+	assert.Nil(t, k2.Scopes)
+	assert.Nil(t, k2.ExpiresAt)
+
+	found, err := db.FindAPIKey(ctx, "H1")
+	require.NoError(t, err)
+	assert.Equal(t, *k1, *found)
+
+	_, err = db.FindAPIKey(ctx, "H3")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	list, err := db.ListAPIKeys(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, k1.ID, list[0].ID)
+	assert.Equal(t, k2.ID, list[1].ID)
+
+	list, err = db.ListAPIKeys(ctx, "deploy")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, k2.ID, list[0].ID)
+
+	require.NoError(t, db.TouchAPIKey(ctx, k1.ID, now))
+	err = db.TouchAPIKey(ctx, "missing", now)
+	assert.True(t, errors.IsNotFound(err))
+
+	rotated, err := db.RotateAPIKey(ctx, k1.ID, "p3", "H3")
+	require.NoError(t, err)
+	assert.Equal(t, "p3", rotated.Prefix)
+	assert.Equal(t, now, *rotated.LastUsedAt)
+
+	_, err = db.FindAPIKey(ctx, "H1")
+	assert.True(t, errors.IsNotFound(err))
+	found, err = db.FindAPIKey(ctx, "H3")
+	require.NoError(t, err)
+	assert.Equal(t, k1.ID, found.ID)
+
+	_, err = db.RotateAPIKey(ctx, k1.ID, "p2", "H2")
+	assert.True(t, errors.IsAlreadyExists(err))
+	_, err = db.RotateAPIKey(ctx, "missing", "p4", "H4")
+	assert.True(t, errors.IsNotFound(err))
+
+	revoked, err := db.RevokeAPIKey(ctx, k1.ID, now)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	assert.Equal(t, now, *revoked.RevokedAt)
+
+	// the first revocation time is kept
+	revoked, err = db.RevokeAPIKey(ctx, k1.ID, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, now, *revoked.RevokedAt)
+
+	_, err = db.RotateAPIKey(ctx, k1.ID, "p4", "H4")
+	assert.True(t, errors.IsNotValid(err))
+
+	_, err = db.RevokeAPIKey(ctx, "missing", now)
+	assert.True(t, errors.IsNotFound(err))
+	_, err = db.GetAPIKey(ctx, "missing")
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func revocations(t *testing.T, db datahub.RevocationsManager) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	issued := now.Add(-time.Minute)
+
+	list, err := db.ListRevocations(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	revoked, err := db.IsTokenRevoked(ctx, "t1", "device1", "denis@ekspand.com", issued)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	byToken, err := db.RevokeTokens(ctx, &v1.Revocation{TokenID: "t1", RevokedAt: now, ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	assert.NotEmpty(t, byToken.ID)
+
+	_, err = db.RevokeTokens(ctx, &v1.Revocation{DeviceID: "device2", Reason: "lost", RevokedAt: now, ExpiresAt: now.Add(2 * time.Hour)})
+	require.NoError(t, err)
+	_, err = db.RevokeTokens(ctx, &v1.Revocation{User: "andrew@ekspand.com", RevokedAt: now.Add(time.Second), ExpiresAt: now.Add(-time.Second)})
+	require.NoError(t, err)
+
+	tcases := []struct {
+		tokenID, deviceID, user string
+		issuedAt                time.Time
+		revoked                 bool
+	}{
+		{"t1", "device1", "denis@ekspand.com", issued, true},
+		{"t2", "device1", "denis@ekspand.com", issued, false},
+		{"t2", "device2", "denis@ekspand.com", issued, true},
+		{"t2", "device2", "denis@ekspand.com", now, true},
+		// issued after revocation
+		{"t2", "device2", "denis@ekspand.com", now.Add(time.Second), false},
+		{"t2", "", "andrew@ekspand.com", issued, true},
+		{"t2", "", "Andrew@ekspand.com", issued, true},
+		{"", "", "", issued, false},
+	}
+	for _, tc := range tcases {
+		revoked, err := db.IsTokenRevoked(ctx, tc.tokenID, tc.deviceID, tc.user, tc.issuedAt)
+		require.NoError(t, err)
+		assert.Equal(t, tc.revoked, revoked, "%+v", tc)
+	}
+
+	list, err = db.ListRevocations(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	assert.Equal(t, *byToken, *list[0])
+	assert.Equal(t, "lost", list[1].Reason)
+
+	purged, err := db.PurgeRevocations(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	purged, err = db.PurgeRevocations(ctx, now.Add(time.Hour+time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	list, err = db.ListRevocations(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "device2", list[0].DeviceID)
+}
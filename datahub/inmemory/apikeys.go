@@ -0,0 +1,163 @@
+package inmemory
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/algorithms/guid"
+	"github.com/juju/errors"
+)
+
+// apiKey specifies the key with the hash of its secret
+type apiKey struct {
+	key  v1.APIKey
+	hash string
+}
+
+func (p *inmem) CreateAPIKey(ctx context.Context, key *v1.APIKey, hash string) (*v1.APIKey, error) {
+	if key.Owner == "" || key.Role == "" || hash == "" {
+		return nil, errors.NotValidf("API key")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.apiKeyByHash(hash) != nil {
+		return nil, errors.AlreadyExistsf("API key")
+	}
+
+	k := &apiKey{key: *key, hash: hash}
+	k.key.ID = guid.MustCreate()
+	k.key.CreatedAt = key.CreatedAt.Truncate(time.Second).UTC()
+	if key.ExpiresAt != nil {
+		t := key.ExpiresAt.Truncate(time.Second).UTC()
+		k.key.ExpiresAt = &t
+	}
+	p.apiKeys = append(p.apiKeys, k)
+
+	return copyAPIKey(k), nil
+}
+
+func (p *inmem) GetAPIKey(ctx context.Context, id string) (*v1.APIKey, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	k := p.apiKeyByID(id)
+	if k == nil {
+		return nil, errors.NotFoundf("API key %q", id)
+	}
+	return copyAPIKey(k), nil
+}
+
+func (p *inmem) FindAPIKey(ctx context.Context, hash string) (*v1.APIKey, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	k := p.apiKeyByHash(hash)
+	if k == nil {
+		return nil, errors.NotFoundf("API key")
+	}
+	return copyAPIKey(k), nil
+}
+
+func (p *inmem) ListAPIKeys(ctx context.Context, owner string) ([]*v1.APIKey, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	// keys are appended in the order of CreatedAt
+	list := []*v1.APIKey{}
+	for _, k := range p.apiKeys {
+		if owner == "" || k.key.Owner == owner {
+			list = append(list, copyAPIKey(k))
+		}
+	}
+	return list, nil
+}
+
+func (p *inmem) RotateAPIKey(ctx context.Context, id, prefix, hash string) (*v1.APIKey, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	k := p.apiKeyByID(id)
+	if k == nil {
+		return nil, errors.NotFoundf("API key %q", id)
+	}
+	if k.key.RevokedAt != nil {
+		return nil, errors.NotValidf("revoked API key %q", id)
+	}
+	if p.apiKeyByHash(hash) != nil {
+		return nil, errors.AlreadyExistsf("API key")
+	}
+
+	k.key.Prefix = prefix
+	k.hash = hash
+	return copyAPIKey(k), nil
+}
+
+func (p *inmem) RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) (*v1.APIKey, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	k := p.apiKeyByID(id)
+	if k == nil {
+		return nil, errors.NotFoundf("API key %q", id)
+	}
+	if k.key.RevokedAt == nil {
+		t := revokedAt.Truncate(time.Second).UTC()
+		k.key.RevokedAt = &t
+	}
+	return copyAPIKey(k), nil
+}
+
+func (p *inmem) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	k := p.apiKeyByID(id)
+	if k == nil {
+		return errors.NotFoundf("API key %q", id)
+	}
+	t := usedAt.Truncate(time.Second).UTC()
+	k.key.LastUsedAt = &t
+	return nil
+}
+
+// apiKeyByID returns the key with the ID, or nil if not found;
+// the caller must hold the lock
+func (p *inmem) apiKeyByID(id string) *apiKey {
+	for _, k := range p.apiKeys {
+		if k.key.ID == id {
+			return k
+		}
+	}
+	return nil
+}
+
+// apiKeyByHash returns the key with the hash, or nil if not found;
+// the caller must hold the lock
+func (p *inmem) apiKeyByHash(hash string) *apiKey {
+	for _, k := range p.apiKeys {
+		if k.hash == hash {
+			return k
+		}
+	}
+	return nil
+}
+
+// copyAPIKey returns a copy of the key, which can be modified by the caller
+func copyAPIKey(k *apiKey) *v1.APIKey {
+	key := k.key
+	key.Scopes = append([]string(nil), k.key.Scopes...)
+	copyTime := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		c := *t
+		return &c
+	}
+	key.ExpiresAt = copyTime(k.key.ExpiresAt)
+	key.LastUsedAt = copyTime(k.key.LastUsedAt)
+	key.RevokedAt = copyTime(k.key.RevokedAt)
+	return &key
+}
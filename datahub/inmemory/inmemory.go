@@ -2,46 +2,231 @@ package inmemory
 
 import (
 	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-phorce/dolly-test/api/v1"
 	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/go-phorce/dolly/algorithms/guid"
+	"github.com/juju/errors"
 )
 
 type inmem struct {
-	teams []string
-	users []v1.User
+	lock        sync.RWMutex
+	teams       []*v1.Team
+	users       []*v1.User
+	memberships []*v1.TeamMembership
+	revocations []*v1.Revocation
+	apiKeys     []*apiKey
 }
 
 // NewUsersManager returns in-memory UsersManager
 func NewUsersManager() (datahub.UsersManager, error) {
+	return New()
+}
+
+// New returns in-memory Datahub
+func New() (datahub.Datahub, error) {
+	lastLogin := func(value string) *time.Time {
+		t, _ := time.Parse(time.RFC3339, value)
+		return &t
+	}
+
 	p := &inmem{
-		teams: []string{"admins", "users"},
-		users: []v1.User{
-			{ID: "a001", Name: "denis", Email: "denis@ekspand.com", Age: 33},
-			{ID: "a002", Name: "andrew", Email: "andrew@ekspand.com", Age: 43},
-			{ID: "a003", Name: "hayk", Email: "hayk@ekspand.com", Age: 27},
+		teams: []*v1.Team{
+			{ID: "t001", Name: "admins"},
+			{ID: "t002", Name: "users"},
+		},
+		users: []*v1.User{
+			{ID: "a001", Name: "denis", Email: "denis@ekspand.com", Age: 33, LoginCount: 12, LastLoginAt: lastLogin("2019-03-01T10:00:00Z")},
+			{ID: "a002", Name: "andrew", Email: "andrew@ekspand.com", Age: 43, LoginCount: 3, LastLoginAt: lastLogin("2019-02-15T08:30:00Z")},
+			{ID: "a003", Name: "hayk", Email: "hayk@ekspand.com", Age: 27, LoginCount: 1, LastLoginAt: lastLogin("2019-01-20T17:45:00Z")},
 			{ID: "a004", Name: "daniel", Email: "daniel@ekspand.com", Age: 14},
 		},
+		memberships: []*v1.TeamMembership{
+			{ID: "m001", TeamID: "t001", Team: "admins", UserID: "a001", Role: "owner"},
+			{ID: "m002", TeamID: "t002", Team: "users", UserID: "a002", Role: "member"},
+			{ID: "m003", TeamID: "t002", Team: "users", UserID: "a003", Role: "member"},
+			{ID: "m004", TeamID: "t002", Team: "users", UserID: "a004", Role: "member"},
+		},
 	}
 	return p, nil
 }
 
-func (p *inmem) ListTeams(ctx context.Context) (*v1.ListTeamsResponse, error) {
+// Ping verifies that the storage is reachable
+func (p *inmem) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (p *inmem) ListTeams(ctx context.Context, req *v1.ListTeamsRequest) (*v1.ListTeamsResponse, error) {
+	order, err := datahub.ParseSort(req.Sort, datahub.SortByName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	limit, err := datahub.PageLimit(req.Limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	after, err := cursorKey(req.Cursor, order)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	keys := make([]sortKey, len(p.teams))
+	for idx, t := range p.teams {
+		keys[idx] = sortKey{value: t.Name, id: t.ID}
+	}
+
+	list, more := paginate(keys, order, after, limit)
+
 	res := &v1.ListTeamsResponse{
-		Teams: p.teams,
+		Teams: make([]string, len(list)),
+		Items: make([]*v1.Team, len(list)),
+	}
+	for i, idx := range list {
+		team := *p.teams[idx]
+		res.Teams[i] = team.Name
+		res.Items[i] = &team
+	}
+	if more {
+		res.NextCursor = keys[list[len(list)-1]].cursor(order)
 	}
 	return res, nil
 }
 
+func (p *inmem) CreateTeam(ctx context.Context, req *v1.CreateTeamRequest) (*v1.Team, error) {
+	if req.Name == "" {
+		return nil, errors.NotValidf("team name")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.teamByName(req.Name) != nil {
+		return nil, errors.AlreadyExistsf("team %q", req.Name)
+	}
+
+	t := &v1.Team{
+		ID:          guid.MustCreate(),
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	p.teams = append(p.teams, t)
+
+	team := *t
+	return &team, nil
+}
+
+func (p *inmem) UpdateTeam(ctx context.Context, req *v1.UpdateTeamRequest) (*v1.Team, error) {
+	if req.Name == "" {
+		return nil, errors.NotValidf("team name")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	idx := p.teamIndex(req.ID)
+	if idx < 0 {
+		return nil, errors.NotFoundf("team %q", req.ID)
+	}
+	if existing := p.teamByName(req.Name); existing != nil && existing.ID != req.ID {
+		return nil, errors.AlreadyExistsf("team %q", req.Name)
+	}
+
+	t := p.teams[idx]
+	t.Name = req.Name
+	t.Description = req.Description
+
+	// keep denormalized team name in memberships
+	for _, m := range p.memberships {
+		if m.TeamID == t.ID {
+			m.Team = t.Name
+		}
+	}
+
+	team := *t
+	return &team, nil
+}
+
+func (p *inmem) DeleteTeam(ctx context.Context, teamID string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	idx := p.teamIndex(teamID)
+	if idx < 0 {
+		return errors.NotFoundf("team %q", teamID)
+	}
+	p.teams = append(p.teams[:idx], p.teams[idx+1:]...)
+
+	p.deleteMemberships(func(m *v1.TeamMembership) bool {
+		return m.TeamID == teamID
+	})
+	return nil
+}
+
 func (p *inmem) FindUser(ctx context.Context, req *v1.FindUserRequest) (*v1.FindUserResponse, error) {
+	order, err := datahub.ParseSort(req.Sort, datahub.SortByName, datahub.SortByEmail, datahub.SortByAge)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	limit, err := datahub.PageLimit(req.Limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	after, err := cursorKey(req.Cursor, order)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	var members map[string]bool
+	if req.TeamID != "" {
+		members = map[string]bool{}
+		for _, m := range p.memberships {
+			if m.TeamID == req.TeamID {
+				members[m.UserID] = true
+			}
+		}
+	}
+
 	users := make([]*v1.User, 0, len(p.users))
+	keys := make([]sortKey, 0, len(p.users))
 
-	for idx, u := range p.users {
+	for _, u := range p.users {
 		if req.Name != "" && u.Name != req.Name {
 			// name does not match
 			continue
 		}
 
+		if !matchText(u.Name, req.NamePrefix, req.NameContains) ||
+			!matchText(u.Email, req.EmailPrefix, req.EmailContains) {
+			// name or email does not match
+			continue
+		}
+
+		if members != nil && !members[u.ID] {
+			// not a member of the team
+			continue
+		}
+
+		if req.LastLoginAfter != nil && (u.LastLoginAt == nil || u.LastLoginAt.Before(*req.LastLoginAfter)) {
+			// last login does not match
+			continue
+		}
+
+		if req.LastLoginBefore != nil && (u.LastLoginAt == nil || !u.LastLoginAt.Before(*req.LastLoginBefore)) {
+			// last login does not match
+			continue
+		}
+
 		if req.MinAge > 0 && u.Age < req.MinAge {
 			// age does not match
 			continue
@@ -52,12 +237,392 @@ func (p *inmem) FindUser(ctx context.Context, req *v1.FindUserRequest) (*v1.Find
 			continue
 		}
 
-		users = append(users, &p.users[idx])
+		users = append(users, u)
+		keys = append(keys, userSortKey(u, order.Field))
 	}
 
+	list, more := paginate(keys, order, after, limit)
+
 	res := &v1.FindUserResponse{
-		Users: users,
+		Users: make([]*v1.User, len(list)),
+	}
+	for i, idx := range list {
+		user := *users[idx]
+		res.Users[i] = &user
+	}
+	if more {
+		res.NextCursor = keys[list[len(list)-1]].cursor(order)
 	}
 
 	return res, nil
 }
+
+func (p *inmem) GetUser(ctx context.Context, user string) (*v1.User, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	u := p.userByIDOrEmail(user)
+	if u == nil {
+		return nil, errors.NotFoundf("user %q", user)
+	}
+
+	res := *u
+	return &res, nil
+}
+
+func (p *inmem) RecordLogin(ctx context.Context, userID string) (*v1.User, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	idx := p.userIndex(userID)
+	if idx < 0 {
+		return nil, errors.NotFoundf("user %q", userID)
+	}
+
+	now := time.Now().UTC()
+	u := p.users[idx]
+	u.LoginCount++
+	u.LastLoginAt = &now
+
+	user := *u
+	return &user, nil
+}
+
+func (p *inmem) CreateUser(ctx context.Context, req *v1.CreateUserRequest) (*v1.User, error) {
+	if req.Name == "" {
+		return nil, errors.NotValidf("user name")
+	}
+	if req.Email == "" {
+		return nil, errors.NotValidf("user email")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.userByEmail(req.Email) != nil {
+		return nil, errors.AlreadyExistsf("user with email %q", req.Email)
+	}
+
+	u := &v1.User{
+		ID:    guid.MustCreate(),
+		Name:  req.Name,
+		Email: req.Email,
+		Age:   req.Age,
+	}
+	p.users = append(p.users, u)
+
+	user := *u
+	return &user, nil
+}
+
+func (p *inmem) UpdateUser(ctx context.Context, req *v1.UpdateUserRequest) (*v1.User, error) {
+	if req.Name == "" {
+		return nil, errors.NotValidf("user name")
+	}
+	if req.Email == "" {
+		return nil, errors.NotValidf("user email")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	idx := p.userIndex(req.ID)
+	if idx < 0 {
+		return nil, errors.NotFoundf("user %q", req.ID)
+	}
+	if existing := p.userByEmail(req.Email); existing != nil && existing.ID != req.ID {
+		return nil, errors.AlreadyExistsf("user with email %q", req.Email)
+	}
+
+	u := p.users[idx]
+	u.Name = req.Name
+	u.Email = req.Email
+	u.Age = req.Age
+
+	user := *u
+	return &user, nil
+}
+
+func (p *inmem) DeleteUser(ctx context.Context, userID string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	idx := p.userIndex(userID)
+	if idx < 0 {
+		return errors.NotFoundf("user %q", userID)
+	}
+	p.users = append(p.users[:idx], p.users[idx+1:]...)
+
+	p.deleteMemberships(func(m *v1.TeamMembership) bool {
+		return m.UserID == userID
+	})
+	return nil
+}
+
+func (p *inmem) AddMembership(ctx context.Context, req *v1.AddMembershipRequest) (*v1.TeamMembership, error) {
+	if req.Role == "" {
+		return nil, errors.NotValidf("membership role")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	teamIdx := p.teamIndex(req.TeamID)
+	if teamIdx < 0 {
+		return nil, errors.NotFoundf("team %q", req.TeamID)
+	}
+	if p.userIndex(req.UserID) < 0 {
+		return nil, errors.NotFoundf("user %q", req.UserID)
+	}
+	for _, m := range p.memberships {
+		if m.TeamID == req.TeamID && m.UserID == req.UserID {
+			return nil, errors.AlreadyExistsf("membership for user %q in team %q", req.UserID, req.TeamID)
+		}
+	}
+
+	m := &v1.TeamMembership{
+		ID:     guid.MustCreate(),
+		TeamID: req.TeamID,
+		Team:   p.teams[teamIdx].Name,
+		UserID: req.UserID,
+		Role:   req.Role,
+	}
+	p.memberships = append(p.memberships, m)
+
+	membership := *m
+	return &membership, nil
+}
+
+func (p *inmem) UpdateMembership(ctx context.Context, req *v1.UpdateMembershipRequest) (*v1.TeamMembership, error) {
+	if req.Role == "" {
+		return nil, errors.NotValidf("membership role")
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, m := range p.memberships {
+		if m.ID == req.ID {
+			m.Role = req.Role
+
+			membership := *m
+			return &membership, nil
+		}
+	}
+	return nil, errors.NotFoundf("membership %q", req.ID)
+}
+
+func (p *inmem) DeleteMembership(ctx context.Context, membershipID string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	count := p.deleteMemberships(func(m *v1.TeamMembership) bool {
+		return m.ID == membershipID
+	})
+	if count == 0 {
+		return errors.NotFoundf("membership %q", membershipID)
+	}
+	return nil
+}
+
+func (p *inmem) GetUserMemberships(ctx context.Context, user string) ([]*v1.TeamMemberInfo, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	u := p.userByIDOrEmail(user)
+	if u == nil {
+		return nil, errors.NotFoundf("user %q", user)
+	}
+
+	list := []*v1.TeamMemberInfo{}
+	for _, m := range p.memberships {
+		if m.UserID != u.ID {
+			continue
+		}
+		list = append(list, &v1.TeamMemberInfo{
+			MembershipID: m.ID,
+			TeamID:       m.TeamID,
+			Team:         m.Team,
+			UserID:       u.ID,
+			Role:         m.Role,
+			Name:         u.Name,
+			Email:        u.Email,
+			Age:          u.Age,
+		})
+	}
+	return list, nil
+}
+
+// teamIndex returns the index of the team, or -1 if not found;
+// the caller must hold the lock
+func (p *inmem) teamIndex(id string) int {
+	for idx, t := range p.teams {
+		if t.ID == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+// teamByName returns the team with the name, or nil if not found;
+// the caller must hold the lock
+func (p *inmem) teamByName(name string) *v1.Team {
+	for _, t := range p.teams {
+		if strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// userIndex returns the index of the user, or -1 if not found;
+// the caller must hold the lock
+func (p *inmem) userIndex(id string) int {
+	for idx, u := range p.users {
+		if u.ID == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+// userByEmail returns the user with the email, or nil if not found;
+// the caller must hold the lock
+func (p *inmem) userByEmail(email string) *v1.User {
+	for _, u := range p.users {
+		if strings.EqualFold(u.Email, email) {
+			return u
+		}
+	}
+	return nil
+}
+
+// userByIDOrEmail returns the user with the ID or email, or nil if not found;
+// the caller must hold the lock
+func (p *inmem) userByIDOrEmail(user string) *v1.User {
+	if idx := p.userIndex(user); idx >= 0 {
+		return p.users[idx]
+	}
+	return p.userByEmail(user)
+}
+
+// deleteMemberships removes memberships that match the filter,
+// and returns the number of removed items; the caller must hold the lock
+func (p *inmem) deleteMemberships(match func(m *v1.TeamMembership) bool) int {
+	list := p.memberships[:0]
+	for _, m := range p.memberships {
+		if !match(m) {
+			list = append(list, m)
+		}
+	}
+	count := len(p.memberships) - len(list)
+	p.memberships = list
+	return count
+}
+
+// matchText returns true if the value has the prefix and contains the substring,
+// case-insensitive; empty prefix or substring match any value
+func matchText(value, prefix, substr string) bool {
+	value = strings.ToLower(value)
+	return strings.HasPrefix(value, strings.ToLower(prefix)) &&
+		strings.Contains(value, strings.ToLower(substr))
+}
+
+// sortKey specifies the sort value and ID of an item
+type sortKey struct {
+	// value is string or int
+	value interface{}
+	id    string
+}
+
+// compare returns -1, 0 or 1 if the key is less, equal or greater than the other
+func (k sortKey) compare(other sortKey) int {
+	var c int
+	switch v := k.value.(type) {
+	case int:
+		o := other.value.(int)
+		if v < o {
+			c = -1
+		} else if v > o {
+			c = 1
+		}
+	case string:
+		c = strings.Compare(v, other.value.(string))
+	}
+	if c == 0 {
+		c = strings.Compare(k.id, other.id)
+	}
+	return c
+}
+
+// cursor returns the cursor that points to the item
+func (k sortKey) cursor(order *datahub.SortOrder) string {
+	c := &datahub.Cursor{
+		Sort: order.String(),
+		ID:   k.id,
+	}
+	switch v := k.value.(type) {
+	case int:
+		c.Value = strconv.Itoa(v)
+	case string:
+		c.Value = v
+	}
+	return c.Encode()
+}
+
+// cursorKey returns the key the cursor points to, or nil if the cursor is empty
+func cursorKey(cursor string, order *datahub.SortOrder) (*sortKey, error) {
+	c, err := datahub.DecodeCursor(cursor, order)
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	k := &sortKey{value: c.Value, id: c.ID}
+	if order.Field == datahub.SortByAge {
+		age, err := strconv.Atoi(c.Value)
+		if err != nil {
+			return nil, errors.NotValidf("cursor")
+		}
+		k.value = age
+	}
+	return k, nil
+}
+
+// userSortKey returns the sort key of the user
+func userSortKey(u *v1.User, field string) sortKey {
+	k := sortKey{id: u.ID}
+	switch field {
+	case datahub.SortByEmail:
+		k.value = u.Email
+	case datahub.SortByAge:
+		k.value = u.Age
+	default:
+		k.value = u.Name
+	}
+	return k
+}
+
+// paginate sorts the keys in the order, and returns indexes of the keys
+// in the page that starts after the cursor, and true if more items are available
+func paginate(keys []sortKey, order *datahub.SortOrder, after *sortKey, limit int) ([]int, bool) {
+	sign := 1
+	if order.Desc {
+		sign = -1
+	}
+
+	list := make([]int, 0, len(keys))
+	for idx := range keys {
+		if after == nil || keys[idx].compare(*after)*sign > 0 {
+			list = append(list, idx)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return keys[list[i]].compare(keys[list[j]])*sign < 0
+	})
+
+	if len(list) > limit {
+		return list[:limit], true
+	}
+	return list, false
+}
@@ -0,0 +1,306 @@
+package inmemory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub/datahubtest"
+	"github.com/go-phorce/dolly-test/datahub/inmemory"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Datahub(t *testing.T) {
+	db, err := inmemory.New()
+	require.NoError(t, err)
+	datahubtest.Run(t, db)
+}
+
+func Test_Teams(t *testing.T) {
+	ctx := context.Background()
+	db, err := inmemory.NewUsersManager()
+	require.NoError(t, err)
+
+	list, err := db.ListTeams(ctx, &v1.ListTeamsRequest{})
+	require.NoError(t, err)
+	count := len(list.Teams)
+
+	_, err = db.CreateTeam(ctx, &v1.CreateTeamRequest{})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+
+	_, err = db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "admins"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	team, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "devs", Description: "developers"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, team.ID)
+	assert.Equal(t, "devs", team.Name)
+
+	list, err = db.ListTeams(ctx, &v1.ListTeamsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.Teams, count+1)
+
+	team, err = db.UpdateTeam(ctx, &v1.UpdateTeamRequest{ID: team.ID, Name: "developers"})
+	require.NoError(t, err)
+	assert.Equal(t, "developers", team.Name)
+	assert.Empty(t, team.Description)
+
+	_, err = db.UpdateTeam(ctx, &v1.UpdateTeamRequest{ID: team.ID, Name: "users"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	_, err = db.UpdateTeam(ctx, &v1.UpdateTeamRequest{ID: "missing", Name: "missing"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	require.NoError(t, db.DeleteTeam(ctx, team.ID))
+	err = db.DeleteTeam(ctx, team.ID)
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	list, err = db.ListTeams(ctx, &v1.ListTeamsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.Teams, count)
+}
+
+func Test_Users(t *testing.T) {
+	ctx := context.Background()
+	db, err := inmemory.NewUsersManager()
+	require.NoError(t, err)
+
+	_, err = db.CreateUser(ctx, &v1.CreateUserRequest{Name: "john"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+
+	_, err = db.CreateUser(ctx, &v1.CreateUserRequest{Name: "denis", Email: "DENIS@ekspand.com"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	user, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "john", Email: "john@ekspand.com", Age: 21})
+	require.NoError(t, err)
+	assert.NotEmpty(t, user.ID)
+
+	res, err := db.FindUser(ctx, &v1.FindUserRequest{Name: "john"})
+	require.NoError(t, err)
+	require.Len(t, res.Users, 1)
+	assert.Equal(t, *user, *res.Users[0])
+
+	user, err = db.UpdateUser(ctx, &v1.UpdateUserRequest{ID: user.ID, Name: "johnny", Email: "john@ekspand.com", Age: 22})
+	require.NoError(t, err)
+	assert.Equal(t, "johnny", user.Name)
+	assert.Equal(t, 22, user.Age)
+
+	_, err = db.UpdateUser(ctx, &v1.UpdateUserRequest{ID: user.ID, Name: "johnny", Email: "hayk@ekspand.com"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	require.NoError(t, db.DeleteUser(ctx, user.ID))
+	err = db.DeleteUser(ctx, user.ID)
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	res, err = db.FindUser(ctx, &v1.FindUserRequest{Name: "johnny"})
+	require.NoError(t, err)
+	assert.Empty(t, res.Users)
+}
+
+func Test_Memberships(t *testing.T) {
+	ctx := context.Background()
+	db, err := inmemory.NewUsersManager()
+	require.NoError(t, err)
+
+	team, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "devs"})
+	require.NoError(t, err)
+
+	_, err = db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: "missing", UserID: "a001", Role: "member"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	_, err = db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: team.ID, UserID: "missing", Role: "member"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	m, err := db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: team.ID, UserID: "a001", Role: "member"})
+	require.NoError(t, err)
+	assert.Equal(t, "devs", m.Team)
+
+	_, err = db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: team.ID, UserID: "a001", Role: "owner"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	m, err = db.UpdateMembership(ctx, &v1.UpdateMembershipRequest{ID: m.ID, Role: "owner"})
+	require.NoError(t, err)
+	assert.Equal(t, "owner", m.Role)
+
+	require.NoError(t, db.DeleteMembership(ctx, m.ID))
+	err = db.DeleteMembership(ctx, m.ID)
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	t.Run("cascade", func(t *testing.T) {
+		m, err := db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: team.ID, UserID: "a002", Role: "member"})
+		require.NoError(t, err)
+
+		require.NoError(t, db.DeleteTeam(ctx, team.ID))
+
+		_, err = db.UpdateMembership(ctx, &v1.UpdateMembershipRequest{ID: m.ID, Role: "owner"})
+		require.Error(t, err)
+		assert.True(t, errors.IsNotFound(err))
+	})
+}
+
+func Test_GetUserMemberships(t *testing.T) {
+	ctx := context.Background()
+	db, err := inmemory.NewUsersManager()
+	require.NoError(t, err)
+
+	_, err = db.GetUserMemberships(ctx, "missing")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	list, err := db.GetUserMemberships(ctx, "a001")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, v1.TeamMemberInfo{
+		MembershipID: "m001",
+		TeamID:       "t001",
+		Team:         "admins",
+		UserID:       "a001",
+		Role:         "owner",
+		Name:         "denis",
+		Email:        "denis@ekspand.com",
+		Age:          33,
+	}, *list[0])
+
+	list2, err := db.GetUserMemberships(ctx, "DENIS@ekspand.com")
+	require.NoError(t, err)
+	assert.Equal(t, list, list2)
+
+	user, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "john", Email: "john@ekspand.com"})
+	require.NoError(t, err)
+
+	list, err = db.GetUserMemberships(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func Test_Paging(t *testing.T) {
+	ctx := context.Background()
+	db, err := inmemory.NewUsersManager()
+	require.NoError(t, err)
+
+	t.Run("users", func(t *testing.T) {
+		var names []string
+		req := &v1.FindUserRequest{Limit: 3, Sort: "-age"}
+		for {
+			res, err := db.FindUser(ctx, req)
+			require.NoError(t, err)
+			for _, u := range res.Users {
+				names = append(names, u.Name)
+			}
+			if res.NextCursor == "" {
+				break
+			}
+			req.Cursor = res.NextCursor
+		}
+		assert.Equal(t, []string{"andrew", "denis", "hayk", "daniel"}, names)
+
+		_, err := db.FindUser(ctx, &v1.FindUserRequest{Sort: "age", Cursor: req.Cursor})
+		require.Error(t, err)
+		assert.True(t, errors.IsNotValid(err))
+
+		_, err = db.FindUser(ctx, &v1.FindUserRequest{Sort: "login_count"})
+		require.Error(t, err)
+		assert.True(t, errors.IsNotValid(err))
+	})
+
+	t.Run("teams", func(t *testing.T) {
+		res, err := db.ListTeams(ctx, &v1.ListTeamsRequest{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, res.Items, 1)
+		assert.Equal(t, "admins", res.Items[0].Name)
+		assert.Equal(t, []string{"admins"}, res.Teams)
+		require.NotEmpty(t, res.NextCursor)
+
+		res, err = db.ListTeams(ctx, &v1.ListTeamsRequest{Limit: 1, Cursor: res.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, res.Teams, 1)
+		assert.Equal(t, "users", res.Items[0].Name)
+		assert.Empty(t, res.NextCursor)
+	})
+}
+
+func Test_FindUserFilters(t *testing.T) {
+	ctx := context.Background()
+	db, err := inmemory.NewUsersManager()
+	require.NoError(t, err)
+
+	at := func(value string) *time.Time {
+		t, _ := time.Parse(time.RFC3339, value)
+		return &t
+	}
+
+	tcases := []struct {
+		req   v1.FindUserRequest
+		names []string
+	}{
+		{req: v1.FindUserRequest{NamePrefix: "DA"}, names: []string{"daniel"}},
+		{req: v1.FindUserRequest{NameContains: "N"}, names: []string{"andrew", "daniel", "denis"}},
+		{req: v1.FindUserRequest{NamePrefix: "d", NameContains: "is"}, names: []string{"denis"}},
+		{req: v1.FindUserRequest{EmailPrefix: "Hayk@"}, names: []string{"hayk"}},
+		{req: v1.FindUserRequest{EmailContains: "@EKSPAND"}, names: []string{"andrew", "daniel", "denis", "hayk"}},
+		{req: v1.FindUserRequest{TeamID: "t002"}, names: []string{"andrew", "daniel", "hayk"}},
+		{req: v1.FindUserRequest{TeamID: "t002", NameContains: "a", MaxAge: 30}, names: []string{"daniel", "hayk"}},
+		{req: v1.FindUserRequest{TeamID: "missing"}, names: nil},
+		{req: v1.FindUserRequest{LastLoginAfter: at("2019-02-15T08:30:00Z")}, names: []string{"andrew", "denis"}},
+		{req: v1.FindUserRequest{LastLoginBefore: at("2019-02-15T08:30:00Z")}, names: []string{"hayk"}},
+		{
+			req:   v1.FindUserRequest{LastLoginAfter: at("2019-01-01T00:00:00Z"), LastLoginBefore: at("2019-03-01T00:00:00Z")},
+			names: []string{"andrew", "hayk"},
+		},
+	}
+
+	for _, tc := range tcases {
+		res, err := db.FindUser(ctx, &tc.req)
+		require.NoError(t, err)
+
+		var names []string
+		for _, u := range res.Users {
+			names = append(names, u.Name)
+		}
+		assert.Equal(t, tc.names, names, "%+v", tc.req)
+	}
+}
+
+func Test_RecordLogin(t *testing.T) {
+	ctx := context.Background()
+	db, err := inmemory.NewUsersManager()
+	require.NoError(t, err)
+
+	_, err = db.GetUser(ctx, "missing")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	user, err := db.GetUser(ctx, "DANIEL@ekspand.com")
+	require.NoError(t, err)
+	assert.Equal(t, "a004", user.ID)
+	assert.Nil(t, user.LastLoginAt)
+
+	user, err = db.RecordLogin(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, user.LoginCount)
+	require.NotNil(t, user.LastLoginAt)
+
+	user2, err := db.GetUser(ctx, "a004")
+	require.NoError(t, err)
+	assert.Equal(t, *user, *user2)
+
+	_, err = db.RecordLogin(ctx, "missing")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+}
@@ -0,0 +1,73 @@
+package inmemory
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/algorithms/guid"
+)
+
+func (p *inmem) RevokeTokens(ctx context.Context, r *v1.Revocation) (*v1.Revocation, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	rev := *r
+	rev.ID = guid.MustCreate()
+	rev.RevokedAt = rev.RevokedAt.Truncate(time.Second).UTC()
+	rev.ExpiresAt = rev.ExpiresAt.Truncate(time.Second).UTC()
+	p.revocations = append(p.revocations, &rev)
+
+	res := rev
+	return &res, nil
+}
+
+func (p *inmem) ListRevocations(ctx context.Context) ([]*v1.Revocation, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	// revocations are appended in the order of RevokedAt
+	list := make([]*v1.Revocation, len(p.revocations))
+	for i, r := range p.revocations {
+		rev := *r
+		list[i] = &rev
+	}
+	return list, nil
+}
+
+func (p *inmem) IsTokenRevoked(ctx context.Context, tokenID, deviceID, user string, issuedAt time.Time) (bool, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, r := range p.revocations {
+		switch {
+		case r.TokenID != "" && r.TokenID == tokenID:
+			return true, nil
+		case r.DeviceID != "" && r.DeviceID == deviceID && !issuedAt.After(r.RevokedAt):
+			return true, nil
+		case r.User != "" && strings.EqualFold(r.User, user) && !issuedAt.After(r.RevokedAt):
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (p *inmem) PurgeRevocations(ctx context.Context, before time.Time) (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	kept := p.revocations[:0]
+	for _, r := range p.revocations {
+		if !r.ExpiresAt.Before(before) {
+			kept = append(kept, r)
+		}
+	}
+
+	purged := len(p.revocations) - len(kept)
+	for i := len(kept); i < len(p.revocations); i++ {
+		p.revocations[i] = nil
+	}
+	p.revocations = kept
+	return purged, nil
+}
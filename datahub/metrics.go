@@ -0,0 +1,164 @@
+package datahub
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/metrics"
+)
+
+var (
+	keyForOperationPerf   = []string{"datahub", "perf"}
+	keyForOperationFailed = []string{"datahub", "failed"}
+)
+
+// measured records timings of the datahub operations
+type measured struct {
+	db Datahub
+}
+
+// WithMetrics returns the datahub, which records timings
+// and failures of the operations
+func WithMetrics(db Datahub) Datahub {
+	return &measured{db: db}
+}
+
+// measure records the timing of the operation started at the time
+func measure(op string, start time.Time, err error) {
+	tag := metrics.Tag{Name: "operation", Value: op}
+	metrics.MeasureSince(keyForOperationPerf, start, tag)
+	if err != nil {
+		metrics.IncrCounter(keyForOperationFailed, 1, tag)
+	}
+}
+
+func (m *measured) ListTeams(ctx context.Context, req *v1.ListTeamsRequest) (res *v1.ListTeamsResponse, err error) {
+	defer func(start time.Time) { measure("ListTeams", start, err) }(time.Now())
+	return m.db.ListTeams(ctx, req)
+}
+
+func (m *measured) CreateTeam(ctx context.Context, req *v1.CreateTeamRequest) (res *v1.Team, err error) {
+	defer func(start time.Time) { measure("CreateTeam", start, err) }(time.Now())
+	return m.db.CreateTeam(ctx, req)
+}
+
+func (m *measured) UpdateTeam(ctx context.Context, req *v1.UpdateTeamRequest) (res *v1.Team, err error) {
+	defer func(start time.Time) { measure("UpdateTeam", start, err) }(time.Now())
+	return m.db.UpdateTeam(ctx, req)
+}
+
+func (m *measured) DeleteTeam(ctx context.Context, teamID string) (err error) {
+	defer func(start time.Time) { measure("DeleteTeam", start, err) }(time.Now())
+	return m.db.DeleteTeam(ctx, teamID)
+}
+
+func (m *measured) FindUser(ctx context.Context, req *v1.FindUserRequest) (res *v1.FindUserResponse, err error) {
+	defer func(start time.Time) { measure("FindUser", start, err) }(time.Now())
+	return m.db.FindUser(ctx, req)
+}
+
+func (m *measured) GetUser(ctx context.Context, user string) (res *v1.User, err error) {
+	defer func(start time.Time) { measure("GetUser", start, err) }(time.Now())
+	return m.db.GetUser(ctx, user)
+}
+
+func (m *measured) RecordLogin(ctx context.Context, userID string) (res *v1.User, err error) {
+	defer func(start time.Time) { measure("RecordLogin", start, err) }(time.Now())
+	return m.db.RecordLogin(ctx, userID)
+}
+
+func (m *measured) CreateUser(ctx context.Context, req *v1.CreateUserRequest) (res *v1.User, err error) {
+	defer func(start time.Time) { measure("CreateUser", start, err) }(time.Now())
+	return m.db.CreateUser(ctx, req)
+}
+
+func (m *measured) UpdateUser(ctx context.Context, req *v1.UpdateUserRequest) (res *v1.User, err error) {
+	defer func(start time.Time) { measure("UpdateUser", start, err) }(time.Now())
+	return m.db.UpdateUser(ctx, req)
+}
+
+func (m *measured) DeleteUser(ctx context.Context, userID string) (err error) {
+	defer func(start time.Time) { measure("DeleteUser", start, err) }(time.Now())
+	return m.db.DeleteUser(ctx, userID)
+}
+
+func (m *measured) AddMembership(ctx context.Context, req *v1.AddMembershipRequest) (res *v1.TeamMembership, err error) {
+	defer func(start time.Time) { measure("AddMembership", start, err) }(time.Now())
+	return m.db.AddMembership(ctx, req)
+}
+
+func (m *measured) UpdateMembership(ctx context.Context, req *v1.UpdateMembershipRequest) (res *v1.TeamMembership, err error) {
+	defer func(start time.Time) { measure("UpdateMembership", start, err) }(time.Now())
+	return m.db.UpdateMembership(ctx, req)
+}
+
+func (m *measured) DeleteMembership(ctx context.Context, membershipID string) (err error) {
+	defer func(start time.Time) { measure("DeleteMembership", start, err) }(time.Now())
+	return m.db.DeleteMembership(ctx, membershipID)
+}
+
+func (m *measured) GetUserMemberships(ctx context.Context, user string) (res []*v1.TeamMemberInfo, err error) {
+	defer func(start time.Time) { measure("GetUserMemberships", start, err) }(time.Now())
+	return m.db.GetUserMemberships(ctx, user)
+}
+
+func (m *measured) RevokeTokens(ctx context.Context, r *v1.Revocation) (res *v1.Revocation, err error) {
+	defer func(start time.Time) { measure("RevokeTokens", start, err) }(time.Now())
+	return m.db.RevokeTokens(ctx, r)
+}
+
+func (m *measured) ListRevocations(ctx context.Context) (res []*v1.Revocation, err error) {
+	defer func(start time.Time) { measure("ListRevocations", start, err) }(time.Now())
+	return m.db.ListRevocations(ctx)
+}
+
+func (m *measured) IsTokenRevoked(ctx context.Context, tokenID, deviceID, user string, issuedAt time.Time) (res bool, err error) {
+	defer func(start time.Time) { measure("IsTokenRevoked", start, err) }(time.Now())
+	return m.db.IsTokenRevoked(ctx, tokenID, deviceID, user, issuedAt)
+}
+
+func (m *measured) PurgeRevocations(ctx context.Context, before time.Time) (res int, err error) {
+	defer func(start time.Time) { measure("PurgeRevocations", start, err) }(time.Now())
+	return m.db.PurgeRevocations(ctx, before)
+}
+
+func (m *measured) CreateAPIKey(ctx context.Context, key *v1.APIKey, hash string) (res *v1.APIKey, err error) {
+	defer func(start time.Time) { measure("CreateAPIKey", start, err) }(time.Now())
+	return m.db.CreateAPIKey(ctx, key, hash)
+}
+
+func (m *measured) GetAPIKey(ctx context.Context, id string) (res *v1.APIKey, err error) {
+	defer func(start time.Time) { measure("GetAPIKey", start, err) }(time.Now())
+	return m.db.GetAPIKey(ctx, id)
+}
+
+func (m *measured) FindAPIKey(ctx context.Context, hash string) (res *v1.APIKey, err error) {
+	defer func(start time.Time) { measure("FindAPIKey", start, err) }(time.Now())
+	return m.db.FindAPIKey(ctx, hash)
+}
+
+func (m *measured) ListAPIKeys(ctx context.Context, owner string) (res []*v1.APIKey, err error) {
+	defer func(start time.Time) { measure("ListAPIKeys", start, err) }(time.Now())
+	return m.db.ListAPIKeys(ctx, owner)
+}
+
+func (m *measured) RotateAPIKey(ctx context.Context, id, prefix, hash string) (res *v1.APIKey, err error) {
+	defer func(start time.Time) { measure("RotateAPIKey", start, err) }(time.Now())
+	return m.db.RotateAPIKey(ctx, id, prefix, hash)
+}
+
+func (m *measured) RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) (res *v1.APIKey, err error) {
+	defer func(start time.Time) { measure("RevokeAPIKey", start, err) }(time.Now())
+	return m.db.RevokeAPIKey(ctx, id, revokedAt)
+}
+
+func (m *measured) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) (err error) {
+	defer func(start time.Time) { measure("TouchAPIKey", start, err) }(time.Now())
+	return m.db.TouchAPIKey(ctx, id, usedAt)
+}
+
+func (m *measured) Ping(ctx context.Context) (err error) {
+	defer func(start time.Time) { measure("Ping", start, err) }(time.Now())
+	return m.db.Ping(ctx)
+}
@@ -0,0 +1,50 @@
+package datahub_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/go-phorce/dolly-test/datahub/inmemory"
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithMetrics(t *testing.T) {
+	sink := metrics.NewInmemSink(time.Minute, time.Minute)
+	_, err := metrics.NewGlobal(&metrics.Config{FilterDefault: true, TimerGranularity: time.Millisecond}, sink)
+	require.NoError(t, err)
+
+	mem, err := inmemory.New()
+	require.NoError(t, err)
+	db := datahub.WithMetrics(mem)
+
+	ctx := context.Background()
+	team, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "dolly"})
+	require.NoError(t, err)
+	assert.Equal(t, "dolly", team.Name)
+
+	_, err = db.GetUser(ctx, "missing")
+	require.Error(t, err)
+
+	intv := sink.Data()[0]
+	intv.RLock()
+	defer intv.RUnlock()
+
+	has := func(m map[string]metrics.SampledValue, key string) bool {
+		_, ok := m[key]
+		return ok
+	}
+	assert.True(t, has(intv.Samples, "datahub.perf;operation=CreateTeam"))
+	assert.True(t, has(intv.Samples, "datahub.perf;operation=GetUser"))
+	assert.False(t, has(intv.Counters, "datahub.failed;operation=CreateTeam"))
+	assert.True(t, has(intv.Counters, "datahub.failed;operation=GetUser"))
+
+	for k := range intv.Samples {
+		assert.True(t, strings.HasPrefix(k, "datahub.perf;"), k)
+	}
+}
@@ -0,0 +1,105 @@
+package datahub
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/juju/errors"
+)
+
+const (
+	// DefaultPageLimit specifies the number of items returned in a page,
+	// if the limit is not specified in the request
+	DefaultPageLimit = 100
+	// MaxPageLimit specifies the maximum number of items returned in a page
+	MaxPageLimit = v1.MaxListLimit
+)
+
+// SortOrder specifies the field to sort by
+type SortOrder struct {
+	Field string
+	Desc  bool
+}
+
+// String returns the sort order in the request format: [-]field
+func (s *SortOrder) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// ParseSort returns the sort order from the request format: [-]field,
+// where field must be one of the allowed fields.
+// The first allowed field in ascending order is returned for empty value.
+func ParseSort(sort string, allowed ...string) (*SortOrder, error) {
+	if sort == "" {
+		return &SortOrder{Field: allowed[0]}, nil
+	}
+
+	s := &SortOrder{Field: sort}
+	if strings.HasPrefix(sort, "-") {
+		s.Field = sort[1:]
+		s.Desc = true
+	}
+
+	for _, f := range allowed {
+		if f == s.Field {
+			return s, nil
+		}
+	}
+	return nil, errors.NotValidf("sort %q", sort)
+}
+
+// PageLimit returns the number of items to return in a page
+func PageLimit(limit int) (int, error) {
+	if limit < 0 || limit > MaxPageLimit {
+		return 0, errors.NotValidf("limit %d", limit)
+	}
+	if limit == 0 {
+		return DefaultPageLimit, nil
+	}
+	return limit, nil
+}
+
+// Cursor specifies the position of the last returned item,
+// the next page starts after it in the same sort order
+type Cursor struct {
+	// Sort specifies the sort order the cursor was issued for
+	Sort string `json:"s"`
+	// Value specifies the value of the sort field of the last item
+	Value string `json:"v"`
+	// ID specifies the ID of the last item, to break ties
+	ID string `json:"id"`
+}
+
+// Encode returns opaque representation of the cursor
+func (c *Cursor) Encode() string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+// DecodeCursor returns the cursor from its opaque representation,
+// and checks that it was issued for the same sort order.
+// Nil is returned for empty value.
+func DecodeCursor(cursor string, sort *SortOrder) (*Cursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	js, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.NotValidf("cursor")
+	}
+
+	c := new(Cursor)
+	if err = json.Unmarshal(js, c); err != nil || c.ID == "" {
+		return nil, errors.NotValidf("cursor")
+	}
+	if c.Sort != sort.String() {
+		return nil, errors.NotValidf("cursor for sort %q", sort.String())
+	}
+	return c, nil
+}
@@ -0,0 +1,63 @@
+package datahub_test
+
+import (
+	"testing"
+
+	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseSort(t *testing.T) {
+	s, err := datahub.ParseSort("", "name", "age")
+	require.NoError(t, err)
+	assert.Equal(t, datahub.SortOrder{Field: "name"}, *s)
+	assert.Equal(t, "name", s.String())
+
+	s, err = datahub.ParseSort("-age", "name", "age")
+	require.NoError(t, err)
+	assert.Equal(t, datahub.SortOrder{Field: "age", Desc: true}, *s)
+	assert.Equal(t, "-age", s.String())
+
+	_, err = datahub.ParseSort("email", "name", "age")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+}
+
+func Test_PageLimit(t *testing.T) {
+	l, err := datahub.PageLimit(0)
+	require.NoError(t, err)
+	assert.Equal(t, datahub.DefaultPageLimit, l)
+
+	l, err = datahub.PageLimit(10)
+	require.NoError(t, err)
+	assert.Equal(t, 10, l)
+
+	for _, limit := range []int{-1, datahub.MaxPageLimit + 1} {
+		_, err = datahub.PageLimit(limit)
+		require.Error(t, err)
+		assert.True(t, errors.IsNotValid(err))
+	}
+}
+
+func Test_Cursor(t *testing.T) {
+	order := &datahub.SortOrder{Field: "name", Desc: true}
+
+	c, err := datahub.DecodeCursor("", order)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c = &datahub.Cursor{Sort: "-name", Value: "denis", ID: "a001"}
+	c2, err := datahub.DecodeCursor(c.Encode(), order)
+	require.NoError(t, err)
+	assert.Equal(t, *c, *c2)
+
+	_, err = datahub.DecodeCursor(c.Encode(), &datahub.SortOrder{Field: "name"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+
+	_, err = datahub.DecodeCursor("not*base64", order)
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+}
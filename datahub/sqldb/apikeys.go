@@ -0,0 +1,198 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/algorithms/guid"
+	"github.com/juju/errors"
+)
+
+const apiKeyColumns = `id, prefix, owner, role, scopes, created_at, expires_at, last_used_at, revoked_at`
+
+// CreateAPIKey stores the key with the hash of its secret,
+// and returns it with assigned ID
+func (p *Provider) CreateAPIKey(ctx context.Context, key *v1.APIKey, hash string) (*v1.APIKey, error) {
+	if key.Owner == "" || key.Role == "" || hash == "" {
+		return nil, errors.NotValidf("API key")
+	}
+
+	scopes, err := json.Marshal(append([]string{}, key.Scopes...))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	id := guid.MustCreate()
+	err = p.withTx(ctx, func(tx *sql.Tx) error {
+		existing, err := lookupID(ctx, tx, `SELECT id FROM api_keys WHERE key_hash = ?`, hash)
+		if err != nil {
+			return err
+		}
+		if existing != "" {
+			return errors.AlreadyExistsf("API key")
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO api_keys (id, prefix, key_hash, owner, role, scopes, created_at, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, key.Prefix, hash, key.Owner, key.Role, string(scopes),
+			key.CreatedAt.Unix(), unixOrNil(key.ExpiresAt))
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetAPIKey(ctx, id)
+}
+
+// GetAPIKey returns the key by ID
+func (p *Provider) GetAPIKey(ctx context.Context, id string) (*v1.APIKey, error) {
+	key, err := scanAPIKey(p.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.NotFoundf("API key %q", id)
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return key, nil
+}
+
+// FindAPIKey returns the key by the hash of its secret
+func (p *Provider) FindAPIKey(ctx context.Context, hash string) (*v1.APIKey, error) {
+	key, err := scanAPIKey(p.db.QueryRowContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = ?`, hash))
+	if err == sql.ErrNoRows {
+		return nil, errors.NotFoundf("API key")
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns the keys of the owner, or all keys if the owner is empty,
+// ordered by CreatedAt
+func (p *Provider) ListAPIKeys(ctx context.Context, owner string) ([]*v1.APIKey, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE ? = '' OR owner = ?
+		ORDER BY created_at, rowid`, owner, owner)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	list := []*v1.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		list = append(list, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return list, nil
+}
+
+// RotateAPIKey replaces the prefix and the hash of the key's secret
+func (p *Provider) RotateAPIKey(ctx context.Context, id, prefix, hash string) (*v1.APIKey, error) {
+	err := p.withTx(ctx, func(tx *sql.Tx) error {
+		var revokedAt sql.NullInt64
+		err := tx.QueryRowContext(ctx, `SELECT revoked_at FROM api_keys WHERE id = ?`, id).Scan(&revokedAt)
+		if err == sql.ErrNoRows {
+			return errors.NotFoundf("API key %q", id)
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+		if revokedAt.Valid {
+			return errors.NotValidf("revoked API key %q", id)
+		}
+
+		existing, err := lookupID(ctx, tx, `SELECT id FROM api_keys WHERE key_hash = ?`, hash)
+		if err != nil {
+			return err
+		}
+		if existing != "" {
+			return errors.AlreadyExistsf("API key")
+		}
+
+		res, err := tx.ExecContext(ctx,
+			`UPDATE api_keys SET prefix = ?, key_hash = ? WHERE id = ?`, prefix, hash, id)
+		return checkAffected(res, err, "API key %q", id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetAPIKey(ctx, id)
+}
+
+// RevokeAPIKey sets the revocation time of the key
+func (p *Provider) RevokeAPIKey(ctx context.Context, id string, revokedAt time.Time) (*v1.APIKey, error) {
+	res, err := p.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ?`, revokedAt.Unix(), id)
+	if err = checkAffected(res, err, "API key %q", id); err != nil {
+		return nil, err
+	}
+	return p.GetAPIKey(ctx, id)
+}
+
+// TouchAPIKey sets the last used time of the key
+func (p *Provider) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	res, err := p.db.ExecContext(ctx,
+		`UPDATE api_keys SET last_used_at = ? WHERE id = ?`, usedAt.Unix(), id)
+	return checkAffected(res, err, "API key %q", id)
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*v1.APIKey, error) {
+	var (
+		scopes                           string
+		createdAt                        int64
+		expiresAt, lastUsedAt, revokedAt sql.NullInt64
+	)
+
+	key := new(v1.APIKey)
+	err := row.Scan(&key.ID, &key.Prefix, &key.Owner, &key.Role, &scopes,
+		&createdAt, &expiresAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, errors.Annotatef(err, "invalid scopes of API key %q", key.ID)
+	}
+	if len(key.Scopes) == 0 {
+		key.Scopes = nil
+	}
+	key.CreatedAt = time.Unix(createdAt, 0).UTC()
+	key.ExpiresAt = unixTime(expiresAt)
+	key.LastUsedAt = unixTime(lastUsedAt)
+	key.RevokedAt = unixTime(revokedAt)
+	return key, nil
+}
+
+func unixOrNil(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Unix()
+}
+
+func unixTime(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0).UTC()
+	return &t
+}
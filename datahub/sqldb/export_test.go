@@ -0,0 +1,8 @@
+package sqldb
+
+import "database/sql"
+
+// DB returns the database, for tests to modify the data directly
+func (p *Provider) DB() *sql.DB {
+	return p.db
+}
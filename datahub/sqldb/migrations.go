@@ -0,0 +1,145 @@
+package sqldb
+
+import (
+	"database/sql"
+
+	"github.com/juju/errors"
+)
+
+// migration describes a single schema change
+type migration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// migrations specifies the ordered list of schema changes.
+// Applied migrations must never be modified, add a new one instead.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create teams, users and memberships",
+		statements: []string{
+			`CREATE TABLE teams (
+				id          VARCHAR(64) NOT NULL PRIMARY KEY,
+				name        VARCHAR(64) NOT NULL,
+				description TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE UNIQUE INDEX idx_teams_name ON teams (name)`,
+			`CREATE TABLE users (
+				id            VARCHAR(64) NOT NULL PRIMARY KEY,
+				name          VARCHAR(64) NOT NULL,
+				email         VARCHAR(64) NOT NULL,
+				age           INTEGER NOT NULL DEFAULT 0,
+				login_count   INTEGER NOT NULL DEFAULT 0,
+				last_login_at TIMESTAMP NULL
+			)`,
+			`CREATE UNIQUE INDEX idx_users_email ON users (email)`,
+			`CREATE TABLE memberships (
+				id      VARCHAR(64) NOT NULL PRIMARY KEY,
+				team_id VARCHAR(64) NOT NULL REFERENCES teams (id),
+				user_id VARCHAR(64) NOT NULL REFERENCES users (id),
+				role    VARCHAR(64) NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX idx_memberships_team_user ON memberships (team_id, user_id)`,
+			`CREATE INDEX idx_memberships_user ON memberships (user_id)`,
+		},
+	},
+	{
+		version:     2,
+		description: "create revocations",
+		statements: []string{
+			// times are stored as Unix seconds, to compare with issued at claim
+			`CREATE TABLE revocations (
+				id         VARCHAR(64) NOT NULL PRIMARY KEY,
+				token_id   VARCHAR(64) NOT NULL DEFAULT '',
+				device_id  VARCHAR(64) NOT NULL DEFAULT '',
+				user       VARCHAR(64) NOT NULL DEFAULT '',
+				reason     TEXT NOT NULL DEFAULT '',
+				revoked_at INTEGER NOT NULL,
+				expires_at INTEGER NOT NULL
+			)`,
+			`CREATE INDEX idx_revocations_token ON revocations (token_id)`,
+			`CREATE INDEX idx_revocations_device ON revocations (device_id)`,
+			`CREATE INDEX idx_revocations_user ON revocations (user)`,
+			`CREATE INDEX idx_revocations_expires ON revocations (expires_at)`,
+		},
+	},
+	{
+		version:     3,
+		description: "create api_keys",
+		statements: []string{
+			// scopes are stored as JSON array, times as Unix seconds
+			`CREATE TABLE api_keys (
+				id           VARCHAR(64) NOT NULL PRIMARY KEY,
+				prefix       VARCHAR(16) NOT NULL,
+				key_hash     VARCHAR(64) NOT NULL,
+				owner        VARCHAR(64) NOT NULL,
+				role         VARCHAR(64) NOT NULL,
+				scopes       TEXT NOT NULL DEFAULT '[]',
+				created_at   INTEGER NOT NULL,
+				expires_at   INTEGER NULL,
+				last_used_at INTEGER NULL,
+				revoked_at   INTEGER NULL
+			)`,
+			`CREATE UNIQUE INDEX idx_api_keys_hash ON api_keys (key_hash)`,
+			`CREATE INDEX idx_api_keys_owner ON api_keys (owner)`,
+		},
+	},
+}
+
+// migrate applies all pending migrations, and returns the current schema version
+func migrate(db *sql.DB) (int, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     INTEGER NOT NULL PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at  TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return 0, errors.Annotate(err, "failed to create schema_migrations")
+	}
+
+	var current int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return 0, errors.Annotate(err, "failed to query schema version")
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		err = applyMigration(db, m)
+		if err != nil {
+			return current, errors.Annotatef(err, "failed to apply migration %d", m.version)
+		}
+		logger.Noticef("api=migrate, version=%d, description=%q", m.version, m.description)
+		current = m.version
+	}
+
+	return current, nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	for _, stmt := range m.statements {
+		if _, err = tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return errors.Trace(err)
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.description, timeNow())
+	if err != nil {
+		tx.Rollback()
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(tx.Commit())
+}
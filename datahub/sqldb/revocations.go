@@ -0,0 +1,87 @@
+package sqldb
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/algorithms/guid"
+	"github.com/juju/errors"
+)
+
+// RevokeTokens adds the revocation, and returns it with assigned ID
+func (p *Provider) RevokeTokens(ctx context.Context, r *v1.Revocation) (*v1.Revocation, error) {
+	rev := *r
+	rev.ID = guid.MustCreate()
+	rev.RevokedAt = rev.RevokedAt.Truncate(time.Second).UTC()
+	rev.ExpiresAt = rev.ExpiresAt.Truncate(time.Second).UTC()
+
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO revocations (id, token_id, device_id, user, reason, revoked_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		rev.ID, rev.TokenID, rev.DeviceID, rev.User, rev.Reason, rev.RevokedAt.Unix(), rev.ExpiresAt.Unix())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &rev, nil
+}
+
+// ListRevocations returns the revocations ordered by RevokedAt
+func (p *Provider) ListRevocations(ctx context.Context) ([]*v1.Revocation, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT id, token_id, device_id, user, reason, revoked_at, expires_at
+		FROM revocations ORDER BY revoked_at, rowid`)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	list := []*v1.Revocation{}
+	for rows.Next() {
+		var revokedAt, expiresAt int64
+		r := new(v1.Revocation)
+		err = rows.Scan(&r.ID, &r.TokenID, &r.DeviceID, &r.User, &r.Reason, &revokedAt, &expiresAt)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		r.RevokedAt = time.Unix(revokedAt, 0).UTC()
+		r.ExpiresAt = time.Unix(expiresAt, 0).UTC()
+		list = append(list, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return list, nil
+}
+
+// IsTokenRevoked returns true if the token issued at the time
+// is revoked by its ID, device or user
+func (p *Provider) IsTokenRevoked(ctx context.Context, tokenID, deviceID, user string, issuedAt time.Time) (bool, error) {
+	var count int
+	err := p.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM revocations
+		WHERE (token_id <> '' AND token_id = ?)
+		OR (device_id <> '' AND device_id = ? AND revoked_at >= ?)
+		OR (user <> '' AND LOWER(user) = LOWER(?) AND revoked_at >= ?)`,
+		tokenID, deviceID, issuedAt.Unix(), user, issuedAt.Unix()).
+		Scan(&count)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return count > 0, nil
+}
+
+// PurgeRevocations deletes the revocations expired before the time,
+// and returns the number of deleted revocations
+func (p *Provider) PurgeRevocations(ctx context.Context, before time.Time) (int, error) {
+	res, err := p.db.ExecContext(ctx, `DELETE FROM revocations WHERE expires_at < ?`, before.Unix())
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return int(count), nil
+}
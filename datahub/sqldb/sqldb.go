@@ -0,0 +1,667 @@
+// Package sqldb provides SQL-backed implementation of the Datahub
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/go-phorce/dolly/algorithms/guid"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/juju/errors"
+
+	// register SQLite driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/datahub", "sqldb")
+
+// ProviderName is the name of SQLite provider
+const ProviderName = "sqlite3"
+
+// sqliteParams specifies SQLite connection parameters:
+// the writers wait for the lock, and the foreign keys are enforced
+var sqliteParams = []string{
+	"_busy_timeout=5000",
+	"_journal_mode=WAL",
+	"_foreign_keys=on",
+}
+
+var timeNow = func() time.Time {
+	return time.Now().UTC()
+}
+
+// Provider of SQL-backed Datahub
+type Provider struct {
+	db *sql.DB
+}
+
+// New returns SQL-backed Datahub,
+// the database schema is migrated to the latest version
+func New(driverName, dataSource string) (*Provider, error) {
+	if driverName == ProviderName {
+		dataSource = sqliteDataSource(dataSource)
+	}
+	db, err := sql.Open(driverName, dataSource)
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed to open %s database", driverName)
+	}
+	if driverName == ProviderName {
+		// SQLite allows a single writer, so the writers wait in the pool
+		db.SetMaxOpenConns(1)
+	}
+
+	version, err := migrate(db)
+	if err != nil {
+		db.Close()
+		return nil, errors.Trace(err)
+	}
+
+	logger.Infof("api=sqldb.New, driver=%s, schema_version=%d", driverName, version)
+
+	return &Provider{db: db}, nil
+}
+
+// sqliteDataSource adds the connection parameters,
+// which are not specified in the data source
+func sqliteDataSource(dataSource string) string {
+	for _, param := range sqliteParams {
+		name := param[:strings.Index(param, "=")+1]
+		if strings.Contains(dataSource, name) {
+			continue
+		}
+		if strings.Contains(dataSource, "?") {
+			dataSource += "&" + param
+		} else {
+			dataSource += "?" + param
+		}
+	}
+	return dataSource
+}
+
+// Close closes the database
+func (p *Provider) Close() error {
+	return p.db.Close()
+}
+
+// Ping verifies that the database is reachable
+func (p *Provider) Ping(ctx context.Context) error {
+	return errors.Trace(p.db.PingContext(ctx))
+}
+
+// ListTeams returns a page of teams
+func (p *Provider) ListTeams(ctx context.Context, req *v1.ListTeamsRequest) (*v1.ListTeamsResponse, error) {
+	order, err := datahub.ParseSort(req.Sort, datahub.SortByName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	limit, err := datahub.PageLimit(req.Limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	query := `SELECT id, name, description FROM teams WHERE 1 = 1`
+	args := []interface{}{}
+
+	query, args, err = pageQuery(query, args, req.Cursor, order, limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	teams := []*v1.Team{}
+	for rows.Next() {
+		t := new(v1.Team)
+		if err = rows.Scan(&t.ID, &t.Name, &t.Description); err != nil {
+			return nil, errors.Trace(err)
+		}
+		teams = append(teams, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	res := &v1.ListTeamsResponse{
+		Items: teams,
+	}
+	if len(teams) > limit {
+		res.Items = teams[:limit]
+		last := res.Items[limit-1]
+		res.NextCursor = nextCursor(order, last.Name, last.ID)
+	}
+	res.Teams = make([]string, len(res.Items))
+	for i, t := range res.Items {
+		res.Teams[i] = t.Name
+	}
+	return res, nil
+}
+
+// CreateTeam creates a team
+func (p *Provider) CreateTeam(ctx context.Context, req *v1.CreateTeamRequest) (*v1.Team, error) {
+	if req.Name == "" {
+		return nil, errors.NotValidf("team name")
+	}
+
+	t := &v1.Team{
+		ID:          guid.MustCreate(),
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	err := p.withTx(ctx, func(tx *sql.Tx) error {
+		id, err := lookupID(ctx, tx, `SELECT id FROM teams WHERE LOWER(name) = LOWER(?)`, req.Name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if id != "" {
+			return errors.AlreadyExistsf("team %q", req.Name)
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO teams (id, name, description) VALUES (?, ?, ?)`,
+			t.ID, t.Name, t.Description)
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// UpdateTeam updates a team
+func (p *Provider) UpdateTeam(ctx context.Context, req *v1.UpdateTeamRequest) (*v1.Team, error) {
+	if req.Name == "" {
+		return nil, errors.NotValidf("team name")
+	}
+
+	err := p.withTx(ctx, func(tx *sql.Tx) error {
+		id, err := lookupID(ctx, tx, `SELECT id FROM teams WHERE LOWER(name) = LOWER(?)`, req.Name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if id != "" && id != req.ID {
+			return errors.AlreadyExistsf("team %q", req.Name)
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE teams SET name = ?, description = ? WHERE id = ?`,
+			req.Name, req.Description, req.ID)
+		return checkAffected(res, err, "team %q", req.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	t := &v1.Team{
+		ID:          req.ID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	return t, nil
+}
+
+// DeleteTeam deletes a team and its memberships
+func (p *Provider) DeleteTeam(ctx context.Context, teamID string) error {
+	return p.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM memberships WHERE team_id = ?`, teamID)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM teams WHERE id = ?`, teamID)
+		return checkAffected(res, err, "team %q", teamID)
+	})
+}
+
+// FindUser returns a page of users that match the search criteria
+func (p *Provider) FindUser(ctx context.Context, req *v1.FindUserRequest) (*v1.FindUserResponse, error) {
+	order, err := datahub.ParseSort(req.Sort, datahub.SortByName, datahub.SortByEmail, datahub.SortByAge)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	limit, err := datahub.PageLimit(req.Limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	query := `SELECT id, name, email, age, login_count, last_login_at FROM users WHERE 1 = 1`
+	args := []interface{}{}
+
+	if req.Name != "" {
+		query += ` AND name = ?`
+		args = append(args, req.Name)
+	}
+	if req.NamePrefix != "" {
+		query += ` AND LOWER(name) LIKE ? ESCAPE '\'`
+		args = append(args, likePattern(req.NamePrefix, false))
+	}
+	if req.NameContains != "" {
+		query += ` AND LOWER(name) LIKE ? ESCAPE '\'`
+		args = append(args, likePattern(req.NameContains, true))
+	}
+	if req.EmailPrefix != "" {
+		query += ` AND LOWER(email) LIKE ? ESCAPE '\'`
+		args = append(args, likePattern(req.EmailPrefix, false))
+	}
+	if req.EmailContains != "" {
+		query += ` AND LOWER(email) LIKE ? ESCAPE '\'`
+		args = append(args, likePattern(req.EmailContains, true))
+	}
+	if req.TeamID != "" {
+		query += ` AND id IN (SELECT user_id FROM memberships WHERE team_id = ?)`
+		args = append(args, req.TeamID)
+	}
+	if req.LastLoginAfter != nil {
+		query += ` AND last_login_at >= ?`
+		args = append(args, req.LastLoginAfter.UTC())
+	}
+	if req.LastLoginBefore != nil {
+		query += ` AND last_login_at < ?`
+		args = append(args, req.LastLoginBefore.UTC())
+	}
+	if req.MinAge > 0 {
+		query += ` AND age >= ?`
+		args = append(args, req.MinAge)
+	}
+	if req.MaxAge > 0 {
+		query += ` AND age <= ?`
+		args = append(args, req.MaxAge)
+	}
+
+	query, args, err = pageQuery(query, args, req.Cursor, order, limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	users := []*v1.User{}
+	for rows.Next() {
+		u := new(v1.User)
+		if err = rows.Scan(&u.ID, &u.Name, &u.Email, &u.Age, &u.LoginCount, &u.LastLoginAt); err != nil {
+			return nil, errors.Trace(err)
+		}
+		users = append(users, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	res := &v1.FindUserResponse{
+		Users: users,
+	}
+	if len(users) > limit {
+		res.Users = users[:limit]
+		last := res.Users[limit-1]
+		switch order.Field {
+		case datahub.SortByEmail:
+			res.NextCursor = nextCursor(order, last.Email, last.ID)
+		case datahub.SortByAge:
+			res.NextCursor = nextCursor(order, strconv.Itoa(last.Age), last.ID)
+		default:
+			res.NextCursor = nextCursor(order, last.Name, last.ID)
+		}
+	}
+	return res, nil
+}
+
+// GetUser returns the user, specified by ID or email
+func (p *Provider) GetUser(ctx context.Context, user string) (*v1.User, error) {
+	u := new(v1.User)
+	err := p.db.QueryRowContext(ctx,
+		`SELECT id, name, email, age, login_count, last_login_at FROM users
+		WHERE id = ? OR LOWER(email) = LOWER(?)
+		ORDER BY id = ? DESC LIMIT 1`, user, user, user).
+		Scan(&u.ID, &u.Name, &u.Email, &u.Age, &u.LoginCount, &u.LastLoginAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.NotFoundf("user %q", user)
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return u, nil
+}
+
+// RecordLogin increments the login count and sets the last login time of the user
+func (p *Provider) RecordLogin(ctx context.Context, userID string) (*v1.User, error) {
+	u := new(v1.User)
+	err := p.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE users SET login_count = login_count + 1, last_login_at = ? WHERE id = ?`,
+			timeNow(), userID)
+		if err = checkAffected(res, err, "user %q", userID); err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx,
+			`SELECT id, name, email, age, login_count, last_login_at FROM users WHERE id = ?`, userID).
+			Scan(&u.ID, &u.Name, &u.Email, &u.Age, &u.LoginCount, &u.LastLoginAt)
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// CreateUser creates a user
+func (p *Provider) CreateUser(ctx context.Context, req *v1.CreateUserRequest) (*v1.User, error) {
+	if req.Name == "" {
+		return nil, errors.NotValidf("user name")
+	}
+	if req.Email == "" {
+		return nil, errors.NotValidf("user email")
+	}
+
+	u := &v1.User{
+		ID:    guid.MustCreate(),
+		Name:  req.Name,
+		Email: req.Email,
+		Age:   req.Age,
+	}
+
+	err := p.withTx(ctx, func(tx *sql.Tx) error {
+		id, err := lookupID(ctx, tx, `SELECT id FROM users WHERE LOWER(email) = LOWER(?)`, req.Email)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if id != "" {
+			return errors.AlreadyExistsf("user with email %q", req.Email)
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO users (id, name, email, age) VALUES (?, ?, ?, ?)`,
+			u.ID, u.Name, u.Email, u.Age)
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// UpdateUser updates a user
+func (p *Provider) UpdateUser(ctx context.Context, req *v1.UpdateUserRequest) (*v1.User, error) {
+	if req.Name == "" {
+		return nil, errors.NotValidf("user name")
+	}
+	if req.Email == "" {
+		return nil, errors.NotValidf("user email")
+	}
+
+	u := new(v1.User)
+	err := p.withTx(ctx, func(tx *sql.Tx) error {
+		id, err := lookupID(ctx, tx, `SELECT id FROM users WHERE LOWER(email) = LOWER(?)`, req.Email)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if id != "" && id != req.ID {
+			return errors.AlreadyExistsf("user with email %q", req.Email)
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE users SET name = ?, email = ?, age = ? WHERE id = ?`,
+			req.Name, req.Email, req.Age, req.ID)
+		if err = checkAffected(res, err, "user %q", req.ID); err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx,
+			`SELECT id, name, email, age, login_count, last_login_at FROM users WHERE id = ?`, req.ID).
+			Scan(&u.ID, &u.Name, &u.Email, &u.Age, &u.LoginCount, &u.LastLoginAt)
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// DeleteUser deletes a user and its memberships
+func (p *Provider) DeleteUser(ctx context.Context, userID string) error {
+	return p.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM memberships WHERE user_id = ?`, userID)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, userID)
+		return checkAffected(res, err, "user %q", userID)
+	})
+}
+
+// AddMembership adds a user to a team
+func (p *Provider) AddMembership(ctx context.Context, req *v1.AddMembershipRequest) (*v1.TeamMembership, error) {
+	if req.Role == "" {
+		return nil, errors.NotValidf("membership role")
+	}
+
+	m := &v1.TeamMembership{
+		ID:     guid.MustCreate(),
+		TeamID: req.TeamID,
+		UserID: req.UserID,
+		Role:   req.Role,
+	}
+
+	err := p.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `SELECT name FROM teams WHERE id = ?`, req.TeamID).Scan(&m.Team)
+		if err == sql.ErrNoRows {
+			return errors.NotFoundf("team %q", req.TeamID)
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+
+		id, err := lookupID(ctx, tx, `SELECT id FROM users WHERE id = ?`, req.UserID)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if id == "" {
+			return errors.NotFoundf("user %q", req.UserID)
+		}
+
+		id, err = lookupID(ctx, tx, `SELECT id FROM memberships WHERE team_id = ? AND user_id = ?`, req.TeamID, req.UserID)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if id != "" {
+			return errors.AlreadyExistsf("membership for user %q in team %q", req.UserID, req.TeamID)
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO memberships (id, team_id, user_id, role) VALUES (?, ?, ?, ?)`,
+			m.ID, m.TeamID, m.UserID, m.Role)
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// UpdateMembership updates a user's role in a team
+func (p *Provider) UpdateMembership(ctx context.Context, req *v1.UpdateMembershipRequest) (*v1.TeamMembership, error) {
+	if req.Role == "" {
+		return nil, errors.NotValidf("membership role")
+	}
+
+	m := new(v1.TeamMembership)
+	err := p.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `UPDATE memberships SET role = ? WHERE id = ?`, req.Role, req.ID)
+		if err = checkAffected(res, err, "membership %q", req.ID); err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx,
+			`SELECT m.id, m.team_id, t.name, m.user_id, m.role
+			FROM memberships m JOIN teams t ON t.id = m.team_id
+			WHERE m.id = ?`, req.ID).
+			Scan(&m.ID, &m.TeamID, &m.Team, &m.UserID, &m.Role)
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// DeleteMembership deletes a membership
+func (p *Provider) DeleteMembership(ctx context.Context, membershipID string) error {
+	res, err := p.db.ExecContext(ctx, `DELETE FROM memberships WHERE id = ?`, membershipID)
+	return checkAffected(res, err, "membership %q", membershipID)
+}
+
+// GetUserMemberships returns memberships of the user,
+// specified by ID or email, joined with team and user details
+func (p *Provider) GetUserMemberships(ctx context.Context, user string) ([]*v1.TeamMemberInfo, error) {
+	var userID string
+	err := p.db.QueryRowContext(ctx,
+		`SELECT id FROM users WHERE id = ? OR LOWER(email) = LOWER(?)
+		ORDER BY id = ? DESC LIMIT 1`, user, user, user).
+		Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil, errors.NotFoundf("user %q", user)
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT m.id, m.team_id, t.name, m.user_id, m.role, u.name, u.email, u.age
+		FROM memberships m
+		JOIN teams t ON t.id = m.team_id
+		JOIN users u ON u.id = m.user_id
+		WHERE m.user_id = ?
+		ORDER BY t.name`, userID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer rows.Close()
+
+	list := []*v1.TeamMemberInfo{}
+	for rows.Next() {
+		m := new(v1.TeamMemberInfo)
+		err = rows.Scan(&m.MembershipID, &m.TeamID, &m.Team, &m.UserID, &m.Role, &m.Name, &m.Email, &m.Age)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		list = append(list, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return list, nil
+}
+
+// likePattern returns lower-cased LIKE pattern to match the prefix,
+// or the substring if contains is true
+func likePattern(value string, contains bool) string {
+	value = likeEscaper.Replace(strings.ToLower(value))
+	if contains {
+		return "%" + value + "%"
+	}
+	return value + "%"
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// pageQuery appends the cursor condition, the order and the limit to the query;
+// the limit is increased by one to determine if more items are available
+func pageQuery(query string, args []interface{}, cursor string, order *datahub.SortOrder, limit int) (string, []interface{}, error) {
+	c, err := datahub.DecodeCursor(cursor, order)
+	if err != nil {
+		return "", nil, errors.Trace(err)
+	}
+
+	// the field is one of the allowed sort fields, which match the column names
+	column := order.Field
+	cmp, dir := ">", "ASC"
+	if order.Desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	if c != nil {
+		var value interface{} = c.Value
+		if order.Field == datahub.SortByAge {
+			age, err := strconv.Atoi(c.Value)
+			if err != nil {
+				return "", nil, errors.NotValidf("cursor")
+			}
+			value = age
+		}
+		query += fmt.Sprintf(` AND (%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))`, column, cmp)
+		args = append(args, value, value, c.ID)
+	}
+
+	query += fmt.Sprintf(` ORDER BY %[1]s %[2]s, id %[2]s LIMIT ?`, column, dir)
+	args = append(args, limit+1)
+
+	return query, args, nil
+}
+
+// nextCursor returns the cursor that points to the last item in the page
+func nextCursor(order *datahub.SortOrder, value, id string) string {
+	c := &datahub.Cursor{
+		Sort:  order.String(),
+		Value: value,
+		ID:    id,
+	}
+	return c.Encode()
+}
+
+// withTx executes the function in a transaction,
+// which is committed if the function succeeds, or rolled back otherwise
+func (p *Provider) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return errors.Trace(tx.Commit())
+}
+
+// lookupID returns ID selected by the query, or empty string if not found
+func lookupID(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (string, error) {
+	var id string
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// checkAffected returns NotFound error if no rows were affected
+func checkAffected(res sql.Result, err error, format string, args ...interface{}) error {
+	if err != nil {
+		return errors.Trace(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if count == 0 {
+		return errors.NotFoundf(format, args...)
+	}
+	return nil
+}
+
+// ensure that Provider implements Datahub interface
+var _ datahub.Datahub = (*Provider)(nil)
@@ -0,0 +1,503 @@
+package sqldb_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub/datahubtest"
+	"github.com/go-phorce/dolly-test/datahub/sqldb"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openDB(t *testing.T) (*sqldb.Provider, func()) {
+	dir, err := ioutil.TempDir("", "sqldb")
+	require.NoError(t, err)
+
+	dsn := filepath.Join(dir, "datahub.db")
+	db, err := sqldb.New(sqldb.ProviderName, dsn)
+	require.NoError(t, err)
+
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func Test_Datahub(t *testing.T) {
+	db, closer := openDB(t)
+	defer closer()
+	datahubtest.Run(t, db)
+}
+
+func Test_ConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	db, closer := openDB(t)
+	defer closer()
+
+	count := 20
+	errs := make(chan error, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("user%d", i)
+			user, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: name, Email: name + "@ekspand.com"})
+			if err == nil {
+				_, err = db.UpdateUser(ctx, &v1.UpdateUserRequest{ID: user.ID, Name: name, Email: user.Email, Age: i})
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	// the writers wait for the lock
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	var users int
+	require.NoError(t, db.DB().QueryRow(`SELECT COUNT(*) FROM users`).Scan(&users))
+	assert.Equal(t, count, users)
+}
+
+func Test_ForeignKeys(t *testing.T) {
+	db, closer := openDB(t)
+	defer closer()
+
+	_, err := db.DB().Exec(`INSERT INTO memberships (id, team_id, user_id, role) VALUES ('m1', 'missing', 'missing', 'member')`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "FOREIGN KEY constraint failed")
+}
+
+func Test_Migrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqldb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	dsn := filepath.Join(dir, "datahub.db")
+
+	db, err := sqldb.New(sqldb.ProviderName, dsn)
+	require.NoError(t, err)
+	team, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "devs"})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// reopen the existing database, the data must persist
+	db, err = sqldb.New(sqldb.ProviderName, dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	list, err := db.ListTeams(ctx, &v1.ListTeamsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Equal(t, *team, *list.Items[0])
+	assert.Equal(t, []string{team.Name}, list.Teams)
+}
+
+func Test_Teams(t *testing.T) {
+	ctx := context.Background()
+	db, closer := openDB(t)
+	defer closer()
+
+	_, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+
+	_, err = db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "admins"})
+	require.NoError(t, err)
+
+	_, err = db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "Admins"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	team, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "devs", Description: "developers"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, team.ID)
+	assert.Equal(t, "devs", team.Name)
+
+	list, err := db.ListTeams(ctx, &v1.ListTeamsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.Teams, 2)
+
+	team, err = db.UpdateTeam(ctx, &v1.UpdateTeamRequest{ID: team.ID, Name: "developers"})
+	require.NoError(t, err)
+	assert.Equal(t, "developers", team.Name)
+	assert.Empty(t, team.Description)
+
+	_, err = db.UpdateTeam(ctx, &v1.UpdateTeamRequest{ID: team.ID, Name: "admins"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	_, err = db.UpdateTeam(ctx, &v1.UpdateTeamRequest{ID: "missing", Name: "missing"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	require.NoError(t, db.DeleteTeam(ctx, team.ID))
+	err = db.DeleteTeam(ctx, team.ID)
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	list, err = db.ListTeams(ctx, &v1.ListTeamsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.Teams, 1)
+}
+
+func Test_Users(t *testing.T) {
+	ctx := context.Background()
+	db, closer := openDB(t)
+	defer closer()
+
+	_, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "john"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+
+	_, err = db.CreateUser(ctx, &v1.CreateUserRequest{Name: "denis", Email: "denis@ekspand.com", Age: 33})
+	require.NoError(t, err)
+
+	_, err = db.CreateUser(ctx, &v1.CreateUserRequest{Name: "denis", Email: "DENIS@ekspand.com"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	user, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "john", Email: "john@ekspand.com", Age: 21})
+	require.NoError(t, err)
+	assert.NotEmpty(t, user.ID)
+
+	res, err := db.FindUser(ctx, &v1.FindUserRequest{Name: "john"})
+	require.NoError(t, err)
+	require.Len(t, res.Users, 1)
+	assert.Equal(t, *user, *res.Users[0])
+
+	res, err = db.FindUser(ctx, &v1.FindUserRequest{MinAge: 30})
+	require.NoError(t, err)
+	require.Len(t, res.Users, 1)
+	assert.Equal(t, "denis", res.Users[0].Name)
+
+	user, err = db.UpdateUser(ctx, &v1.UpdateUserRequest{ID: user.ID, Name: "johnny", Email: "john@ekspand.com", Age: 22})
+	require.NoError(t, err)
+	assert.Equal(t, "johnny", user.Name)
+	assert.Equal(t, 22, user.Age)
+
+	_, err = db.UpdateUser(ctx, &v1.UpdateUserRequest{ID: user.ID, Name: "johnny", Email: "denis@ekspand.com"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	_, err = db.UpdateUser(ctx, &v1.UpdateUserRequest{ID: "missing", Name: "missing", Email: "missing@ekspand.com"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	require.NoError(t, db.DeleteUser(ctx, user.ID))
+	err = db.DeleteUser(ctx, user.ID)
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	res, err = db.FindUser(ctx, &v1.FindUserRequest{Name: "johnny"})
+	require.NoError(t, err)
+	assert.Empty(t, res.Users)
+}
+
+func Test_Memberships(t *testing.T) {
+	ctx := context.Background()
+	db, closer := openDB(t)
+	defer closer()
+
+	team, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "devs"})
+	require.NoError(t, err)
+	user1, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "denis", Email: "denis@ekspand.com"})
+	require.NoError(t, err)
+	user2, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "andrew", Email: "andrew@ekspand.com"})
+	require.NoError(t, err)
+
+	_, err = db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: "missing", UserID: user1.ID, Role: "member"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	_, err = db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: team.ID, UserID: "missing", Role: "member"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	m, err := db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: team.ID, UserID: user1.ID, Role: "member"})
+	require.NoError(t, err)
+	assert.Equal(t, "devs", m.Team)
+
+	_, err = db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: team.ID, UserID: user1.ID, Role: "owner"})
+	require.Error(t, err)
+	assert.True(t, errors.IsAlreadyExists(err))
+
+	m, err = db.UpdateMembership(ctx, &v1.UpdateMembershipRequest{ID: m.ID, Role: "owner"})
+	require.NoError(t, err)
+	assert.Equal(t, "owner", m.Role)
+	assert.Equal(t, "devs", m.Team)
+
+	require.NoError(t, db.DeleteMembership(ctx, m.ID))
+	err = db.DeleteMembership(ctx, m.ID)
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	t.Run("cascade", func(t *testing.T) {
+		m, err := db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: team.ID, UserID: user2.ID, Role: "member"})
+		require.NoError(t, err)
+
+		require.NoError(t, db.DeleteTeam(ctx, team.ID))
+
+		_, err = db.UpdateMembership(ctx, &v1.UpdateMembershipRequest{ID: m.ID, Role: "owner"})
+		require.Error(t, err)
+		assert.True(t, errors.IsNotFound(err))
+	})
+}
+
+func Test_GetUserMemberships(t *testing.T) {
+	ctx := context.Background()
+	db, closer := openDB(t)
+	defer closer()
+
+	_, err := db.GetUserMemberships(ctx, "missing")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	other, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "other", Email: "other@ekspand.com"})
+	require.NoError(t, err)
+	user, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "denis", Email: "denis@ekspand.com", Age: 33})
+	require.NoError(t, err)
+
+	list, err := db.GetUserMemberships(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	admins, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "admins"})
+	require.NoError(t, err)
+	users, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "users"})
+	require.NoError(t, err)
+
+	m1, err := db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: users.ID, UserID: user.ID, Role: "member"})
+	require.NoError(t, err)
+	m2, err := db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: admins.ID, UserID: user.ID, Role: "owner"})
+	require.NoError(t, err)
+
+	list, err = db.GetUserMemberships(ctx, "DENIS@ekspand.com")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, v1.TeamMemberInfo{
+		MembershipID: m2.ID,
+		TeamID:       admins.ID,
+		Team:         "admins",
+		UserID:       user.ID,
+		Role:         "owner",
+		Name:         "denis",
+		Email:        "denis@ekspand.com",
+		Age:          33,
+	}, *list[0])
+	assert.Equal(t, m1.ID, list[1].MembershipID)
+	assert.Equal(t, "users", list[1].Team)
+
+	// the user is found by ID before email
+	_, err = db.UpdateUser(ctx, &v1.UpdateUserRequest{ID: other.ID, Name: "other", Email: user.ID})
+	require.NoError(t, err)
+	list, err = db.GetUserMemberships(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+}
+
+func Test_Paging(t *testing.T) {
+	ctx := context.Background()
+	db, closer := openDB(t)
+	defer closer()
+
+	for _, u := range []*v1.CreateUserRequest{
+		{Name: "denis", Email: "denis@ekspand.com", Age: 33},
+		{Name: "andrew", Email: "andrew@ekspand.com", Age: 43},
+		{Name: "hayk", Email: "hayk@ekspand.com", Age: 27},
+		{Name: "daniel", Email: "daniel@ekspand.com", Age: 27},
+		{Name: "denis", Email: "denis2@ekspand.com", Age: 18},
+	} {
+		_, err := db.CreateUser(ctx, u)
+		require.NoError(t, err)
+	}
+
+	findAll := func(sort string, limit int) []string {
+		var emails []string
+		req := &v1.FindUserRequest{Limit: limit, Sort: sort}
+		for {
+			res, err := db.FindUser(ctx, req)
+			require.NoError(t, err)
+			require.True(t, len(res.Users) <= limit)
+			for _, u := range res.Users {
+				emails = append(emails, u.Email)
+			}
+			if res.NextCursor == "" {
+				return emails
+			}
+			req.Cursor = res.NextCursor
+		}
+	}
+
+	// paging with any page size must return the same order as a single page
+	for _, sort := range []string{"", "-name", "email", "-email", "age", "-age"} {
+		all := findAll(sort, 100)
+		require.Len(t, all, 5)
+		for _, limit := range []int{1, 2, 4} {
+			assert.Equal(t, all, findAll(sort, limit), "sort=%s, limit=%d", sort, limit)
+		}
+	}
+
+	assert.Equal(t, []string{"andrew@ekspand.com", "denis@ekspand.com"}, findAll("-age", 100)[:2])
+
+	_, err := db.FindUser(ctx, &v1.FindUserRequest{Sort: "age", Cursor: "invalid"})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+
+	_, err = db.FindUser(ctx, &v1.FindUserRequest{Limit: -1})
+	require.Error(t, err)
+	assert.True(t, errors.IsNotValid(err))
+
+	for _, name := range []string{"b", "c", "a"} {
+		_, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: name})
+		require.NoError(t, err)
+	}
+
+	res, err := db.ListTeams(ctx, &v1.ListTeamsRequest{Limit: 2, Sort: "-name"})
+	require.NoError(t, err)
+	require.Len(t, res.Teams, 2)
+	assert.Equal(t, "c", res.Items[0].Name)
+	assert.Equal(t, "b", res.Items[1].Name)
+
+	res, err = db.ListTeams(ctx, &v1.ListTeamsRequest{Limit: 2, Sort: "-name", Cursor: res.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, res.Teams, 1)
+	assert.Equal(t, "a", res.Items[0].Name)
+	assert.Empty(t, res.NextCursor)
+}
+
+func Test_FindUserFilters(t *testing.T) {
+	ctx := context.Background()
+	db, closer := openDB(t)
+	defer closer()
+
+	at := func(value string) *time.Time {
+		t, _ := time.Parse(time.RFC3339, value)
+		return &t
+	}
+
+	team, err := db.CreateTeam(ctx, &v1.CreateTeamRequest{Name: "users"})
+	require.NoError(t, err)
+
+	for _, u := range []struct {
+		name, email string
+		lastLogin   *time.Time
+		member      bool
+	}{
+		{"denis", "denis@ekspand.com", at("2019-03-01T10:00:00Z"), false},
+		{"andrew", "andrew@ekspand.com", at("2019-02-15T08:30:00Z"), true},
+		{"hayk", "hayk@ekspand.com", at("2019-01-20T17:45:00Z"), true},
+		{"daniel", "daniel@ekspand.com", nil, true},
+		{"d_100%", "special@ekspand.com", nil, false},
+	} {
+		user, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: u.name, Email: u.email})
+		require.NoError(t, err)
+		if u.lastLogin != nil {
+			_, err = db.DB().Exec(`UPDATE users SET last_login_at = ? WHERE id = ?`, *u.lastLogin, user.ID)
+			require.NoError(t, err)
+		}
+		if u.member {
+			_, err = db.AddMembership(ctx, &v1.AddMembershipRequest{TeamID: team.ID, UserID: user.ID, Role: "member"})
+			require.NoError(t, err)
+		}
+	}
+
+	tcases := []struct {
+		req   v1.FindUserRequest
+		names []string
+	}{
+		{req: v1.FindUserRequest{NamePrefix: "DA"}, names: []string{"daniel"}},
+		{req: v1.FindUserRequest{NameContains: "N"}, names: []string{"andrew", "daniel", "denis"}},
+		{req: v1.FindUserRequest{NamePrefix: "d", NameContains: "is"}, names: []string{"denis"}},
+		{req: v1.FindUserRequest{NamePrefix: "d_"}, names: []string{"d_100%"}},
+		{req: v1.FindUserRequest{NameContains: "%"}, names: []string{"d_100%"}},
+		{req: v1.FindUserRequest{EmailPrefix: "Hayk@"}, names: []string{"hayk"}},
+		{req: v1.FindUserRequest{EmailContains: "@EKSPAND"}, names: []string{"andrew", "d_100%", "daniel", "denis", "hayk"}},
+		{req: v1.FindUserRequest{TeamID: team.ID}, names: []string{"andrew", "daniel", "hayk"}},
+		{req: v1.FindUserRequest{TeamID: team.ID, NamePrefix: "h"}, names: []string{"hayk"}},
+		{req: v1.FindUserRequest{TeamID: "missing"}, names: nil},
+		{req: v1.FindUserRequest{LastLoginAfter: at("2019-02-15T08:30:00Z")}, names: []string{"andrew", "denis"}},
+		{req: v1.FindUserRequest{LastLoginBefore: at("2019-02-15T08:30:00Z")}, names: []string{"hayk"}},
+		{
+			req:   v1.FindUserRequest{LastLoginAfter: at("2019-01-01T00:00:00Z"), LastLoginBefore: at("2019-03-01T00:00:00Z")},
+			names: []string{"andrew", "hayk"},
+		},
+	}
+
+	for _, tc := range tcases {
+		res, err := db.FindUser(ctx, &tc.req)
+		require.NoError(t, err)
+
+		var names []string
+		for _, u := range res.Users {
+			names = append(names, u.Name)
+		}
+		assert.Equal(t, tc.names, names, "%+v", tc.req)
+	}
+}
+
+func Test_RecordLogin(t *testing.T) {
+	ctx := context.Background()
+	db, closer := openDB(t)
+	defer closer()
+
+	_, err := db.GetUser(ctx, "missing")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	other, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "other", Email: "other@ekspand.com"})
+	require.NoError(t, err)
+	created, err := db.CreateUser(ctx, &v1.CreateUserRequest{Name: "denis", Email: "denis@ekspand.com"})
+	require.NoError(t, err)
+
+	user, err := db.GetUser(ctx, "DENIS@ekspand.com")
+	require.NoError(t, err)
+	assert.Equal(t, *created, *user)
+
+	for i := 1; i <= 2; i++ {
+		user, err = db.RecordLogin(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, i, user.LoginCount)
+		require.NotNil(t, user.LastLoginAt)
+	}
+
+	user2, err := db.GetUser(ctx, created.ID)
+	require.NoError(t, err)
+	assert.True(t, user.LastLoginAt.Equal(*user2.LastLoginAt))
+	assert.Equal(t, 2, user2.LoginCount)
+
+	_, err = db.RecordLogin(ctx, "missing")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotFound(err))
+
+	// the user is found by ID before email
+	_, err = db.UpdateUser(ctx, &v1.UpdateUserRequest{ID: other.ID, Name: "other", Email: created.ID})
+	require.NoError(t, err)
+	user, err = db.GetUser(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, user.ID)
+}
+
+func Test_Ping(t *testing.T) {
+	db, closer := openDB(t)
+	defer closer()
+
+	ctx := context.Background()
+	require.NoError(t, db.Ping(ctx))
+
+	require.NoError(t, db.Close())
+	assert.Error(t, db.Ping(ctx))
+}
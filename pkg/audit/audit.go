@@ -0,0 +1,237 @@
+package audit
+
+import (
+	"crypto"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/juju/errors"
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/pkg", "audit")
+
+// FileName specifies the name of audit file in the audit folder
+const FileName = "audit.log"
+
+// DefaultCheckpointInterval specifies how often the signed checkpoints are written
+const DefaultCheckpointInterval = time.Hour
+
+// Event provides audit record, which is written as a JSON line.
+// Each record includes the hash of the previous record,
+// and the checkpoint records are signed.
+type Event struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Source    string    `json:"source"`
+	Type      string    `json:"type"`
+	Identity  string    `json:"identity"`
+	ContextID string    `json:"context_id"`
+	RaftIndex uint64    `json:"raft_index,omitempty"`
+	Message   string    `json:"message"`
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature,omitempty"`
+}
+
+// Auditor records audit events, it is implemented by rest.Server and rest.Auditor
+type Auditor interface {
+	Audit(source string,
+		eventType string,
+		identity string,
+		contextID string,
+		raftIndex uint64,
+		message string)
+}
+
+// ForRequest records the audit event with the identity and correlation ID of the request,
+// the event is not recorded if the auditor is nil
+func ForRequest(a Auditor, r *http.Request, source, eventType, message string) {
+	if a == nil {
+		return
+	}
+	ctx := identity.ForRequest(r)
+	a.Audit(source, eventType, ctx.Identity().String(), ctx.CorrelationID(), 0, message)
+}
+
+// FileAuditor writes hash-chained audit events to the rotated files
+type FileAuditor struct {
+	lock   sync.Mutex
+	writer io.WriteCloser
+	closed bool
+
+	// seq and lastHash specify the last written record
+	seq      uint64
+	lastHash string
+
+	signer crypto.Signer
+	// unsigned is the number of records after the last checkpoint
+	unsigned int
+	stop     chan struct{}
+}
+
+// New returns FileAuditor, which writes to FileName in the folder.
+// The chain is continued from the last record of existing files.
+func New(folder string, maxAgeDays, maxSizeMb int) (*FileAuditor, error) {
+	err := os.MkdirAll(folder, 0700)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	last, err := lastEvent(folder)
+	if err != nil {
+		return nil, errors.Annotate(err, "unable to continue audit chain")
+	}
+
+	a := &FileAuditor{
+		writer: &lumberjack.Logger{
+			Filename: filepath.Join(folder, FileName),
+			MaxAge:   maxAgeDays,
+			MaxSize:  maxSizeMb,
+		},
+	}
+	if last != nil {
+		a.seq = last.Seq
+		a.lastHash = last.Hash
+		logger.Infof("api=audit.New, folder=%q, seq=%d", folder, a.seq)
+	}
+	return a, nil
+}
+
+// StartCheckpoints starts to write checkpoints signed by the signer with the interval,
+// the last checkpoint is written on Close
+func (a *FileAuditor) StartCheckpoints(signer crypto.Signer, interval time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.closed || a.stop != nil {
+		return
+	}
+	if interval == 0 {
+		interval = DefaultCheckpointInterval
+	}
+
+	a.signer = signer
+	a.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				a.lock.Lock()
+				a.checkpoint()
+				a.lock.Unlock()
+			}
+		}
+	}(a.stop)
+}
+
+// Audit records the event
+func (a *FileAuditor) Audit(source string,
+	eventType string,
+	identity string,
+	contextID string,
+	raftIndex uint64,
+	message string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.closed {
+		logger.Errorf("api=Audit, reason=closed, source=%s, type=%q, message=%q", source, eventType, message)
+		return
+	}
+
+	a.write(&Event{
+		Source:    source,
+		Type:      eventType,
+		Identity:  identity,
+		ContextID: contextID,
+		RaftIndex: raftIndex,
+		Message:   message,
+	})
+	a.unsigned++
+}
+
+// checkpoint writes signed checkpoint, if there are records after the last one;
+// the caller must hold the lock
+func (a *FileAuditor) checkpoint() {
+	if a.signer == nil || a.unsigned == 0 {
+		return
+	}
+
+	e := &Event{
+		Source:  EvtSourceAudit,
+		Type:    EvtCheckpoint,
+		Message: checkpointMessage(a.unsigned),
+	}
+	if a.write(e) {
+		a.unsigned = 0
+	}
+}
+
+// write adds the event to the chain and writes it;
+// the caller must hold the lock
+func (a *FileAuditor) write(e *Event) bool {
+	e.Seq = a.seq + 1
+	e.Time = time.Now().UTC()
+	e.PrevHash = a.lastHash
+
+	hash, err := e.hash()
+	if err != nil {
+		logger.Errorf("api=Audit, reason=hash, err=[%v]", err)
+		return false
+	}
+	e.Hash = hash
+
+	if e.Type == EvtCheckpoint && e.Source == EvtSourceAudit {
+		if e.Signature, err = sign(a.signer, hash); err != nil {
+			logger.Errorf("api=Audit, reason=sign, err=[%v]", errors.ErrorStack(err))
+			return false
+		}
+	}
+
+	line, err := marshalLine(e)
+	if err != nil {
+		logger.Errorf("api=Audit, reason=Marshal, err=[%v]", err)
+		return false
+	}
+
+	if _, err = a.writer.Write(line); err != nil {
+		logger.Errorf("api=Audit, reason=Write, err=[%v]", err)
+		return false
+	}
+
+	a.seq = e.Seq
+	a.lastHash = e.Hash
+	return true
+}
+
+// Close writes the last checkpoint and closes the audit file
+func (a *FileAuditor) Close() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.closed {
+		return errors.New("already closed")
+	}
+
+	if a.stop != nil {
+		close(a.stop)
+		a.stop = nil
+	}
+	a.checkpoint()
+
+	a.closed = true
+	return errors.Trace(a.writer.Close())
+}
@@ -0,0 +1,138 @@
+package audit_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-phorce/dolly-test/pkg/audit"
+	"github.com/go-phorce/dolly/xhttp/authz"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder keeps audit events in memory
+type recorder struct {
+	events []*audit.Event
+}
+
+func (a *recorder) Audit(source, eventType, identity, contextID string, raftIndex uint64, message string) {
+	a.events = append(a.events, &audit.Event{
+		Source:    source,
+		Type:      eventType,
+		Identity:  identity,
+		ContextID: contextID,
+		RaftIndex: raftIndex,
+		Message:   message,
+	})
+}
+
+func readEvents(t *testing.T, file string) []*audit.Event {
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var events []*audit.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := new(audit.Event)
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), e), scanner.Text())
+		events = append(events, e)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func Test_FileAuditor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a, err := audit.New(filepath.Join(dir, "logs"), 1, 1)
+	require.NoError(t, err)
+
+	a.Audit("auth", "token issued", "dolly-client/denis", "c1", 0, `user="denis@ekspand.com"`)
+	a.Audit("status", "service started", "node1", "n1", 12, "message with\nnew line")
+	require.NoError(t, a.Close())
+
+	err = a.Close()
+	require.Error(t, err)
+	assert.Equal(t, "already closed", err.Error())
+
+	// the events are not written after close
+	a.Audit("auth", "logout", "dolly-client/denis", "c2", 0, "")
+
+	events := readEvents(t, filepath.Join(dir, "logs", audit.FileName))
+	require.Len(t, events, 2)
+	assert.Equal(t, "auth", events[0].Source)
+	assert.Equal(t, "token issued", events[0].Type)
+	assert.Equal(t, "dolly-client/denis", events[0].Identity)
+	assert.Equal(t, "c1", events[0].ContextID)
+	assert.Equal(t, `user="denis@ekspand.com"`, events[0].Message)
+	assert.False(t, events[0].Time.IsZero())
+	assert.Equal(t, uint64(12), events[1].RaftIndex)
+	assert.Equal(t, "message with\nnew line", events[1].Message)
+}
+
+func Test_ForRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/team", nil)
+	r.Header.Set(header.XCorrelationID, "corr1")
+	r = identity.WithTestIdentity(r, identity.NewIdentity("dolly-admin", "denis", ""))
+
+	// nil auditor is ignored
+	audit.ForRequest(nil, r, "teams", "team created", "id=1")
+
+	a := new(recorder)
+	audit.ForRequest(a, r, "teams", "team created", "id=1")
+	require.Len(t, a.events, 1)
+	assert.Equal(t, "teams", a.events[0].Source)
+	assert.Equal(t, "team created", a.events[0].Type)
+	assert.Equal(t, "dolly-admin/denis", a.events[0].Identity)
+	assert.Equal(t, "corr1", a.events[0].ContextID)
+	assert.Equal(t, "id=1", a.events[0].Message)
+}
+
+func Test_Authz(t *testing.T) {
+	az, err := authz.New(&authz.Config{
+		Allow:    []string{"/v1/team:dolly-admin"},
+		AllowAny: []string{"/v1/status"},
+	})
+	require.NoError(t, err)
+
+	a := new(recorder)
+	azp := audit.NewAuthz(az, a)
+	azp.SetRoleMapper(func(r *http.Request) string {
+		return identity.ForRequest(r).Identity().Role()
+	})
+
+	h, err := azp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	require.NoError(t, err)
+
+	call := func(path, role string) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r = identity.WithTestIdentity(r, identity.NewIdentity(role, "denis", ""))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, call("/v1/team", "dolly-admin"))
+	assert.Equal(t, http.StatusNoContent, call("/v1/status", "guest"))
+	assert.Empty(t, a.events)
+
+	assert.Equal(t, http.StatusUnauthorized, call("/v1/team/123", "dolly-client"))
+	require.Len(t, a.events, 1)
+	assert.Equal(t, audit.EvtSourceAuthz, a.events[0].Source)
+	assert.Equal(t, audit.EvtAccessDenied, a.events[0].Type)
+	assert.Equal(t, "dolly-client/denis", a.events[0].Identity)
+	assert.Equal(t, "method=GET, path=/v1/team/123, status=401", a.events[0].Message)
+}
@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp"
+	"github.com/juju/errors"
+)
+
+const (
+	// EvtSourceAuthz specifies source for authorization events
+	EvtSourceAuthz = "authz"
+	// EvtAccessDenied specifies access denied event
+	EvtAccessDenied = "access denied"
+)
+
+// authz records denied requests of the authorization provider
+type authz struct {
+	rest.Authz
+	auditor Auditor
+}
+
+// NewAuthz returns rest.Authz, which records the requests denied by the provider
+func NewAuthz(az rest.Authz, auditor Auditor) rest.Authz {
+	return &authz{
+		Authz:   az,
+		auditor: auditor,
+	}
+}
+
+// NewHandler returns a http.Handler that enforces the authorization
+// and records denied requests
+func (a *authz) NewHandler(delegate http.Handler) (http.Handler, error) {
+	h, err := a.Authz.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dc, ok := w.(*deniedCapture); ok {
+			dc.allowed = true
+			w = dc.delegate
+		}
+		delegate.ServeHTTP(w, r)
+	}))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := &deniedCapture{ResponseCapture: xhttp.NewResponseCapture(w), delegate: w}
+		h.ServeHTTP(rc, r)
+
+		if !rc.allowed {
+			ForRequest(a.auditor, r, EvtSourceAuthz, EvtAccessDenied,
+				fmt.Sprintf("method=%s, path=%s, status=%d", r.Method, r.URL.Path, rc.StatusCode()))
+		}
+	}), nil
+}
+
+// deniedCapture captures the status of denied request,
+// the allowed request is passed to the delegate with the original writer
+type deniedCapture struct {
+	*xhttp.ResponseCapture
+	delegate http.ResponseWriter
+	allowed  bool
+}
@@ -0,0 +1,248 @@
+package audit
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// EvtSourceAudit specifies source for events of the audit log
+	EvtSourceAudit = "audit"
+	// EvtCheckpoint specifies signed checkpoint event
+	EvtCheckpoint = "checkpoint"
+)
+
+// maxLine limits the size of audit record
+const maxLine = 1024 * 1024
+
+// hash returns hex encoded SHA-256 of the event without its hash and signature
+func (e *Event) hash() (string, error) {
+	c := *e
+	c.Hash = ""
+	c.Signature = ""
+
+	js, err := json.Marshal(&c)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	h := sha256.Sum256(js)
+	return hex.EncodeToString(h[:]), nil
+}
+
+func marshalLine(e *Event) ([]byte, error) {
+	js, err := json.Marshal(e)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return append(js, '\n'), nil
+}
+
+func checkpointMessage(records int) string {
+	return fmt.Sprintf("records=%d", records)
+}
+
+// sign returns base64 encoded signature of the hash
+func sign(signer crypto.Signer, hash string) (string, error) {
+	digest, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	sig, err := signer.Sign(rand.Reader, digest, crypto.SHA256)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// verifySignature verifies the signature of the hash with RSA or ECDSA public key
+func verifySignature(pub crypto.PublicKey, hash, signature string) error {
+	digest, err := hex.DecodeString(hash)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return errors.Trace(rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig))
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	return errors.NotSupportedf("key of %T type", pub)
+}
+
+// Files returns the audit files in the folder, from the oldest to the current one
+func Files(folder string) ([]string, error) {
+	infos, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// the rotated files are named as audit-2006-01-02T15-04-05.000.log
+	prefix := strings.TrimSuffix(FileName, filepath.Ext(FileName)) + "-"
+	ext := filepath.Ext(FileName)
+
+	var files []string
+	current := ""
+	for _, fi := range infos {
+		name := fi.Name()
+		switch {
+		case fi.IsDir():
+		case name == FileName:
+			current = filepath.Join(folder, name)
+		case strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ext):
+			files = append(files, filepath.Join(folder, name))
+		}
+	}
+	sort.Strings(files)
+	if current != "" {
+		files = append(files, current)
+	}
+	return files, nil
+}
+
+// readEvents calls fn for each event in the file, with its line number
+func readEvents(file string, fn func(line int, e *Event, err error) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		e := new(Event)
+		err = json.Unmarshal(scanner.Bytes(), e)
+		if err = fn(line, e, err); err != nil {
+			return err
+		}
+	}
+	return errors.Trace(scanner.Err())
+}
+
+// lastEvent returns the last event in the folder, or nil if there are no events
+func lastEvent(folder string) (*Event, error) {
+	files, err := Files(folder)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		var last *Event
+		err = readEvents(files[i], func(line int, e *Event, err error) error {
+			if err != nil {
+				return errors.Annotatef(err, "%s:%d", filepath.Base(files[i]), line)
+			}
+			last = e
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if last != nil {
+			return last, nil
+		}
+	}
+	return nil, nil
+}
+
+// VerifyResult provides the result of the audit log verification
+type VerifyResult struct {
+	Files       int
+	Records     int
+	Checkpoints int
+	// Unsigned is the number of records after the last checkpoint
+	Unsigned int
+}
+
+// Verify walks the audit files in the folder, and returns the error
+// for the first broken link or bad signature of a checkpoint.
+// The checkpoints are verified with the public key, which can be nil
+// if the log has no checkpoints.
+func Verify(folder string, pub crypto.PublicKey) (*VerifyResult, error) {
+	files, err := Files(folder)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(files) == 0 {
+		return nil, errors.NotFoundf("audit files in %q", folder)
+	}
+
+	res := &VerifyResult{}
+	var prev *Event
+
+	for _, file := range files {
+		res.Files++
+		name := filepath.Base(file)
+
+		err = readEvents(file, func(line int, e *Event, err error) error {
+			if err != nil {
+				return errors.Errorf("%s:%d: invalid record: %v", name, line, err)
+			}
+
+			hash, err := e.hash()
+			if err != nil {
+				return errors.Annotatef(err, "%s:%d", name, line)
+			}
+			if hash != e.Hash {
+				return errors.Errorf("%s:%d: record %d is modified: hash does not match", name, line, e.Seq)
+			}
+
+			// the first record may follow the records in deleted files
+			if prev != nil {
+				if e.Seq != prev.Seq+1 {
+					return errors.Errorf("%s:%d: broken link: record %d follows record %d", name, line, e.Seq, prev.Seq)
+				}
+				if e.PrevHash != prev.Hash {
+					return errors.Errorf("%s:%d: broken link: prev_hash of record %d does not match", name, line, e.Seq)
+				}
+			}
+
+			res.Records++
+			if e.Source == EvtSourceAudit && e.Type == EvtCheckpoint {
+				if pub == nil {
+					return errors.Errorf("%s:%d: public key is required to verify checkpoint %d", name, line, e.Seq)
+				}
+				if err := verifySignature(pub, e.Hash, e.Signature); err != nil {
+					return errors.Errorf("%s:%d: bad signature of checkpoint %d: %v", name, line, e.Seq, err)
+				}
+				res.Checkpoints++
+				res.Unsigned = 0
+			} else {
+				res.Unsigned++
+			}
+
+			prev = e
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
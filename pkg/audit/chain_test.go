@@ -0,0 +1,202 @@
+package audit_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/pkg/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeEvents(t *testing.T, file string, events []*audit.Event) {
+	var lines []string
+	for _, e := range events {
+		js, err := json.Marshal(e)
+		require.NoError(t, err)
+		lines = append(lines, string(js))
+	}
+	require.NoError(t, ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+}
+
+func Test_Chain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	a, err := audit.New(dir, 1, 1)
+	require.NoError(t, err)
+	a.Audit("auth", "token issued", "dolly-client/denis", "c1", 0, "")
+	a.Audit("auth", "logout", "dolly-client/denis", "c2", 0, "")
+	require.NoError(t, a.Close())
+
+	// the chain is continued after restart
+	a, err = audit.New(dir, 1, 1)
+	require.NoError(t, err)
+	a.Audit("auth", "token issued", "dolly-client/denis", "c3", 0, "")
+	require.NoError(t, a.Close())
+
+	file := filepath.Join(dir, audit.FileName)
+	events := readEvents(t, file)
+	require.Len(t, events, 3)
+	assert.Empty(t, events[0].PrevHash)
+	for i, e := range events {
+		assert.Equal(t, uint64(i+1), e.Seq)
+		assert.NotEmpty(t, e.Hash)
+		assert.Empty(t, e.Signature)
+		if i > 0 {
+			assert.Equal(t, events[i-1].Hash, e.PrevHash)
+		}
+	}
+
+	res, err := audit.Verify(dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Files)
+	assert.Equal(t, 3, res.Records)
+	assert.Equal(t, 0, res.Checkpoints)
+	assert.Equal(t, 3, res.Unsigned)
+
+	t.Run("modified", func(t *testing.T) {
+		modified := readEvents(t, file)
+		modified[1].Message = "changed"
+		writeEvents(t, file, modified)
+
+		_, err := audit.Verify(dir, nil)
+		require.Error(t, err)
+		assert.Equal(t, "audit.log:2: record 2 is modified: hash does not match", err.Error())
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		writeEvents(t, file, []*audit.Event{events[0], events[2]})
+
+		_, err := audit.Verify(dir, nil)
+		require.Error(t, err)
+		assert.Equal(t, "audit.log:2: broken link: record 3 follows record 1", err.Error())
+	})
+
+	// the rotated file is not older than MaxAge,
+	// otherwise the rotation removes it in background
+	rotated := filepath.Join(dir, "audit-"+time.Now().UTC().Add(-time.Minute).Format("2006-01-02T15-04-05.000")+".log")
+
+	t.Run("rotated", func(t *testing.T) {
+		writeEvents(t, rotated, events[:2])
+		writeEvents(t, file, events[2:])
+
+		res, err := audit.Verify(dir, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, res.Files)
+		assert.Equal(t, 3, res.Records)
+	})
+
+	t.Run("oldest removed", func(t *testing.T) {
+		require.NoError(t, os.Remove(rotated))
+
+		res, err := audit.Verify(dir, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, res.Records)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		empty, err := ioutil.TempDir("", "audit")
+		require.NoError(t, err)
+		defer os.RemoveAll(empty)
+
+		_, err = audit.Verify(empty, nil)
+		require.Error(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "audit files in"), err.Error())
+	})
+}
+
+func Test_Checkpoints(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		dir, err := ioutil.TempDir("", "audit")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		a, err := audit.New(dir, 1, 1)
+		require.NoError(t, err)
+		a.StartCheckpoints(key, 50*time.Millisecond)
+
+		a.Audit("auth", "token issued", "dolly-client/denis", "c1", 0, "")
+		time.Sleep(200 * time.Millisecond)
+		a.Audit("auth", "logout", "dolly-client/denis", "c2", 0, "")
+		require.NoError(t, a.Close())
+
+		events := readEvents(t, filepath.Join(dir, audit.FileName))
+		// the checkpoint is not written without new records
+		require.Len(t, events, 4)
+		for _, i := range []int{1, 3} {
+			assert.Equal(t, audit.EvtSourceAudit, events[i].Source)
+			assert.Equal(t, audit.EvtCheckpoint, events[i].Type)
+			assert.Equal(t, "records=1", events[i].Message)
+			assert.NotEmpty(t, events[i].Signature)
+		}
+
+		res, err := audit.Verify(dir, key.Public())
+		require.NoError(t, err)
+		assert.Equal(t, 4, res.Records)
+		assert.Equal(t, 2, res.Checkpoints)
+		assert.Equal(t, 0, res.Unsigned)
+
+		_, err = audit.Verify(dir, nil)
+		require.Error(t, err)
+		assert.Equal(t, "audit.log:2: public key is required to verify checkpoint 2", err.Error())
+
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		_, err = audit.Verify(dir, other.Public())
+		require.Error(t, err)
+		assert.True(t, strings.HasPrefix(err.Error(), "audit.log:2: bad signature of checkpoint 2"), err.Error())
+	}
+}
+
+func Test_LoadKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "audit-key.pem")
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+
+	der, err = x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	pubFile := filepath.Join(dir, "audit-pub.pem")
+	require.NoError(t, ioutil.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	signer, err := audit.LoadSigner(keyFile, nil)
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), signer.Public())
+
+	for _, file := range []string{keyFile, pubFile} {
+		pub, err := audit.LoadPublicKey(file)
+		require.NoError(t, err)
+		assert.Equal(t, key.Public(), pub)
+	}
+
+	_, err = audit.LoadSigner(filepath.Join(dir, "missing.pem"), nil)
+	require.Error(t, err)
+	_, err = audit.LoadPublicKey(filepath.Join(dir, "missing.pem"))
+	require.Error(t, err)
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"strings"
+
+	"github.com/go-phorce/dolly/xpki/cryptoprov"
+	"github.com/juju/errors"
+)
+
+// LoadSigner returns the key to sign checkpoints from the file,
+// which contains PEM-encoded private key or PKCS#11 URI of the key in HSM
+func LoadSigner(file string, prov *cryptoprov.Crypto) (crypto.Signer, error) {
+	key, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	key = []byte(strings.TrimSpace(string(key)))
+
+	var pvk crypto.PrivateKey
+	if prov != nil {
+		_, pvk, err = prov.LoadPrivateKey(key)
+	} else if strings.HasPrefix(string(key), "pkcs11") {
+		err = errors.Errorf("crypto provider is required for PKCS#11 key")
+	} else {
+		pvk, err = cryptoprov.ParsePrivateKeyPEM(key)
+	}
+	if err != nil {
+		return nil, errors.Annotatef(err, "unable to load audit signing key from %q", file)
+	}
+
+	signer, ok := pvk.(crypto.Signer)
+	if !ok {
+		return nil, errors.NotSupportedf("audit signing key of %T type", pvk)
+	}
+	return signer, nil
+}
+
+// LoadPublicKey returns the key to verify checkpoints from the file,
+// which contains PEM-encoded certificate, public or private key
+func LoadPublicKey(file string) (crypto.PublicKey, error) {
+	pemBytes, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.NotValidf("PEM in %q", file)
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Annotatef(err, "unable to parse certificate from %q", file)
+		}
+		return crt.PublicKey, nil
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Annotatef(err, "unable to parse public key from %q", file)
+		}
+		return pub, nil
+	}
+
+	pvk, err := cryptoprov.ParsePrivateKeyPEM(pemBytes)
+	if err != nil {
+		return nil, errors.Annotatef(err, "unable to load key from %q", file)
+	}
+	signer, ok := pvk.(crypto.Signer)
+	if !ok {
+		return nil, errors.NotSupportedf("key of %T type", pvk)
+	}
+	return signer.Public(), nil
+}
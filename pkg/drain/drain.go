@@ -0,0 +1,109 @@
+package drain
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/juju/errors"
+)
+
+// DefaultTimeout specifies the deadline to drain in-flight requests
+const DefaultTimeout = 30 * time.Second
+
+// DefaultUpstartWait specifies how long to wait for SIGTERM after SIGUSR2
+const DefaultUpstartWait = 15 * time.Second
+
+// pollInterval specifies how often the in-flight requests are checked while draining
+const pollInterval = 50 * time.Millisecond
+
+// Tracker counts in-flight requests, and provides the draining state on shutdown
+type Tracker struct {
+	inflight int64
+	draining int32
+}
+
+// New returns Tracker
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// InFlight returns the number of requests being served
+func (t *Tracker) InFlight() int64 {
+	return atomic.LoadInt64(&t.inflight)
+}
+
+// IsDraining returns true after Start is called,
+// the readiness check should report the service as not ready
+func (t *Tracker) IsDraining() bool {
+	return atomic.LoadInt32(&t.draining) == 1
+}
+
+// Start starts draining
+func (t *Tracker) Start() {
+	atomic.StoreInt32(&t.draining, 1)
+}
+
+// Wait blocks until there are no in-flight requests or the context is done,
+// and returns the number of requests still being served
+func (t *Tracker) Wait(ctx context.Context) int64 {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		n := t.InFlight()
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return t.InFlight()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handler returns the handler, which counts in-flight requests of the delegate
+func (t *Tracker) Handler(delegate http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&t.inflight, 1)
+		defer atomic.AddInt64(&t.inflight, -1)
+		delegate.ServeHTTP(w, r)
+	})
+}
+
+// authz counts in-flight requests of the server,
+// the authorization provider is optional
+type authz struct {
+	rest.Authz
+	tracker *Tracker
+}
+
+// NewAuthz returns rest.Authz, which counts in-flight requests,
+// it is used as the hook into the handlers chain of rest.Server,
+// the az can be nil if the authorization is not configured
+func (t *Tracker) NewAuthz(az rest.Authz) rest.Authz {
+	return &authz{Authz: az, tracker: t}
+}
+
+// SetRoleMapper configures the role mapper of the authorization provider
+func (a *authz) SetRoleMapper(m func(*http.Request) string) {
+	if a.Authz != nil {
+		a.Authz.SetRoleMapper(m)
+	}
+}
+
+// NewHandler returns a http.Handler that enforces the authorization
+// and counts in-flight requests
+func (a *authz) NewHandler(delegate http.Handler) (http.Handler, error) {
+	if a.Authz != nil {
+		var err error
+		delegate, err = a.Authz.NewHandler(delegate)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return a.tracker.Handler(delegate), nil
+}
@@ -0,0 +1,100 @@
+package drain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// denyAll is the authorization provider, which denies all requests
+type denyAll struct {
+	rest.Authz
+	roleMapper func(*http.Request) string
+}
+
+func (d *denyAll) SetRoleMapper(m func(*http.Request) string) {
+	d.roleMapper = m
+}
+
+func (d *denyAll) NewHandler(delegate http.Handler) (http.Handler, error) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}), nil
+}
+
+func Test_Tracker(t *testing.T) {
+	tracker := New()
+	assert.False(t, tracker.IsDraining())
+	assert.Equal(t, int64(0), tracker.InFlight())
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	h := tracker.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/teams", nil))
+			done <- struct{}{}
+		}()
+		<-entered
+	}
+	assert.Equal(t, int64(2), tracker.InFlight())
+
+	tracker.Start()
+	assert.True(t, tracker.IsDraining())
+
+	// the deadline is exceeded
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, int64(2), tracker.Wait(ctx))
+
+	close(release)
+	<-done
+	<-done
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second)
+	defer cancel2()
+	assert.Equal(t, int64(0), tracker.Wait(ctx2))
+}
+
+func Test_NewAuthz(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		az := New().NewAuthz(nil)
+		az.SetRoleMapper(func(*http.Request) string { return "admin" })
+
+		h, err := az.NewHandler(ok)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/teams", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("delegate", func(t *testing.T) {
+		deny := &denyAll{}
+		az := New().NewAuthz(deny)
+		az.SetRoleMapper(func(*http.Request) string { return "admin" })
+		assert.NotNil(t, deny.roleMapper)
+
+		h, err := az.NewHandler(ok)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/teams", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
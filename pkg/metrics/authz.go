@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/metrics/tags"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/juju/errors"
+)
+
+var (
+	keyForAuthzAllowed = []string{"authz", "allowed"}
+	keyForAuthzDenied  = []string{"authz", "denied"}
+)
+
+// countingAuthz counts allowed and denied requests of the authorization provider
+type countingAuthz struct {
+	rest.Authz
+}
+
+// NewAuthz returns rest.Authz, which counts allowed and denied requests per role
+func NewAuthz(az rest.Authz) rest.Authz {
+	return &countingAuthz{Authz: az}
+}
+
+// allowedKey is the context key of the flag set by the allowed handler
+type allowedKey struct{}
+
+// NewHandler returns a http.Handler that enforces the authorization
+// and counts the requests
+func (a *countingAuthz) NewHandler(delegate http.Handler) (http.Handler, error) {
+	h, err := a.Authz.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allowed, ok := r.Context().Value(allowedKey{}).(*bool); ok {
+			*allowed = true
+		}
+		delegate.ServeHTTP(w, r)
+	}))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := false
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), allowedKey{}, &allowed)))
+
+		tag := metrics.Tag{Name: tags.Role, Value: identity.ForRequest(r).Identity().Role()}
+		if allowed {
+			metrics.IncrCounter(keyForAuthzAllowed, 1, tag)
+		} else {
+			metrics.IncrCounter(keyForAuthzDenied, 1, tag)
+		}
+	}), nil
+}
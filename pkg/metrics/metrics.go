@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/juju/errors"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/pkg", "metrics")
+
+// Supported metrics providers
+const (
+	ProviderInmemory = "inmemory"
+	ProviderStatsd   = "statsd"
+	ProviderDatadog  = "datadog"
+)
+
+// DefaultAddress specifies the default address of statsd or datadog agent
+const DefaultAddress = "127.0.0.1:8125"
+
+// Tags of the metrics
+const (
+	TagDatacenter  = "datacenter"
+	TagEnvironment = "environment"
+	TagService     = "service"
+	TagRoute       = "route"
+)
+
+// in-memory sink keeps the last minute of 10 seconds intervals
+const (
+	inmemInterval = 10 * time.Second
+	inmemRetain   = time.Minute
+)
+
+// Config provides configuration of the metrics pipeline
+type Config struct {
+	// ServiceName is added as the prefix of the metrics keys
+	ServiceName string
+	// Provider specifies the sink: inmemory|statsd|datadog|prometheus
+	Provider string
+	// Address specifies the address of statsd or datadog agent
+	Address string
+	// Tags are added to all metrics
+	Tags []metrics.Tag
+}
+
+// Pipeline is the global metrics pipeline
+type Pipeline struct {
+	*metrics.Metrics
+	sink       metrics.Sink
+	prometheus *PrometheusSink
+}
+
+// Sink returns the sink of the pipeline
+func (p *Pipeline) Sink() metrics.Sink {
+	return p.sink
+}
+
+// Prometheus returns the sink to be collected by Prometheus,
+// or nil if the provider is not prometheus
+func (p *Pipeline) Prometheus() *PrometheusSink {
+	return p.prometheus
+}
+
+// Close stops flushing the metrics
+func (p *Pipeline) Close() error {
+	if s, ok := p.sink.(interface{ Shutdown() }); ok {
+		s.Shutdown()
+	}
+	return nil
+}
+
+// NewSink returns the sink for the provider
+func NewSink(provider, address string) (metrics.Sink, error) {
+	if address == "" {
+		address = DefaultAddress
+	}
+
+	switch strings.ToLower(provider) {
+	case "", ProviderInmemory:
+		return metrics.NewInmemSink(inmemInterval, inmemRetain), nil
+	case ProviderStatsd:
+		sink, err := metrics.NewStatsdSink(address)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return sink, nil
+	case ProviderPrometheus:
+		return NewPrometheusSink(), nil
+	case ProviderDatadog:
+		host, _ := os.Hostname()
+		sink, err := metrics.NewDogStatsdSink(address, host)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return sink, nil
+	}
+	return nil, errors.NotSupportedf("metrics provider %q", provider)
+}
+
+// New creates the sink for the configured provider,
+// and sets the pipeline as the global metrics
+func New(cfg *Config) (*Pipeline, error) {
+	sink, err := NewSink(cfg.Provider, cfg.Address)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	prometheus, _ := sink.(*PrometheusSink)
+	if len(cfg.Tags) > 0 {
+		sink = &taggedSink{Sink: sink, tags: cfg.Tags}
+	}
+
+	mcfg := metrics.DefaultConfig(cfg.ServiceName)
+	// the host is added as a tag to aggregate the metrics across the hosts
+	mcfg.EnableHostname = false
+	mcfg.EnableHostnameLabel = true
+
+	m, err := metrics.NewGlobal(mcfg, sink)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	logger.Infof("api=metrics.New, provider=%q, service=%q, tags=%v", cfg.Provider, cfg.ServiceName, cfg.Tags)
+	return &Pipeline{Metrics: m, sink: sink, prometheus: prometheus}, nil
+}
+
+// taggedSink adds the tags to all metrics
+type taggedSink struct {
+	metrics.Sink
+	tags []metrics.Tag
+}
+
+func (s *taggedSink) with(tags []metrics.Tag) []metrics.Tag {
+	return append(append(make([]metrics.Tag, 0, len(tags)+len(s.tags)), tags...), s.tags...)
+}
+
+func (s *taggedSink) SetGauge(key []string, val float32, tags []metrics.Tag) {
+	s.Sink.SetGauge(key, val, s.with(tags))
+}
+
+func (s *taggedSink) IncrCounter(key []string, val float32, tags []metrics.Tag) {
+	s.Sink.IncrCounter(key, val, s.with(tags))
+}
+
+func (s *taggedSink) AddSample(key []string, val float32, tags []metrics.Tag) {
+	s.Sink.AddSample(key, val, s.with(tags))
+}
+
+// Shutdown stops the wrapped sink
+func (s *taggedSink) Shutdown() {
+	if sink, ok := s.Sink.(interface{ Shutdown() }); ok {
+		sink.Shutdown()
+	}
+}
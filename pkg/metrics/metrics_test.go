@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewSink(t *testing.T) {
+	for _, provider := range []string{"", ProviderInmemory} {
+		sink, err := NewSink(provider, "")
+		require.NoError(t, err)
+		assert.IsType(t, &metrics.InmemSink{}, sink)
+	}
+
+	sink, err := NewSink(ProviderStatsd, "")
+	require.NoError(t, err)
+	assert.IsType(t, &metrics.StatsdSink{}, sink)
+	sink.(*metrics.StatsdSink).Shutdown()
+
+	sink, err = NewSink(ProviderDatadog, "127.0.0.1:8125")
+	require.NoError(t, err)
+	assert.IsType(t, &metrics.DogStatsdSink{}, sink)
+
+	_, err = NewSink("graphite", "")
+	require.Error(t, err)
+	assert.True(t, errors.IsNotSupported(err))
+}
+
+// keys returns the keys of counters and samples in the in-memory sink
+func keys(p *Pipeline) []string {
+	var list []string
+	for _, intv := range p.Sink().(*taggedSink).Sink.(*metrics.InmemSink).Data() {
+		intv.RLock()
+		for k := range intv.Counters {
+			list = append(list, "counter:"+k)
+		}
+		for k := range intv.Samples {
+			list = append(list, "sample:"+k)
+		}
+		intv.RUnlock()
+	}
+	return list
+}
+
+func hasKey(list []string, prefix string, parts ...string) bool {
+	for _, k := range list {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		found := true
+		for _, part := range parts {
+			if !strings.Contains(k, part) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func Test_Pipeline(t *testing.T) {
+	p, err := New(&Config{
+		ServiceName: "dolly-test",
+		Provider:    ProviderInmemory,
+		Tags: []metrics.Tag{
+			{Name: TagDatacenter, Value: "dc1"},
+			{Name: TagEnvironment, Value: "test"},
+		},
+	})
+	require.NoError(t, err)
+	defer p.Close()
+
+	r := NewRouter("teams", rest.NewRouter(nil))
+	r.GET("/v1/teams/:id", func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	r.DELETE("/v1/teams/:id", func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		w := httptest.NewRecorder()
+		r.Handler().ServeHTTP(w, httptest.NewRequest(method, "/v1/teams/123", nil))
+	}
+
+	list := keys(p)
+	assert.True(t, hasKey(list, "counter:dolly-test.http.route.requests;",
+		"service=teams", "method=GET", "route=/v1/teams/:id", "status=204", "datacenter=dc1", "environment=test"), "%v", list)
+	assert.True(t, hasKey(list, "sample:dolly-test.http.route.latency;",
+		"method=GET", "route=/v1/teams/:id", "status=204"), "%v", list)
+	assert.True(t, hasKey(list, "counter:dolly-test.http.route.requests;",
+		"method=DELETE", "route=/v1/teams/:id", "status=404"), "%v", list)
+	assert.False(t, hasKey(list, "counter:", "/v1/teams/123"), "%v", list)
+}
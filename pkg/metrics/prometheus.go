@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/xhttp/header"
+)
+
+// ProviderPrometheus specifies the sink, which is exposed to Prometheus by HTTP endpoint
+const ProviderPrometheus = "prometheus"
+
+// prometheusContentType is the content type of the text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets specifies the upper bounds of the histogram buckets,
+// the timings are measured in milliseconds
+var DefaultBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// Prometheus metric types
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// PrometheusSink keeps the last values of gauges, the sum of counters,
+// and histograms of samples, to be collected by Prometheus
+type PrometheusSink struct {
+	buckets []float64
+
+	lock     sync.Mutex
+	families map[string]*family
+}
+
+// family is the metric with its series
+type family struct {
+	name   string
+	kind   string
+	series map[string]*series
+}
+
+// series is the metric value with the set of labels
+type series struct {
+	labels string
+	value  float64
+	// histogram
+	counts []uint64
+	count  uint64
+}
+
+// NewPrometheusSink returns the sink with DefaultBuckets
+func NewPrometheusSink() *PrometheusSink {
+	return &PrometheusSink{
+		buckets:  DefaultBuckets,
+		families: map[string]*family{},
+	}
+}
+
+// SetGauge should retain the last value it is set to
+func (s *PrometheusSink) SetGauge(key []string, val float32, tags []metrics.Tag) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.series(metricName(key, ""), typeGauge, tags).value = float64(val)
+}
+
+// IncrCounter should accumulate values
+func (s *PrometheusSink) IncrCounter(key []string, val float32, tags []metrics.Tag) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.series(metricName(key, "_total"), typeCounter, tags).value += float64(val)
+}
+
+// AddSample is for timing information, the samples are added to the histogram
+func (s *PrometheusSink) AddSample(key []string, val float32, tags []metrics.Tag) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ser := s.series(metricName(key, ""), typeHistogram, tags)
+	if ser.counts == nil {
+		ser.counts = make([]uint64, len(s.buckets))
+	}
+	v := float64(val)
+	for i, le := range s.buckets {
+		if v <= le {
+			ser.counts[i]++
+		}
+	}
+	ser.count++
+	ser.value += v
+}
+
+// series returns the series of the metric, the caller must hold the lock
+func (s *PrometheusSink) series(name, kind string, tags []metrics.Tag) *series {
+	f := s.families[name]
+	if f == nil {
+		f = &family{name: name, kind: kind, series: map[string]*series{}}
+		s.families[name] = f
+	}
+
+	labels := formatLabels(tags)
+	ser := f.series[labels]
+	if ser == nil {
+		ser = &series{labels: labels}
+		f.series[labels] = ser
+	}
+	return ser
+}
+
+// ServeHTTP writes the metrics in the text exposition format
+func (s *PrometheusSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(header.ContentType, prometheusContentType)
+	w.Write(s.Expose())
+}
+
+// Expose returns the metrics in the text exposition format
+func (s *PrometheusSink) Expose() []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := make([]string, 0, len(s.families))
+	for name := range s.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := new(bytes.Buffer)
+	for _, name := range names {
+		f := s.families[name]
+		fmt.Fprintf(b, "# TYPE %s %s\n", name, f.kind)
+
+		labels := make([]string, 0, len(f.series))
+		for l := range f.series {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+
+		for _, l := range labels {
+			ser := f.series[l]
+			if f.kind != typeHistogram {
+				fmt.Fprintf(b, "%s%s %s\n", name, braces(l), formatFloat(ser.value))
+				continue
+			}
+			for i, le := range s.buckets {
+				fmt.Fprintf(b, "%s_bucket%s %d\n", name, braces(join(l, `le="`+formatFloat(le)+`"`)), ser.counts[i])
+			}
+			fmt.Fprintf(b, "%s_bucket%s %d\n", name, braces(join(l, `le="+Inf"`)), ser.count)
+			fmt.Fprintf(b, "%s_sum%s %s\n", name, braces(l), formatFloat(ser.value))
+			fmt.Fprintf(b, "%s_count%s %d\n", name, braces(l), ser.count)
+		}
+	}
+	return b.Bytes()
+}
+
+// metricName returns the key joined by underscore,
+// with characters not allowed by Prometheus replaced
+func metricName(key []string, suffix string) string {
+	name := sanitizeName(strings.Join(key, "_"))
+	if suffix != "" && !strings.HasSuffix(name, suffix) {
+		name += suffix
+	}
+	return name
+}
+
+func sanitizeName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || (c >= '0' && c <= '9' && i > 0)) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// formatLabels returns the labels sorted by name, the last value of the duplicated label is used
+func formatLabels(tags []metrics.Tag) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	values := map[string]string{}
+	for _, t := range tags {
+		values[sanitizeName(t.Name)] = t.Value
+	}
+	names := make([]string, 0, len(values))
+	for n := range values {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	list := make([]string, len(names))
+	for i, n := range names {
+		list[i] = n + `="` + labelValueEscaper.Replace(values[n]) + `"`
+	}
+	return strings.Join(list, ",")
+}
+
+func join(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
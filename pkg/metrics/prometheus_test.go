@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/xhttp/authz"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PrometheusSink(t *testing.T) {
+	s := NewPrometheusSink()
+	s.buckets = []float64{10, 100}
+
+	s.IncrCounter([]string{"dolly-demo", "http", "route", "requests"}, 1, []metrics.Tag{{Name: "route", Value: "/v1/teams"}, {Name: "method", Value: "GET"}})
+	s.IncrCounter([]string{"dolly-demo", "http", "route", "requests"}, 2, []metrics.Tag{{Name: "method", Value: "GET"}, {Name: "route", Value: "/v1/teams"}})
+	s.IncrCounter([]string{"dolly-demo", "heartbeat"}, 1, nil)
+	s.SetGauge([]string{"dolly-demo", "uptime", "seconds"}, 10, []metrics.Tag{{Name: "service", Value: "health"}})
+	s.SetGauge([]string{"dolly-demo", "uptime", "seconds"}, 20, []metrics.Tag{{Name: "service", Value: "health"}})
+	s.SetGauge([]string{"message"}, 1, []metrics.Tag{{Name: "text", Value: "a \"quoted\"\nline"}})
+	s.AddSample([]string{"dolly-demo", "http", "route", "latency"}, 5, nil)
+	s.AddSample([]string{"dolly-demo", "http", "route", "latency"}, 50, nil)
+	s.AddSample([]string{"dolly-demo", "http", "route", "latency"}, 500, nil)
+
+	exp := `# TYPE dolly_demo_heartbeat_total counter
+dolly_demo_heartbeat_total 1
+# TYPE dolly_demo_http_route_latency histogram
+dolly_demo_http_route_latency_bucket{le="10"} 1
+dolly_demo_http_route_latency_bucket{le="100"} 2
+dolly_demo_http_route_latency_bucket{le="+Inf"} 3
+dolly_demo_http_route_latency_sum 555
+dolly_demo_http_route_latency_count 3
+# TYPE dolly_demo_http_route_requests_total counter
+dolly_demo_http_route_requests_total{method="GET",route="/v1/teams"} 3
+# TYPE dolly_demo_uptime_seconds gauge
+dolly_demo_uptime_seconds{service="health"} 20
+# TYPE message gauge
+message{text="a \"quoted\"\nline"} 1
+`
+	assert.Equal(t, exp, string(s.Expose()))
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, prometheusContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, exp, w.Body.String())
+}
+
+func Test_Authz(t *testing.T) {
+	p, err := New(&Config{ServiceName: "dolly-test", Provider: ProviderPrometheus})
+	require.NoError(t, err)
+	require.NotNil(t, p.Prometheus())
+
+	az, err := authz.New(&authz.Config{
+		Allow: []string{"/v1/team:dolly-admin"},
+	})
+	require.NoError(t, err)
+
+	azp := NewAuthz(az)
+	azp.SetRoleMapper(func(r *http.Request) string {
+		return identity.ForRequest(r).Identity().Role()
+	})
+	h, err := azp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	require.NoError(t, err)
+
+	call := func(role string) int {
+		r := httptest.NewRequest(http.MethodGet, "/v1/team", nil)
+		r = identity.WithTestIdentity(r, identity.NewIdentity(role, "denis", ""))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusNoContent, call("dolly-admin"))
+	assert.Equal(t, http.StatusNoContent, call("dolly-admin"))
+	assert.Equal(t, http.StatusUnauthorized, call("dolly-client"))
+
+	out := string(p.Prometheus().Expose())
+	assert.Contains(t, out, "# TYPE dolly_test_authz_allowed_total counter\n")
+	assert.Regexp(t, `dolly_test_authz_allowed_total\{host="[^"]*",role="dolly-admin"\} 2\n`, out)
+	assert.Regexp(t, `dolly_test_authz_denied_total\{host="[^"]*",role="dolly-client"\} 1\n`, out)
+}
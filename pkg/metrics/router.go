@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-phorce/dolly/metrics"
+	"github.com/go-phorce/dolly/metrics/tags"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp"
+)
+
+var (
+	keyForRouteRequests = []string{"http", "route", "requests"}
+	keyForRouteLatency  = []string{"http", "route", "latency"}
+)
+
+// router records request counters and latency of the registered routes,
+// the routes are tagged with the path pattern, not the request URI
+type router struct {
+	rest.Router
+	service string
+}
+
+// NewRouter returns the router, which records per-route metrics
+// of the handlers registered by the service
+func NewRouter(service string, r rest.Router) rest.Router {
+	return &router{Router: r, service: service}
+}
+
+// Handle returns the handler, which records the request counter and latency of the route
+func Handle(service, method, route string, h rest.Handle) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		start := time.Now()
+		rc := xhttp.NewResponseCapture(w)
+		h(rc, r, p)
+
+		t := []metrics.Tag{
+			{Name: TagService, Value: service},
+			{Name: tags.Method, Value: method},
+			{Name: TagRoute, Value: route},
+			{Name: tags.Status, Value: strconv.Itoa(rc.StatusCode())},
+		}
+		metrics.IncrCounter(keyForRouteRequests, 1, t...)
+		metrics.MeasureSince(keyForRouteLatency, start, t...)
+	}
+}
+
+func (r *router) GET(path string, h rest.Handle) {
+	r.Router.GET(path, Handle(r.service, http.MethodGet, path, h))
+}
+
+func (r *router) HEAD(path string, h rest.Handle) {
+	r.Router.HEAD(path, Handle(r.service, http.MethodHead, path, h))
+}
+
+func (r *router) OPTIONS(path string, h rest.Handle) {
+	r.Router.OPTIONS(path, Handle(r.service, http.MethodOptions, path, h))
+}
+
+func (r *router) POST(path string, h rest.Handle) {
+	r.Router.POST(path, Handle(r.service, http.MethodPost, path, h))
+}
+
+func (r *router) PUT(path string, h rest.Handle) {
+	r.Router.PUT(path, Handle(r.service, http.MethodPut, path, h))
+}
+
+func (r *router) PATCH(path string, h rest.Handle) {
+	r.Router.PATCH(path, Handle(r.service, http.MethodPatch, path, h))
+}
+
+func (r *router) DELETE(path string, h rest.Handle) {
+	r.Router.DELETE(path, Handle(r.service, http.MethodDelete, path, h))
+}
@@ -0,0 +1,72 @@
+package reload
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/juju/errors"
+)
+
+// Authz is the authorization provider, which can be replaced
+// while the server is running
+type Authz struct {
+	lock       sync.RWMutex
+	az         rest.Authz
+	roleMapper func(*http.Request) string
+	handlers   handlers
+}
+
+// NewAuthz returns Authz with the provider,
+// the az can be nil if the authorization is not configured
+func NewAuthz(az rest.Authz) *Authz {
+	return &Authz{az: az}
+}
+
+// SetRoleMapper configures the role mapper of the current and future providers
+func (a *Authz) SetRoleMapper(m func(*http.Request) string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.roleMapper = m
+	if a.az != nil {
+		a.az.SetRoleMapper(m)
+	}
+}
+
+// NewHandler returns a http.Handler that enforces the current authorization
+func (a *Authz) NewHandler(delegate http.Handler) (http.Handler, error) {
+	return a.handlers.add(delegate, a.build)
+}
+
+// Set replaces the authorization provider for the handlers,
+// the az can be nil if the authorization is not configured.
+// On error, the handlers keep the current provider.
+func (a *Authz) Set(az rest.Authz) error {
+	a.lock.Lock()
+	prev := a.az
+	a.az = az
+	if az != nil && a.roleMapper != nil {
+		az.SetRoleMapper(a.roleMapper)
+	}
+	a.lock.Unlock()
+
+	if err := a.handlers.rebuild(a.build); err != nil {
+		a.lock.Lock()
+		a.az = prev
+		a.lock.Unlock()
+		return errors.Annotate(err, "failed to apply authorization")
+	}
+	logger.Notice("api=Authz.Set, status=applied")
+	return nil
+}
+
+func (a *Authz) build(delegate http.Handler) (http.Handler, error) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	if a.az == nil {
+		return delegate, nil
+	}
+	return a.az.NewHandler(delegate)
+}
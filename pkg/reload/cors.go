@@ -0,0 +1,99 @@
+package reload
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/juju/errors"
+	"github.com/rs/cors"
+)
+
+// CORS is the CORS middleware, whose options can be replaced
+// while the server is running
+type CORS struct {
+	lock     sync.RWMutex
+	opts     *rest.CORSOptions
+	handlers handlers
+}
+
+// NewCORS returns CORS with the options,
+// the opts can be nil if CORS is not enabled
+func NewCORS(opts *rest.CORSOptions) *CORS {
+	return &CORS{opts: opts}
+}
+
+// Handler returns a http.Handler that handles CORS requests with the current options
+func (c *CORS) Handler(delegate http.Handler) http.Handler {
+	// building of CORS handler never fails
+	h, _ := c.handlers.add(delegate, c.build)
+	return h
+}
+
+// Set replaces the options for the handlers,
+// the opts can be nil if CORS is not enabled
+func (c *CORS) Set(opts *rest.CORSOptions) {
+	c.lock.Lock()
+	c.opts = opts
+	c.lock.Unlock()
+
+	c.handlers.rebuild(c.build)
+	logger.Noticef("api=CORS.Set, enabled=%t", opts != nil)
+}
+
+// NewAuthz returns rest.Authz, which handles CORS requests
+// before the authorization, so the preflight requests are not authorized.
+// It is used as the hook into the handlers chain of rest.Server,
+// the az can be nil if the authorization is not configured
+func (c *CORS) NewAuthz(az rest.Authz) rest.Authz {
+	return &corsAuthz{Authz: az, cors: c}
+}
+
+func (c *CORS) build(delegate http.Handler) (http.Handler, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	opt := c.opts
+	if opt == nil {
+		return delegate, nil
+	}
+	return cors.New(cors.Options{
+		AllowedOrigins:         opt.AllowedOrigins,
+		AllowOriginFunc:        opt.AllowOriginFunc,
+		AllowOriginRequestFunc: opt.AllowOriginRequestFunc,
+		AllowedMethods:         opt.AllowedMethods,
+		AllowedHeaders:         opt.AllowedHeaders,
+		ExposedHeaders:         opt.ExposedHeaders,
+		MaxAge:                 opt.MaxAge,
+		AllowCredentials:       opt.AllowCredentials,
+		OptionsPassthrough:     opt.OptionsPassthrough,
+		Debug:                  opt.Debug,
+	}).Handler(delegate), nil
+}
+
+// corsAuthz handles CORS requests of the server,
+// the authorization provider is optional
+type corsAuthz struct {
+	rest.Authz
+	cors *CORS
+}
+
+// SetRoleMapper configures the role mapper of the authorization provider
+func (a *corsAuthz) SetRoleMapper(m func(*http.Request) string) {
+	if a.Authz != nil {
+		a.Authz.SetRoleMapper(m)
+	}
+}
+
+// NewHandler returns a http.Handler that handles CORS requests,
+// and enforces the authorization
+func (a *corsAuthz) NewHandler(delegate http.Handler) (http.Handler, error) {
+	if a.Authz != nil {
+		var err error
+		delegate, err = a.Authz.NewHandler(delegate)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return a.cors.Handler(delegate), nil
+}
@@ -0,0 +1,65 @@
+package reload
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/juju/errors"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/pkg", "reload")
+
+// handler serves requests by the current handler,
+// which is rebuilt around the delegate when the configuration is changed
+type handler struct {
+	delegate http.Handler
+	current  atomic.Value
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.current.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// handlers is the list of handlers built by a middleware
+type handlers struct {
+	lock sync.Mutex
+	list []*handler
+}
+
+// add builds the handler around the delegate
+func (l *handlers) add(delegate http.Handler, build func(http.Handler) (http.Handler, error)) (http.Handler, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	current, err := build(delegate)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	h := &handler{delegate: delegate}
+	h.current.Store(current)
+	l.list = append(l.list, h)
+	return h, nil
+}
+
+// rebuild builds all handlers again,
+// the handlers are swapped only if all of them are built
+func (l *handlers) rebuild(build func(http.Handler) (http.Handler, error)) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	built := make([]http.Handler, len(l.list))
+	for i, h := range l.list {
+		current, err := build(h.delegate)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		built[i] = current
+	}
+	for i, h := range l.list {
+		h.current.Store(built[i])
+	}
+	return nil
+}
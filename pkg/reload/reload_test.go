@@ -0,0 +1,173 @@
+package reload
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly/rest"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusAuthz is the authorization provider, which responds with the status
+type statusAuthz struct {
+	rest.Authz
+	status     int
+	err        error
+	roleMapper func(*http.Request) string
+}
+
+func (a *statusAuthz) SetRoleMapper(m func(*http.Request) string) {
+	a.roleMapper = m
+}
+
+func (a *statusAuthz) NewHandler(delegate http.Handler) (http.Handler, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(a.status)
+	}), nil
+}
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func Test_Authz(t *testing.T) {
+	az := NewAuthz(nil)
+	az.SetRoleMapper(func(*http.Request) string { return "admin" })
+
+	h, err := az.NewHandler(ok)
+	require.NoError(t, err)
+
+	get := func() int {
+		return serve(h, httptest.NewRequest(http.MethodGet, "/v1/teams", nil)).Code
+	}
+	assert.Equal(t, http.StatusOK, get())
+
+	deny := &statusAuthz{status: http.StatusUnauthorized}
+	require.NoError(t, az.Set(deny))
+	assert.Equal(t, http.StatusUnauthorized, get())
+	assert.NotNil(t, deny.roleMapper)
+
+	// the current provider is kept
+	err = az.Set(&statusAuthz{err: errors.New("invalid allow list")})
+	require.Error(t, err)
+	assert.Equal(t, "failed to apply authorization: invalid allow list", err.Error())
+	assert.Equal(t, http.StatusUnauthorized, get())
+
+	require.NoError(t, az.Set(nil))
+	assert.Equal(t, http.StatusOK, get())
+}
+
+func Test_CORS(t *testing.T) {
+	c := NewCORS(nil)
+	az := c.NewAuthz(&statusAuthz{status: http.StatusUnauthorized})
+	h, err := az.NewHandler(ok)
+	require.NoError(t, err)
+
+	preflight := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "/v1/teams", nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		return serve(h, r)
+	}
+
+	w := preflight()
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	c.Set(&rest.CORSOptions{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedMethods: []string{http.MethodGet},
+	})
+	w = preflight()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+
+	c.Set(&rest.CORSOptions{
+		AllowedOrigins: []string{"https://other.com"},
+	})
+	w = preflight()
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func Test_TLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cert1, key1 := writeKeyPair(t, dir, "first")
+	cert2, key2 := writeKeyPair(t, dir, "second")
+
+	_, err = NewTLS(filepath.Join(dir, "missing.pem"), key1, "", tls.NoClientCert)
+	require.Error(t, err)
+
+	s, err := NewTLS(cert1, key1, "", tls.NoClientCert)
+	require.NoError(t, err)
+	defer s.Close()
+
+	subject := func() string {
+		cfg, err := s.Config().GetConfigForClient(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, tls.NoClientCert, cfg.ClientAuth)
+		kp, err := cfg.GetCertificate(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		c, err := x509.ParseCertificate(kp.Certificate[0])
+		require.NoError(t, err)
+		return c.Subject.CommonName
+	}
+	assert.Equal(t, "first", subject())
+
+	require.NoError(t, s.Set(cert2, key2, "", tls.NoClientCert))
+	assert.Equal(t, "second", subject())
+
+	// the current configuration is kept
+	require.Error(t, s.Set(cert1, filepath.Join(dir, "missing.pem"), "", tls.NoClientCert))
+	assert.Equal(t, "second", subject())
+}
+
+func writeKeyPair(t *testing.T, dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
@@ -0,0 +1,96 @@
+package reload
+
+import (
+	"crypto/tls"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-phorce/dolly/rest/tlsconfig"
+	"github.com/juju/errors"
+)
+
+// KeypairReloadInterval specifies how often the key pair files are checked for changes
+var KeypairReloadInterval = 5 * time.Second
+
+// TLS provides the server TLS configuration,
+// whose files can be replaced while the server is running
+type TLS struct {
+	server  *tls.Config
+	current atomic.Value
+
+	lock   sync.Mutex
+	loader *tlsconfig.KeypairReloader
+}
+
+// NewTLS returns TLS loaded from the files
+func NewTLS(certFile, keyFile, caFile string, clientAuth tls.ClientAuthType) (*TLS, error) {
+	cfg, loader, err := loadTLS(certFile, keyFile, caFile, clientAuth)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	t := &TLS{loader: loader}
+	t.current.Store(cfg)
+
+	t.server = cfg.Clone()
+	t.server.GetConfigForClient = t.getConfigForClient
+	return t, nil
+}
+
+// Config returns the configuration for the server,
+// the handshakes use the current configuration
+func (t *TLS) Config() *tls.Config {
+	return t.server
+}
+
+// Set loads the files, and replaces the configuration for new connections.
+// On error, the current configuration is kept.
+func (t *TLS) Set(certFile, keyFile, caFile string, clientAuth tls.ClientAuthType) error {
+	cfg, loader, err := loadTLS(certFile, keyFile, caFile, clientAuth)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.current.Store(cfg)
+	if t.loader != nil {
+		t.loader.Close()
+	}
+	t.loader = loader
+
+	logger.Noticef("api=TLS.Set, cert=%q, key=%q, ca=%q", certFile, keyFile, caFile)
+	return nil
+}
+
+// Close stops watching the key pair files
+func (t *TLS) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.loader != nil {
+		t.loader.Close()
+		t.loader = nil
+	}
+	return nil
+}
+
+func (t *TLS) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return t.current.Load().(*tls.Config), nil
+}
+
+func loadTLS(certFile, keyFile, caFile string, clientAuth tls.ClientAuthType) (*tls.Config, *tlsconfig.KeypairReloader, error) {
+	cfg, err := tlsconfig.NewServerTLSFromFiles(certFile, keyFile, caFile, clientAuth)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	loader, err := tlsconfig.NewKeypairReloader(certFile, keyFile, KeypairReloadInterval)
+	if err != nil {
+		return nil, nil, errors.Annotatef(err, "reason=NewKeypairReloader, cert=%q, key=%q", certFile, keyFile)
+	}
+	cfg.GetCertificate = loader.GetKeypairFunc()
+	return cfg, loader, nil
+}
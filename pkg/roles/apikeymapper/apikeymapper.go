@@ -7,7 +7,6 @@ import (
 
 	"github.com/go-phorce/dolly/xhttp/identity"
 	"github.com/go-phorce/dolly/xlog"
-	"github.com/go-phorce/dolly/xpki/certutil"
 	"github.com/juju/errors"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -43,6 +42,7 @@ type Provider struct {
 	httpHeader string
 	keysMap    map[string]identity.Identity
 	namesMap   map[string]identity.Identity
+	store      KeyStore
 }
 
 // LoadConfig returns configuration loaded from a file
@@ -115,10 +115,13 @@ func (p *Provider) IdentityMapper(r *http.Request) (identity.Identity, error) {
 	if key == "" {
 		return nil, nil
 	}
-	key = strings.ToUpper(certutil.SHA256Hex([]byte(key)))
+	key = HashKey(key)
 	if id, ok := p.keysMap[key]; ok {
 		logger.Infof("api=IdentityMapper, role=%s, name=%q", id.Role(), id.Name())
 		return id, nil
 	}
+	if p.store != nil {
+		return p.storeIdentity(r, key)
+	}
 	return nil, errors.New("invalid access key")
 }
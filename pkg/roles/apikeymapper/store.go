@@ -0,0 +1,96 @@
+package apikeymapper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xpki/certutil"
+	"github.com/juju/errors"
+)
+
+// TouchInterval limits how often the last used time of a stored key is updated
+const TouchInterval = time.Minute
+
+// KeyStore provides API keys issued at runtime
+type KeyStore interface {
+	// FindAPIKey returns the key by the hash of its secret
+	FindAPIKey(ctx context.Context, hash string) (*v1.APIKey, error)
+	// TouchAPIKey sets the last used time of the key
+	TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// SetKeyStore specifies the store of API keys issued at runtime,
+// which is checked for the keys not found in the configuration
+func (p *Provider) SetKeyStore(s KeyStore) {
+	p.store = s
+}
+
+// HashKey returns the hash of the key, as it is stored
+func HashKey(key string) string {
+	return strings.ToUpper(certutil.SHA256Hex([]byte(key)))
+}
+
+// GenerateKey returns a new key, its prefix to identify the key, and its hash
+func GenerateKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 36)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", "", errors.Trace(err)
+	}
+
+	prefix = hex.EncodeToString(b[:4])
+	key = prefix + "." + base64.RawURLEncoding.EncodeToString(b[4:])
+	return key, prefix, HashKey(key), nil
+}
+
+// storeIdentity returns identity of the stored key
+func (p *Provider) storeIdentity(r *http.Request, hash string) (identity.Identity, error) {
+	key, err := p.store.FindAPIKey(r.Context(), hash)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, errors.New("invalid access key")
+		}
+		return nil, errors.Annotate(err, "failed to find access key")
+	}
+
+	now := time.Now().UTC()
+	if key.RevokedAt != nil {
+		return nil, errors.Errorf("access key %s is revoked", key.Prefix)
+	}
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, errors.Errorf("access key %s is expired", key.Prefix)
+	}
+	if !inScopes(r.URL.Path, key.Scopes) {
+		return nil, errors.Errorf("access key %s is not allowed for %q", key.Prefix, r.URL.Path)
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= TouchInterval {
+		if err = p.store.TouchAPIKey(r.Context(), key.ID, now); err != nil {
+			logger.Errorf("api=IdentityMapper, reason=TouchAPIKey, key=%s, err=[%v]", key.Prefix, err)
+		}
+	}
+
+	logger.Infof("api=IdentityMapper, role=%s, name=%q, key=%s", key.Role, key.Owner, key.Prefix)
+	return identity.NewIdentityWithUserInfo(key.Role, key.Owner, "", key), nil
+}
+
+// inScopes returns true if the path is under one of the scopes,
+// or the scopes are empty
+func inScopes(path string, scopes []string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		scope = strings.TrimSuffix(scope, "/")
+		if path == scope || strings.HasPrefix(path, scope+"/") {
+			return true
+		}
+	}
+	return false
+}
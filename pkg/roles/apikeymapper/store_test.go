@@ -0,0 +1,137 @@
+package apikeymapper_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub/inmemory"
+	"github.com/go-phorce/dolly-test/pkg/roles/apikeymapper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GenerateKey(t *testing.T) {
+	key, prefix, hash, err := apikeymapper.GenerateKey()
+	require.NoError(t, err)
+	assert.Len(t, prefix, 8)
+	assert.True(t, strings.HasPrefix(key, prefix+"."))
+	assert.Equal(t, apikeymapper.HashKey(key), hash)
+	assert.Equal(t, strings.ToUpper(hash), hash)
+
+	key2, prefix2, _, err := apikeymapper.GenerateKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, key2)
+	assert.NotEqual(t, prefix, prefix2)
+}
+
+func Test_KeyStore(t *testing.T) {
+	ctx := context.Background()
+	db, err := inmemory.New()
+	require.NoError(t, err)
+
+	create := func(scopes []string, expiresAt *time.Time) (*v1.APIKey, string) {
+		secret, prefix, hash, err := apikeymapper.GenerateKey()
+		require.NoError(t, err)
+		key, err := db.CreateAPIKey(ctx, &v1.APIKey{
+			Prefix:    prefix,
+			Owner:     "ci-bot",
+			Role:      "dolly-client",
+			Scopes:    scopes,
+			CreatedAt: time.Now().UTC(),
+			ExpiresAt: expiresAt,
+		}, hash)
+		require.NoError(t, err)
+		return key, secret
+	}
+
+	p := apikeymapper.New(&apikeymapper.Config{})
+	call := func(path, secret string) (string, error) {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set(apikeymapper.APIKeyHeader, secret)
+		id, err := p.IdentityMapper(r)
+		if err != nil {
+			return "", err
+		}
+		return id.String(), nil
+	}
+
+	key, secret := create(nil, nil)
+
+	_, err = call("/v1/teams", secret)
+	require.Error(t, err)
+	assert.Equal(t, "invalid access key", err.Error())
+
+	p.SetKeyStore(db)
+
+	t.Run("valid", func(t *testing.T) {
+		id, err := call("/v1/teams", secret)
+		require.NoError(t, err)
+		assert.Equal(t, "dolly-client/ci-bot", id)
+
+		stored, err := db.GetAPIKey(ctx, key.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.LastUsedAt)
+		lastUsed := *stored.LastUsedAt
+
+		// the last used time is not updated more often than TouchInterval
+		_, err = call("/v1/teams", secret)
+		require.NoError(t, err)
+		stored, err = db.GetAPIKey(ctx, key.ID)
+		require.NoError(t, err)
+		assert.Equal(t, lastUsed, *stored.LastUsedAt)
+
+		_, err = call("/v1/teams", secret+"x")
+		require.Error(t, err)
+		assert.Equal(t, "invalid access key", err.Error())
+	})
+
+	t.Run("scopes", func(t *testing.T) {
+		_, secret := create([]string{"/v1/teams", "/v1/users/"}, nil)
+
+		for _, path := range []string{"/v1/teams", "/v1/teams/memberships", "/v1/users", "/v1/users/123"} {
+			_, err := call(path, secret)
+			assert.NoError(t, err, path)
+		}
+		for _, path := range []string{"/v1/teamsx", "/v1/auth/token", "/"} {
+			_, err := call(path, secret)
+			require.Error(t, err, path)
+			assert.Contains(t, err.Error(), "is not allowed for")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Minute).UTC()
+		_, secret := create(nil, &expiresAt)
+
+		_, err := call("/v1/teams", secret)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is expired")
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		key, secret := create(nil, nil)
+		_, err := db.RevokeAPIKey(ctx, key.ID, time.Now().UTC())
+		require.NoError(t, err)
+
+		_, err = call("/v1/teams", secret)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is revoked")
+	})
+
+	t.Run("static", func(t *testing.T) {
+		config, err := apikeymapper.LoadConfig("testdata/roles.yaml")
+		require.NoError(t, err)
+		p := apikeymapper.New(config)
+		p.SetKeyStore(db)
+
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(apikeymapper.APIKeyHeader, "A5D461AFA800FBCD820F36707FE9B3051832DB59FD491E6DA91F5E7D884C31C8")
+		id, err := p.IdentityMapper(r)
+		require.NoError(t, err)
+		assert.Equal(t, "admin/Denis Issoupov", id.String())
+	})
+}
@@ -1,8 +1,10 @@
 package certmapper
 
 import (
+	"crypto/x509"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
 	"strings"
 
 	"github.com/go-phorce/dolly/algorithms/slices"
@@ -34,6 +36,11 @@ type Config struct {
 	ValidOrganizations []string `json:"valid_organizations" yaml:"valid_organizations"`
 	// ValidIssuers is a list of accepted root Subject names
 	ValidIssuers []string `json:"valid_issuers" yaml:"valid_issuers"`
+	// Rules is a list of pattern rules, checked in order
+	// for the subjects not found in NamesMap
+	Rules []*Rule `json:"rules" yaml:"rules"`
+	// Revocation specifies revocation checking of client certificates
+	Revocation *RevocationConfig `json:"revocation" yaml:"revocation"`
 }
 
 // Provider of Cert identity
@@ -42,6 +49,9 @@ type Provider struct {
 	organizations []string
 	// list of accepted root Subject names
 	issuers []string
+	rules   []*rule
+	// revocation is nil if revocation checking is not configured
+	revocation *revocationChecker
 }
 
 // LoadConfig returns configuration loaded from a file
@@ -61,6 +71,15 @@ func LoadConfig(file string) (*Config, error) {
 		return nil, errors.Annotatef(err, "unable to unmarshal %q", file)
 	}
 
+	if config.Revocation != nil {
+		dir := filepath.Dir(file)
+		for i, source := range config.Revocation.CRL {
+			if !strings.Contains(source, "://") && !filepath.IsAbs(source) {
+				config.Revocation.CRL[i] = filepath.Join(dir, source)
+			}
+		}
+	}
+
 	return &config, nil
 }
 
@@ -70,11 +89,11 @@ func Load(cfgfile string) (*Provider, error) {
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	return New(cfg), nil
+	return New(cfg)
 }
 
 // New returns new Provider
-func New(cfg *Config) *Provider {
+func New(cfg *Config) (*Provider, error) {
 	p := &Provider{
 		namesMap:      map[string]identity.Identity{},
 		organizations: cfg.ValidOrganizations,
@@ -87,7 +106,27 @@ func New(cfg *Config) *Provider {
 			logger.Infof("api=subjectToIdentity, role=%s, subject=%q", role, subj)
 		}
 	}
-	return p
+
+	for _, r := range cfg.Rules {
+		c, err := compileRule(r)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		p.rules = append(p.rules, c)
+	}
+
+	if cfg.Revocation != nil {
+		p.revocation = newRevocationChecker(cfg.Revocation)
+	}
+	return p, nil
+}
+
+// Close stops loading CRLs in background
+func (p *Provider) Close() error {
+	if p.revocation != nil {
+		p.revocation.close()
+	}
+	return nil
 }
 
 // Applicable returns true if the request has autherization data applicable to the provider
@@ -134,17 +173,34 @@ func (p *Provider) IdentityMapper(r *http.Request) (identity.Identity, error) {
 		}
 	}
 
+	if p.revocation != nil {
+		if err := p.revocation.check(r.TLS); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
 	subj := certutil.NameToString(&r.TLS.PeerCertificates[0].Subject)
 	if fromMap, ok := p.namesMap[subj]; ok {
 		id = fromMap
-	} else {
-		return nil, errors.Errorf("the %q subject is not allowed", certutil.NameToString(&r.TLS.PeerCertificates[0].Subject))
+	} else if id = p.ruleIdentity(r.TLS.PeerCertificates[0]); id == nil {
+		return nil, errors.Errorf("the %q subject is not allowed", subj)
 	}
 
 	logger.Infof("api=IdentityMapper, subject=%q, role=%s, name=%q", subj, id.Role(), id.Name())
 	return id, nil
 }
 
+// ruleIdentity returns identity of the first rule matching the certificate,
+// or nil if not found
+func (p *Provider) ruleIdentity(crt *x509.Certificate) identity.Identity {
+	for _, rule := range p.rules {
+		if name, ok := rule.match(crt); ok {
+			return identity.NewIdentity(rule.Role, name, "")
+		}
+	}
+	return nil
+}
+
 func subjectToIdentity(role, subject string) identity.Identity {
 	var name string
 	for _, token := range strings.Split(subject, ",") {
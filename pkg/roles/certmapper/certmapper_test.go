@@ -7,7 +7,7 @@ import (
 	"net/http"
 	"testing"
 
-	"github.com/certcentral/enrollme/pkg/roles/certmapper"
+	"github.com/go-phorce/dolly-test/pkg/roles/certmapper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -38,7 +38,8 @@ func Test_identity(t *testing.T) {
 	cfg, err := certmapper.LoadConfig("testdata/roles.yaml")
 	require.NoError(t, err)
 
-	p := certmapper.New(cfg)
+	p, err := certmapper.New(cfg)
+	require.NoError(t, err)
 
 	t.Run("not_applicable", func(t *testing.T) {
 		r, _ := http.NewRequest(http.MethodGet, "/", nil)
@@ -0,0 +1,8 @@
+package certmapper
+
+// OCSPCacheSize returns the number of cached OCSP responses
+func OCSPCacheSize(p *Provider) int {
+	p.revocation.lock.RLock()
+	defer p.revocation.lock.RUnlock()
+	return len(p.revocation.ocsp)
+}
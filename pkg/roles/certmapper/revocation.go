@@ -0,0 +1,385 @@
+package certmapper
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// DefaultCRLRefresh specifies how often CRLs are loaded
+	DefaultCRLRefresh = time.Hour
+	// DefaultOCSPCacheTime specifies how long OCSP responses are cached,
+	// if the response does not specify the next update
+	DefaultOCSPCacheTime = time.Hour
+	// DefaultOCSPTimeout specifies the timeout of OCSP requests
+	DefaultOCSPTimeout = 5 * time.Second
+	// DefaultOCSPFailureBackoff specifies how long OCSP responder is not requested after failure
+	DefaultOCSPFailureBackoff = time.Minute
+)
+
+// RevocationConfig provides configuration of client certificates revocation checking
+type RevocationConfig struct {
+	// CRL specifies URLs or files of CRLs in DER or PEM format.
+	// Relative path is resolved against the folder of the configuration file.
+	CRL []string `json:"crl" yaml:"crl"`
+	// CRLRefresh specifies how often CRLs are loaded. Default is 1h.
+	CRLRefresh time.Duration `json:"crl_refresh" yaml:"crl_refresh"`
+	// OCSP enables OCSP checking with the responder specified in the certificate
+	OCSP bool `json:"ocsp" yaml:"ocsp"`
+	// OCSPResponder overrides the responder URL specified in the certificate
+	OCSPResponder string `json:"ocsp_responder" yaml:"ocsp_responder"`
+	// OCSPCacheTime specifies how long OCSP responses are cached,
+	// if the response does not specify the next update. Default is 1h.
+	OCSPCacheTime time.Duration `json:"ocsp_cache_time" yaml:"ocsp_cache_time"`
+	// OCSPTimeout specifies the timeout of OCSP requests. Default is 5s.
+	OCSPTimeout time.Duration `json:"ocsp_timeout" yaml:"ocsp_timeout"`
+	// OCSPFailureBackoff specifies how long the failed OCSP request is not retried,
+	// the failure is returned for the responder during this time. Default is 1m.
+	OCSPFailureBackoff time.Duration `json:"ocsp_failure_backoff" yaml:"ocsp_failure_backoff"`
+	// HardFail specifies to reject the certificate, if its status can not be determined,
+	// for example when the OCSP responder is not available or CRL is expired.
+	// By default the errors are logged and the certificate is accepted.
+	HardFail bool `json:"hard_fail" yaml:"hard_fail"`
+}
+
+// revocationChecker checks client certificates with CRLs and OCSP
+type revocationChecker struct {
+	cfg    RevocationConfig
+	client *http.Client
+	stop   chan struct{}
+
+	lock sync.RWMutex
+	// crls is a map of CRL source to the last loaded CRL
+	crls map[string]*x509.RevocationList
+	// ocsp is a map of OCSP responses by issuer and serial number
+	ocsp map[string]*ocspStatus
+	// ocspFailures is a map of the last failures by OCSP responder
+	ocspFailures map[string]*ocspFailure
+}
+
+// ocspFailure is cached failure of OCSP request
+type ocspFailure struct {
+	err     error
+	retryAt time.Time
+}
+
+// ocspStatus is cached OCSP response
+type ocspStatus struct {
+	status    int
+	expiresAt time.Time
+}
+
+func newRevocationChecker(cfg *RevocationConfig) *revocationChecker {
+	c := &revocationChecker{
+		cfg:          *cfg,
+		crls:         map[string]*x509.RevocationList{},
+		ocsp:         map[string]*ocspStatus{},
+		ocspFailures: map[string]*ocspFailure{},
+	}
+	if c.cfg.CRLRefresh == 0 {
+		c.cfg.CRLRefresh = DefaultCRLRefresh
+	}
+	if c.cfg.OCSPCacheTime == 0 {
+		c.cfg.OCSPCacheTime = DefaultOCSPCacheTime
+	}
+	if c.cfg.OCSPTimeout == 0 {
+		c.cfg.OCSPTimeout = DefaultOCSPTimeout
+	}
+	if c.cfg.OCSPFailureBackoff == 0 {
+		c.cfg.OCSPFailureBackoff = DefaultOCSPFailureBackoff
+	}
+	c.client = &http.Client{Timeout: c.cfg.OCSPTimeout}
+
+	if len(c.cfg.CRL) > 0 {
+		c.loadCRLs()
+
+		c.stop = make(chan struct{})
+		go c.refreshCRLs(c.stop)
+	}
+	return c
+}
+
+// close stops loading CRLs
+func (c *revocationChecker) close() {
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+func (c *revocationChecker) refreshCRLs(stop chan struct{}) {
+	ticker := time.NewTicker(c.cfg.CRLRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.loadCRLs()
+		}
+	}
+}
+
+// loadCRLs loads all CRLs, the last loaded CRL is kept if the source fails
+func (c *revocationChecker) loadCRLs() {
+	for _, source := range c.cfg.CRL {
+		crl, err := c.loadCRL(source)
+		if err != nil {
+			logger.Errorf("api=loadCRL, source=%q, err=[%v]", source, err)
+			continue
+		}
+
+		c.lock.Lock()
+		c.crls[source] = crl
+		c.lock.Unlock()
+		logger.Infof("api=loadCRL, source=%q, revoked=%d, next_update=%s",
+			source, len(crl.RevokedCertificateEntries), crl.NextUpdate.Format(time.RFC3339))
+	}
+}
+
+func (c *revocationChecker) loadCRL(source string) (*x509.RevocationList, error) {
+	var der []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		der, err = c.get(source)
+	} else {
+		der, err = ioutil.ReadFile(source)
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if block, _ := pem.Decode(der); block != nil {
+		der = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, errors.Annotate(err, "unable to parse CRL")
+	}
+	return crl, nil
+}
+
+func (c *revocationChecker) get(url string) ([]byte, error) {
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// check returns error if the peer certificate is revoked,
+// or its status can not be determined in hard-fail mode
+func (c *revocationChecker) check(state *tls.ConnectionState) error {
+	crt := state.PeerCertificates[0]
+	issuer := issuerOf(state)
+
+	checked, err := c.checkCRL(crt, issuer)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if c.cfg.OCSP {
+		err = c.checkOCSP(crt, issuer)
+		if err == nil {
+			checked = true
+		} else if errors.Cause(err) == errRevoked {
+			return errors.Trace(err)
+		} else if errors.Cause(err) != errOCSPBackoff {
+			logger.Errorf("api=checkOCSP, serial=%s, err=[%v]", crt.SerialNumber, err)
+		}
+	}
+
+	if !checked && c.cfg.HardFail {
+		return errors.Errorf("unable to check revocation status of %s certificate", crt.SerialNumber)
+	}
+	return nil
+}
+
+// errRevoked is returned for revoked certificates
+var errRevoked = errors.New("certificate is revoked")
+
+// errOCSPBackoff is returned while the failed OCSP responder is not requested
+var errOCSPBackoff = errors.New("OCSP responder failed recently")
+
+// checkCRL returns true if the certificate was checked by an unexpired CRL of its issuer
+func (c *revocationChecker) checkCRL(crt, issuer *x509.Certificate) (bool, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	checked := false
+	now := time.Now()
+	for source, crl := range c.crls {
+		if !bytes.Equal(crl.RawIssuer, crt.RawIssuer) {
+			continue
+		}
+		if issuer != nil {
+			if err := crl.CheckSignatureFrom(issuer); err != nil {
+				logger.Errorf("api=checkCRL, source=%q, err=[%v]", source, err)
+				continue
+			}
+		}
+		if revoked(crl, crt.SerialNumber) {
+			return true, errors.Annotatef(errRevoked, "%s certificate", crt.SerialNumber)
+		}
+		if crl.NextUpdate.IsZero() || now.Before(crl.NextUpdate) {
+			checked = true
+		} else {
+			logger.Warningf("api=checkCRL, source=%q, reason=expired, next_update=%s",
+				source, crl.NextUpdate.Format(time.RFC3339))
+		}
+	}
+	return checked, nil
+}
+
+func revoked(crl *x509.RevocationList, serial *big.Int) bool {
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(serial) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOCSP returns nil if OCSP responder returned good status
+func (c *revocationChecker) checkOCSP(crt, issuer *x509.Certificate) error {
+	if issuer == nil {
+		return errors.New("issuer is not available")
+	}
+
+	responder := c.cfg.OCSPResponder
+	if responder == "" {
+		if len(crt.OCSPServer) == 0 {
+			return errors.New("OCSP responder is not specified")
+		}
+		responder = crt.OCSPServer[0]
+	}
+
+	key := string(crt.RawIssuer) + "/" + crt.SerialNumber.String()
+	c.lock.RLock()
+	cached := c.ocsp[key]
+	c.lock.RUnlock()
+
+	if cached == nil || time.Now().After(cached.expiresAt) {
+		if err := c.ocspBackoff(responder); err != nil {
+			return errors.Trace(err)
+		}
+
+		status, err := c.requestOCSP(responder, crt, issuer)
+		if err != nil {
+			// the failure is cached, so TLS requests are not blocked
+			// by the timeout while the responder is not available
+			c.lock.Lock()
+			c.ocspFailures[responder] = &ocspFailure{
+				err:     err,
+				retryAt: time.Now().Add(c.cfg.OCSPFailureBackoff),
+			}
+			c.lock.Unlock()
+			return errors.Trace(err)
+		}
+		cached = status
+
+		c.lock.Lock()
+		c.evictOCSP()
+		c.ocsp[key] = cached
+		delete(c.ocspFailures, responder)
+		c.lock.Unlock()
+	}
+
+	switch cached.status {
+	case ocsp.Good:
+		return nil
+	case ocsp.Revoked:
+		return errors.Annotatef(errRevoked, "%s certificate", crt.SerialNumber)
+	default:
+		return errors.Errorf("OCSP status of %s certificate is unknown", crt.SerialNumber)
+	}
+}
+
+// evictOCSP removes expired OCSP responses from the cache,
+// so the cache does not grow with every certificate ever seen.
+// The caller must hold the lock.
+func (c *revocationChecker) evictOCSP() {
+	now := time.Now()
+	for key, status := range c.ocsp {
+		if now.After(status.expiresAt) {
+			delete(c.ocsp, key)
+		}
+	}
+}
+
+// ocspBackoff returns error, if the request to the responder failed within the backoff time
+func (c *revocationChecker) ocspBackoff(responder string) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	failure := c.ocspFailures[responder]
+	if failure == nil || time.Now().After(failure.retryAt) {
+		return nil
+	}
+	return errors.Annotatef(errOCSPBackoff, "%v", failure.err)
+}
+
+func (c *revocationChecker) requestOCSP(responder string, crt, issuer *x509.Certificate) (*ocspStatus, error) {
+	req, err := ocsp.CreateRequest(crt, issuer, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	resp, err := c.client.Post(responder, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%s returned %d", responder, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	res, err := ocsp.ParseResponseForCert(body, crt, issuer)
+	if err != nil {
+		return nil, errors.Annotate(err, "unable to parse OCSP response")
+	}
+
+	status := &ocspStatus{
+		status:    res.Status,
+		expiresAt: time.Now().Add(c.cfg.OCSPCacheTime),
+	}
+	if !res.NextUpdate.IsZero() && res.NextUpdate.Before(status.expiresAt) {
+		status.expiresAt = res.NextUpdate
+	}
+	return status, nil
+}
+
+// issuerOf returns the issuer of the peer certificate, or nil if not available
+func issuerOf(state *tls.ConnectionState) *x509.Certificate {
+	for _, chain := range state.VerifiedChains {
+		if len(chain) > 1 {
+			return chain[1]
+		}
+	}
+	if len(state.PeerCertificates) > 1 {
+		return state.PeerCertificates[1]
+	}
+	return nil
+}
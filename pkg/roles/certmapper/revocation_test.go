@@ -0,0 +1,408 @@
+package certmapper_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/pkg/roles/certmapper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testCA issues client certificates, CRLs and OCSP responses
+type testCA struct {
+	crt *x509.Certificate
+	key crypto.Signer
+}
+
+func newTestCA(t *testing.T, cn string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{crt: crt, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, serial int64, cn string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.crt, key.Public(), ca.key)
+	require.NoError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return crt
+}
+
+func (ca *testCA) crl(t *testing.T, nextUpdate time.Time, revoked ...int64) []byte {
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(time.Now().UnixNano()),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, serial := range revoked {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now().Add(-time.Minute),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, ca.crt, ca.key)
+	require.NoError(t, err)
+	return der
+}
+
+// ocspStub is OCSP responder, which returns the status by serial number
+type ocspStub struct {
+	*httptest.Server
+
+	lock   sync.Mutex
+	status map[int64]int
+	calls  int
+}
+
+func newOCSPStub(t *testing.T, ca *testCA) *ocspStub {
+	s := &ocspStub{status: map[int64]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s.lock.Lock()
+		s.calls++
+		status := s.status[req.SerialNumber.Int64()]
+		s.lock.Unlock()
+
+		res, err := ocsp.CreateResponse(ca.crt, ca.crt, ocsp.Response{
+			Status:           status,
+			SerialNumber:     req.SerialNumber,
+			ThisUpdate:       time.Now().Add(-time.Minute),
+			NextUpdate:       time.Now().Add(time.Hour),
+			RevokedAt:        time.Now().Add(-time.Minute),
+			RevocationReason: ocsp.KeyCompromise,
+		}, ca.key)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(res)
+	}))
+	return s
+}
+
+func (s *ocspStub) set(serial int64, status int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.status[serial] = status
+}
+
+func (s *ocspStub) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.calls
+}
+
+func identityFor(p *certmapper.Provider, crt, issuer *x509.Certificate) (string, error) {
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{crt},
+	}
+	if issuer != nil {
+		r.TLS.VerifiedChains = [][]*x509.Certificate{{crt, issuer}}
+	}
+	id, err := p.IdentityMapper(r)
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+var clientRules = []*certmapper.Rule{
+	{Role: "dolly-client", CN: []string{"*"}},
+}
+
+func Test_LoadConfigRevocation(t *testing.T) {
+	cfg, err := certmapper.LoadConfig("testdata/roles_revocation.yaml")
+	require.NoError(t, err)
+	require.NotNil(t, cfg.Revocation)
+	assert.Equal(t, []string{filepath.Join("testdata", "crl", "ca.crl"), "http://localhost/ca.crl"}, cfg.Revocation.CRL)
+	assert.Equal(t, 30*time.Minute, cfg.Revocation.CRLRefresh)
+	assert.True(t, cfg.Revocation.OCSP)
+	assert.True(t, cfg.Revocation.HardFail)
+}
+
+func Test_CRL(t *testing.T) {
+	ca := newTestCA(t, "[TEST] Dolly Root CA")
+	good := ca.issue(t, 100, "good.ekspand.com")
+	bad := ca.issue(t, 101, "bad.ekspand.com")
+
+	dir, err := ioutil.TempDir("", "crl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	crlFile := filepath.Join(dir, "ca.crl")
+	require.NoError(t, ioutil.WriteFile(crlFile, ca.crl(t, time.Now().Add(time.Hour), 101), 0644))
+
+	p, err := certmapper.New(&certmapper.Config{
+		Rules: clientRules,
+		Revocation: &certmapper.RevocationConfig{
+			CRL:      []string{crlFile},
+			HardFail: true,
+		},
+	})
+	require.NoError(t, err)
+	defer p.Close()
+
+	id, err := identityFor(p, good, ca.crt)
+	require.NoError(t, err)
+	assert.Equal(t, "dolly-client/good.ekspand.com", id)
+
+	_, err = identityFor(p, bad, ca.crt)
+	require.Error(t, err)
+	assert.Equal(t, "101 certificate: certificate is revoked", err.Error())
+
+	// the revoked certificate is rejected without the issuer as well
+	_, err = identityFor(p, bad, nil)
+	require.Error(t, err)
+
+	t.Run("other_issuer", func(t *testing.T) {
+		crt := newTestCA(t, "[TEST] Other Root CA").issue(t, 100, "other.ekspand.com")
+
+		_, err := identityFor(p, crt, nil)
+		require.Error(t, err)
+		assert.Equal(t, "unable to check revocation status of 100 certificate", err.Error())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(crlFile, ca.crl(t, time.Now().Add(-time.Minute)), 0644))
+
+		p, err := certmapper.New(&certmapper.Config{
+			Rules: clientRules,
+			Revocation: &certmapper.RevocationConfig{
+				CRL: []string{crlFile, filepath.Join(dir, "missing.crl")},
+			},
+		})
+		require.NoError(t, err)
+		defer p.Close()
+
+		// soft-fail mode
+		_, err = identityFor(p, good, ca.crt)
+		require.NoError(t, err)
+	})
+}
+
+func Test_CRLDownload(t *testing.T) {
+	ca := newTestCA(t, "[TEST] Dolly Root CA")
+	crt := ca.issue(t, 100, "client.ekspand.com")
+
+	var lock sync.Mutex
+	crl := ca.crl(t, time.Now().Add(time.Hour))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		w.Write(crl)
+	}))
+	defer srv.Close()
+
+	p, err := certmapper.New(&certmapper.Config{
+		Rules: clientRules,
+		Revocation: &certmapper.RevocationConfig{
+			CRL:        []string{srv.URL + "/ca.crl"},
+			CRLRefresh: 10 * time.Millisecond,
+			HardFail:   true,
+		},
+	})
+	require.NoError(t, err)
+	defer p.Close()
+
+	_, err = identityFor(p, crt, ca.crt)
+	require.NoError(t, err)
+
+	lock.Lock()
+	crl = ca.crl(t, time.Now().Add(time.Hour), 100)
+	lock.Unlock()
+
+	for i := 0; i < 100; i++ {
+		if _, err = identityFor(p, crt, ca.crt); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate is revoked")
+}
+
+func Test_OCSP(t *testing.T) {
+	ca := newTestCA(t, "[TEST] Dolly Root CA")
+	good := ca.issue(t, 100, "good.ekspand.com")
+	bad := ca.issue(t, 101, "bad.ekspand.com")
+	unknown := ca.issue(t, 102, "unknown.ekspand.com")
+
+	stub := newOCSPStub(t, ca)
+	defer stub.Close()
+	stub.set(101, ocsp.Revoked)
+	stub.set(102, ocsp.Unknown)
+
+	newProvider := func(responder string, hardFail bool) *certmapper.Provider {
+		p, err := certmapper.New(&certmapper.Config{
+			Rules: clientRules,
+			Revocation: &certmapper.RevocationConfig{
+				OCSP:          true,
+				OCSPResponder: responder,
+				HardFail:      hardFail,
+			},
+		})
+		require.NoError(t, err)
+		return p
+	}
+
+	t.Run("hard_fail", func(t *testing.T) {
+		p := newProvider(stub.URL, true)
+
+		id, err := identityFor(p, good, ca.crt)
+		require.NoError(t, err)
+		assert.Equal(t, "dolly-client/good.ekspand.com", id)
+
+		// the response is cached
+		calls := stub.count()
+		_, err = identityFor(p, good, ca.crt)
+		require.NoError(t, err)
+		assert.Equal(t, calls, stub.count())
+
+		_, err = identityFor(p, bad, ca.crt)
+		require.Error(t, err)
+		assert.Equal(t, "101 certificate: certificate is revoked", err.Error())
+
+		_, err = identityFor(p, unknown, ca.crt)
+		require.Error(t, err)
+		assert.Equal(t, "unable to check revocation status of 102 certificate", err.Error())
+
+		// the issuer is required for OCSP request
+		_, err = identityFor(p, good, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("soft_fail", func(t *testing.T) {
+		p := newProvider(stub.URL, false)
+
+		_, err := identityFor(p, unknown, ca.crt)
+		require.NoError(t, err)
+
+		_, err = identityFor(p, bad, ca.crt)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "certificate is revoked")
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		url := srv.URL
+		srv.Close()
+
+		_, err := identityFor(newProvider(url, false), good, ca.crt)
+		require.NoError(t, err)
+
+		_, err = identityFor(newProvider(url, true), good, ca.crt)
+		require.Error(t, err)
+		assert.Equal(t, "unable to check revocation status of 100 certificate", err.Error())
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		p, err := certmapper.New(&certmapper.Config{
+			Rules: clientRules,
+			Revocation: &certmapper.RevocationConfig{
+				OCSP:          true,
+				OCSPResponder: stub.URL,
+				OCSPCacheTime: 50 * time.Millisecond,
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = identityFor(p, good, ca.crt)
+		require.NoError(t, err)
+		_, err = identityFor(p, bad, ca.crt)
+		require.Error(t, err)
+		assert.Equal(t, 2, certmapper.OCSPCacheSize(p))
+
+		// the expired responses are dropped when a response is cached
+		time.Sleep(100 * time.Millisecond)
+		_, err = identityFor(p, unknown, ca.crt)
+		require.NoError(t, err)
+		assert.Equal(t, 1, certmapper.OCSPCacheSize(p))
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		var lock sync.Mutex
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			calls++
+			lock.Unlock()
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+		count := func() int {
+			lock.Lock()
+			defer lock.Unlock()
+			return calls
+		}
+
+		p, err := certmapper.New(&certmapper.Config{
+			Rules: clientRules,
+			Revocation: &certmapper.RevocationConfig{
+				OCSP:               true,
+				OCSPResponder:      srv.URL,
+				OCSPFailureBackoff: 100 * time.Millisecond,
+			},
+		})
+		require.NoError(t, err)
+
+		_, err = identityFor(p, good, ca.crt)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count())
+
+		// the failed responder is not requested within the backoff
+		_, err = identityFor(p, unknown, ca.crt)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count())
+
+		time.Sleep(150 * time.Millisecond)
+		_, err = identityFor(p, good, ca.crt)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count())
+	})
+}
@@ -0,0 +1,197 @@
+package certmapper
+
+import (
+	"crypto/x509"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-phorce/dolly/xpki/certutil"
+	"github.com/juju/errors"
+)
+
+// Name fields of the certificate, the identity name is derived from
+const (
+	// NameFromCN specifies Common Name of the subject, this is default
+	NameFromCN = "cn"
+	// NameFromSubject specifies full subject DN
+	NameFromSubject = "subject"
+	// NameFromDNS specifies DNS name of SAN
+	NameFromDNS = "dns"
+	// NameFromEmail specifies email of SAN
+	NameFromEmail = "email"
+	// NameFromURI specifies URI of SAN, such as SPIFFE ID
+	NameFromURI = "uri"
+)
+
+// Rule provides mapping of certificates to a role by patterns.
+// The certificate matches the rule if it matches all specified conditions,
+// where a list condition matches if any of its values matches.
+// Patterns use path.Match syntax, such as "*.ekspand.com".
+type Rule struct {
+	// Role specifies the role of matched certificate
+	Role string `json:"role" yaml:"role"`
+	// Name specifies the field, the identity name is derived from:
+	// cn, subject, dns, email or uri. Default is cn.
+	// For SAN fields, the first value matched by the rule is used.
+	Name string `json:"name" yaml:"name"`
+	// CN specifies patterns of Common Name
+	CN []string `json:"cn" yaml:"cn"`
+	// CNRegex specifies regular expression of Common Name,
+	// the expression matches the whole Common Name
+	CNRegex string `json:"cn_regex" yaml:"cn_regex"`
+	// OU specifies patterns of Organizational Unit
+	OU []string `json:"ou" yaml:"ou"`
+	// O specifies patterns of Organization
+	O []string `json:"o" yaml:"o"`
+	// DNS specifies patterns of SAN DNS names
+	DNS []string `json:"dns" yaml:"dns"`
+	// Email specifies patterns of SAN emails
+	Email []string `json:"email" yaml:"email"`
+	// URI specifies patterns of SAN URIs, such as "spiffe://ekspand.com/dolly/*"
+	URI []string `json:"uri" yaml:"uri"`
+	// Policies specifies certificate policy OIDs, such as "1.3.6.1.4.1.311.21.8"
+	Policies []string `json:"policies" yaml:"policies"`
+}
+
+// rule is compiled Rule
+type rule struct {
+	*Rule
+	cnRegex *regexp.Regexp
+}
+
+func compileRule(r *Rule) (*rule, error) {
+	if r.Role == "" {
+		return nil, errors.NotValidf("rule without role")
+	}
+	switch r.Name {
+	case "":
+		r.Name = NameFromCN
+	case NameFromCN, NameFromSubject, NameFromDNS, NameFromEmail, NameFromURI:
+	default:
+		return nil, errors.NotValidf("name field %q in rule for %q role", r.Name, r.Role)
+	}
+
+	if len(r.CN) == 0 && r.CNRegex == "" && len(r.OU) == 0 && len(r.O) == 0 &&
+		len(r.DNS) == 0 && len(r.Email) == 0 && len(r.URI) == 0 && len(r.Policies) == 0 {
+		return nil, errors.NotValidf("rule for %q role without conditions", r.Role)
+	}
+
+	for _, patterns := range [][]string{r.CN, r.OU, r.O, r.DNS, r.Email, r.URI} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, errors.NotValidf("pattern %q in rule for %q role", pattern, r.Role)
+			}
+		}
+	}
+	for _, oid := range r.Policies {
+		if !isOID(oid) {
+			return nil, errors.NotValidf("policy OID %q in rule for %q role", oid, r.Role)
+		}
+	}
+
+	c := &rule{Rule: r}
+	if r.CNRegex != "" {
+		var err error
+		// anchored, so a pattern does not match a longer Common Name
+		if c.cnRegex, err = regexp.Compile("^(?:" + r.CNRegex + ")$"); err != nil {
+			return nil, errors.Annotatef(err, "invalid cn_regex in rule for %q role", r.Role)
+		}
+	}
+	return c, nil
+}
+
+// match returns the identity name, if the certificate matches the rule
+func (r *rule) match(crt *x509.Certificate) (string, bool) {
+	subj := &crt.Subject
+	if len(r.CN) > 0 && matchAny(r.CN, []string{subj.CommonName}) == "" {
+		return "", false
+	}
+	if r.cnRegex != nil && !r.cnRegex.MatchString(subj.CommonName) {
+		return "", false
+	}
+	if len(r.OU) > 0 && matchAny(r.OU, subj.OrganizationalUnit) == "" {
+		return "", false
+	}
+	if len(r.O) > 0 && matchAny(r.O, subj.Organization) == "" {
+		return "", false
+	}
+	if len(r.Policies) > 0 && !hasPolicy(crt, r.Policies) {
+		return "", false
+	}
+
+	names := map[string]string{
+		NameFromCN:      subj.CommonName,
+		NameFromSubject: certutil.NameToString(subj),
+	}
+	sans := []struct {
+		field    string
+		patterns []string
+		values   []string
+	}{
+		{NameFromDNS, r.DNS, crt.DNSNames},
+		{NameFromEmail, r.Email, crt.EmailAddresses},
+		{NameFromURI, r.URI, uriStrings(crt.URIs)},
+	}
+	for _, san := range sans {
+		if len(san.patterns) > 0 {
+			matched := matchAny(san.patterns, san.values)
+			if matched == "" {
+				return "", false
+			}
+			names[san.field] = matched
+		} else if len(san.values) > 0 {
+			names[san.field] = san.values[0]
+		}
+	}
+
+	name := names[r.Name]
+	return name, name != ""
+}
+
+// matchAny returns the first value matched by any of the patterns
+func matchAny(patterns, values []string) string {
+	for _, val := range values {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, val); ok && val != "" {
+				return val
+			}
+		}
+	}
+	return ""
+}
+
+func hasPolicy(crt *x509.Certificate, oids []string) bool {
+	for _, id := range crt.PolicyIdentifiers {
+		s := id.String()
+		for _, oid := range oids {
+			if s == oid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func uriStrings(uris []*url.URL) []string {
+	list := make([]string, 0, len(uris))
+	for _, u := range uris {
+		list = append(list, u.String())
+	}
+	return list
+}
+
+// isOID returns true if s is dotted OID, such as 1.2.3
+func isOID(s string) bool {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, p := range parts {
+		if p == "" || strings.TrimLeft(p, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
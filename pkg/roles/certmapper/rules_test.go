@@ -0,0 +1,134 @@
+package certmapper_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/go-phorce/dolly-test/pkg/roles/certmapper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_InvalidRules(t *testing.T) {
+	_, err := certmapper.Load("testdata/roles_invalid_regex.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid cn_regex in rule for "enrollme-client" role`)
+
+	tcases := []struct {
+		rule   certmapper.Rule
+		experr string
+	}{
+		{certmapper.Rule{CN: []string{"*"}}, "rule without role not valid"},
+		{certmapper.Rule{Role: "r"}, `rule for "r" role without conditions not valid`},
+		{certmapper.Rule{Role: "r", Name: "serial", CN: []string{"*"}}, `name field "serial" in rule for "r" role not valid`},
+		{certmapper.Rule{Role: "r", DNS: []string{"[a-"}}, `pattern "[a-" in rule for "r" role not valid`},
+		{certmapper.Rule{Role: "r", Policies: []string{"1.x.3"}}, `policy OID "1.x.3" in rule for "r" role not valid`},
+	}
+	for _, tc := range tcases {
+		rule := tc.rule
+		_, err := certmapper.New(&certmapper.Config{Rules: []*certmapper.Rule{&rule}})
+		require.Error(t, err)
+		assert.Equal(t, tc.experr, err.Error())
+	}
+}
+
+func Test_Rules(t *testing.T) {
+	p, err := certmapper.Load("testdata/roles_rules.yaml")
+	require.NoError(t, err)
+
+	spiffe, _ := url.Parse("spiffe://ekspand.com/dolly/worker")
+	other, _ := url.Parse("spiffe://other.com/dolly/worker")
+
+	tcases := []struct {
+		name   string
+		crt    *x509.Certificate
+		exp    string
+		experr string
+	}{
+		{
+			name: "exact_subject",
+			crt:  &x509.Certificate{Subject: pkix.Name{CommonName: "admin.ekspand.com", Organization: []string{"Digicert"}}},
+			exp:  "enrollme-admin/admin.ekspand.com",
+		},
+		{
+			name: "cn_wildcard",
+			crt:  &x509.Certificate{Subject: pkix.Name{CommonName: "node1.peers.ekspand.com", OrganizationalUnit: []string{"prod"}}},
+			exp:  "enrollme-peer/node1.peers.ekspand.com",
+		},
+		{
+			name:   "cn_wildcard_ou",
+			crt:    &x509.Certificate{Subject: pkix.Name{CommonName: "node1.peers.ekspand.com", OrganizationalUnit: []string{"test"}}},
+			experr: `the "OU=test, CN=node1.peers.ekspand.com" subject is not allowed`,
+		},
+		{
+			name: "cn_regex",
+			crt:  &x509.Certificate{Subject: pkix.Name{CommonName: "enrollme12.ekspand.com", Organization: []string{"Digicert"}}},
+			exp:  "enrollme-client/enrollme12.ekspand.com",
+		},
+		{
+			name:   "cn_regex_o",
+			crt:    &x509.Certificate{Subject: pkix.Name{CommonName: "enrollme12.ekspand.com", Organization: []string{"Other"}}},
+			experr: `the "O=Other, CN=enrollme12.ekspand.com" subject is not allowed`,
+		},
+		{
+			name: "cn_regex_anchored",
+			crt:  &x509.Certificate{Subject: pkix.Name{CommonName: "operator.ekspand.com"}},
+			exp:  "enrollme-operator/operator.ekspand.com",
+		},
+		{
+			name:   "cn_regex_suffix",
+			crt:    &x509.Certificate{Subject: pkix.Name{CommonName: "operator.ekspand.com.evil.net"}},
+			experr: `the "CN=operator.ekspand.com.evil.net" subject is not allowed`,
+		},
+		{
+			name:   "cn_regex_prefix",
+			crt:    &x509.Certificate{Subject: pkix.Name{CommonName: "evil-operator.ekspand.com"}},
+			experr: `the "CN=evil-operator.ekspand.com" subject is not allowed`,
+		},
+		{
+			name: "spiffe",
+			crt:  &x509.Certificate{URIs: []*url.URL{other, spiffe}},
+			exp:  "enrollme-workload/spiffe://ekspand.com/dolly/worker",
+		},
+		{
+			name: "dns_policy",
+			crt: &x509.Certificate{
+				DNSNames:          []string{"d1.devices.ekspand.com"},
+				PolicyIdentifiers: []asn1.ObjectIdentifier{{2, 5, 29, 32, 0}, {1, 3, 6, 1, 4, 1, 99999, 1, 1}},
+			},
+			exp: "enrollme-device/d1.devices.ekspand.com",
+		},
+		{
+			name:   "dns_no_policy",
+			crt:    &x509.Certificate{Subject: pkix.Name{CommonName: "d1"}, DNSNames: []string{"d1.devices.ekspand.com"}},
+			experr: `the "CN=d1" subject is not allowed`,
+		},
+		{
+			name: "email",
+			crt:  &x509.Certificate{Subject: pkix.Name{CommonName: "Denis"}, EmailAddresses: []string{"denis@other.com", "denis@ekspand.com"}},
+			exp:  "enrollme-user/denis@ekspand.com",
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest(http.MethodGet, "/", nil)
+			r.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{tc.crt},
+			}
+			id, err := p.IdentityMapper(r)
+			if tc.experr != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.experr, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.exp, id.String())
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package roles
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-phorce/dolly-test/pkg/roles/apikeymapper"
+	"github.com/go-phorce/dolly-test/pkg/roles/certmapper"
+	"github.com/go-phorce/dolly-test/pkg/roles/jwtmapper"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/juju/errors"
+)
+
+// DefaultProviders specifies the default order of identity providers
+var DefaultProviders = []string{jwtmapper.ProviderName, apikeymapper.ProviderName, certmapper.ProviderName}
+
+// Identity is the identity with the name of the provider, which returned it
+type Identity struct {
+	identity.Identity
+	provider string
+}
+
+// Provider returns the name of the identity provider,
+// the names are joined with "+" when several providers are required
+func (i *Identity) Provider() string {
+	return i.provider
+}
+
+// ProviderOf returns the name of the identity provider,
+// or empty string if the identity was not returned by the roles provider
+func ProviderOf(id identity.Identity) string {
+	if i, ok := id.(*Identity); ok {
+		return i.provider
+	}
+	return ""
+}
+
+// requiredProviders specifies the providers required for the path
+type requiredProviders struct {
+	path      string
+	providers []string
+}
+
+// mapperProvider is the identity provider of a mapper swapped on reload
+type mapperProvider struct {
+	mapper func() IdentityProvider
+}
+
+func (m *mapperProvider) Applicable(r *http.Request) bool {
+	p := m.mapper()
+	return p != nil && p.Applicable(r)
+}
+
+func (m *mapperProvider) IdentityMapper(r *http.Request) (identity.Identity, error) {
+	p := m.mapper()
+	if p == nil {
+		return nil, errors.New("identity provider is not configured")
+	}
+	return p.IdentityMapper(r)
+}
+
+// registerMappers registers the providers of the configured mappers
+func (p *Provider) registerMappers() {
+	p.providers = map[string]IdentityProvider{
+		jwtmapper.ProviderName: &mapperProvider{mapper: func() IdentityProvider {
+			if m := p.JwtMapper(); m != nil {
+				return m
+			}
+			return nil
+		}},
+		apikeymapper.ProviderName: &mapperProvider{mapper: func() IdentityProvider {
+			if m := p.APIKeyMapper(); m != nil {
+				return m
+			}
+			return nil
+		}},
+		certmapper.ProviderName: &mapperProvider{mapper: func() IdentityProvider {
+			if m := p.CertMapper(); m != nil {
+				return m
+			}
+			return nil
+		}},
+	}
+	p.order = append([]string{}, DefaultProviders...)
+}
+
+// Register adds the identity provider with the name,
+// the provider is checked after already registered providers,
+// unless the order is specified by SetProviders
+func (p *Provider) Register(name string, prov IdentityProvider) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if name == "" || strings.ContainsAny(name, ",:+") {
+		return errors.NotValidf("identity provider name %q", name)
+	}
+	if _, ok := p.providers[name]; ok {
+		return errors.AlreadyExistsf("identity provider %q", name)
+	}
+	p.providers[name] = prov
+	p.order = append(p.order, name)
+	return nil
+}
+
+// SetProviders specifies the order of identity providers,
+// the providers not listed are not used unless required for a path
+func (p *Provider) SetProviders(order []string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, name := range order {
+		if _, ok := p.providers[name]; !ok {
+			return errors.NotFoundf("identity provider %q", name)
+		}
+	}
+	p.order = append([]string{}, order...)
+	return nil
+}
+
+// SetRequiredProviders specifies identity providers required for a path
+// and its children, in format: ${path}:${provider},${provider}.
+// All listed providers must be applicable to the request and return identity
+// of the same principal, with the same name case insensitive, for example
+// the certificate rule with email name and JWT of the user.
+// The identity of the first listed provider is used.
+func (p *Provider) SetRequiredProviders(rules []string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var required []*requiredProviders
+	for _, rule := range rules {
+		idx := strings.LastIndex(rule, ":")
+		if idx <= 0 || idx == len(rule)-1 {
+			return errors.NotValidf("required providers %q", rule)
+		}
+
+		req := &requiredProviders{path: rule[:idx]}
+		for _, name := range strings.Split(rule[idx+1:], ",") {
+			name = strings.TrimSpace(name)
+			if _, ok := p.providers[name]; !ok {
+				return errors.NotFoundf("identity provider %q for %q", name, req.path)
+			}
+			req.providers = append(req.providers, name)
+		}
+		required = append(required, req)
+	}
+
+	// the most specific path is checked first
+	sort.SliceStable(required, func(i, j int) bool {
+		return len(required[i].path) > len(required[j].path)
+	})
+	p.required = required
+	return nil
+}
+
+// requiredFor returns the providers required for the path, or nil
+func requiredFor(required []*requiredProviders, path string) *requiredProviders {
+	for _, req := range required {
+		prefix := strings.TrimSuffix(req.path, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") || prefix == "" {
+			return req
+		}
+	}
+	return nil
+}
+
+// IdentityMapper returns identity from the request
+func (p *Provider) IdentityMapper(r *http.Request) (identity.Identity, error) {
+	p.lock.RLock()
+	providers, order, required := p.providers, p.order, p.required
+	p.lock.RUnlock()
+
+	if req := requiredFor(required, r.URL.Path); req != nil {
+		var first identity.Identity
+		for _, name := range req.providers {
+			prov := providers[name]
+			if !prov.Applicable(r) {
+				return nil, errors.Errorf("%s identity is required", name)
+			}
+			id, err := prov.IdentityMapper(r)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if id == nil {
+				return nil, errors.Errorf("%s identity is required", name)
+			}
+			if first == nil {
+				if id.Name() == "" {
+					return nil, errors.Errorf("%s identity has no name", name)
+				}
+				first = id
+			} else if !strings.EqualFold(id.Name(), first.Name()) {
+				return nil, errors.Errorf("%s identity %q does not match %s identity %q",
+					name, id.Name(), req.providers[0], first.Name())
+			}
+		}
+		return &Identity{Identity: first, provider: strings.Join(req.providers, "+")}, nil
+	}
+
+	for _, name := range order {
+		prov := providers[name]
+		if prov.Applicable(r) {
+			id, err := prov.IdentityMapper(r)
+			if err != nil || id == nil {
+				return id, err
+			}
+			return &Identity{Identity: id, provider: name}, nil
+		}
+	}
+
+	// if none of providers are applicable or configured,
+	// then use default guest mapper
+	return identity.GuestIdentityMapper(r)
+}
@@ -0,0 +1,178 @@
+package roles_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+
+	"github.com/go-phorce/dolly-test/pkg/roles"
+	"github.com/go-phorce/dolly-test/pkg/roles/apikeymapper"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// headerProvider returns identity from X-Test-User header
+type headerProvider struct{}
+
+func (headerProvider) Applicable(r *http.Request) bool {
+	return r.Header.Get("X-Test-User") != ""
+}
+
+func (headerProvider) IdentityMapper(r *http.Request) (identity.Identity, error) {
+	return identity.NewIdentity("tester", r.Header.Get("X-Test-User"), ""), nil
+}
+
+func Test_Chain(t *testing.T) {
+	p, err := roles.New(
+		"",
+		"apikeymapper/testdata/roles.yaml",
+		"certmapper/testdata/roles.yaml",
+		nil)
+	require.NoError(t, err)
+
+	request := func(path string, apiKey, cert, user bool) *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, path, nil)
+		if apiKey {
+			r.Header.Set(apikeymapper.APIKeyHeader, "A5D461AFA800FBCD820F36707FE9B3051832DB59FD491E6DA91F5E7D884C31C8")
+		}
+		if cert {
+			r.TLS = &tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{
+					{{Subject: pkix.Name{CommonName: "[TEST] Digicert Enrollme Root CA"}}},
+				},
+				PeerCertificates: []*x509.Certificate{
+					{
+						Subject: pkix.Name{
+							CommonName:   "peers.enrollme2.ekspand.com",
+							Organization: []string{"Digicert"},
+							Country:      []string{"US"},
+							Province:     []string{"wa"},
+							Locality:     []string{"Kirkland"},
+						},
+					},
+				},
+			}
+		}
+		if user {
+			r.Header.Set("X-Test-User", "denis")
+		}
+		return r
+	}
+	identityFor := func(r *http.Request) (string, string, error) {
+		id, err := p.IdentityMapper(r)
+		if err != nil {
+			return "", "", err
+		}
+		return id.String(), roles.ProviderOf(id), nil
+	}
+
+	id, prov, err := identityFor(request("/v1/teams", true, true, false))
+	require.NoError(t, err)
+	assert.Equal(t, "admin/Denis Issoupov", id)
+	assert.Equal(t, "apikey", prov)
+
+	id, prov, err = identityFor(request("/v1/teams", false, false, false))
+	require.NoError(t, err)
+	assert.Equal(t, identity.GuestRoleName, id[:len(identity.GuestRoleName)])
+	assert.Empty(t, prov)
+
+	t.Run("register", func(t *testing.T) {
+		require.NoError(t, p.Register("header", headerProvider{}))
+
+		err := p.Register("header", headerProvider{})
+		require.Error(t, err)
+		assert.Equal(t, `identity provider "header" already exists`, err.Error())
+
+		err = p.Register("a+b", headerProvider{})
+		require.Error(t, err)
+		assert.Equal(t, `identity provider name "a+b" not valid`, err.Error())
+
+		id, prov, err := identityFor(request("/v1/teams", false, false, true))
+		require.NoError(t, err)
+		assert.Equal(t, "tester/denis", id)
+		assert.Equal(t, "header", prov)
+	})
+
+	t.Run("order", func(t *testing.T) {
+		err := p.SetProviders([]string{"cert", "missing"})
+		require.Error(t, err)
+		assert.Equal(t, `identity provider "missing" not found`, err.Error())
+
+		require.NoError(t, p.SetProviders([]string{"header", "cert", "apikey"}))
+
+		id, prov, err := identityFor(request("/v1/teams", true, true, false))
+		require.NoError(t, err)
+		assert.Equal(t, "enrollme-peer/peers.enrollme2.ekspand.com", id)
+		assert.Equal(t, "cert", prov)
+
+		id, prov, err = identityFor(request("/v1/teams", true, true, true))
+		require.NoError(t, err)
+		assert.Equal(t, "tester/denis", id)
+		assert.Equal(t, "header", prov)
+	})
+
+	t.Run("required", func(t *testing.T) {
+		err := p.SetRequiredProviders([]string{"/v1/admin"})
+		require.Error(t, err)
+		assert.Equal(t, `required providers "/v1/admin" not valid`, err.Error())
+
+		err = p.SetRequiredProviders([]string{"/v1/admin:cert,jwt2"})
+		require.Error(t, err)
+		assert.Equal(t, `identity provider "jwt2" for "/v1/admin" not found`, err.Error())
+
+		require.NoError(t, p.SetRequiredProviders([]string{
+			"/v1/admin:apikey,cert",
+			"/v1/admin/users:header",
+		}))
+
+		_, _, err = identityFor(request("/v1/admin/teams", true, false, true))
+		require.Error(t, err)
+		assert.Equal(t, "cert identity is required", err.Error())
+
+		_, _, err = identityFor(request("/v1/admin", false, true, false))
+		require.Error(t, err)
+		assert.Equal(t, "apikey identity is required", err.Error())
+
+		// the identities of different principals
+		_, _, err = identityFor(request("/v1/admin/teams", true, true, true))
+		require.Error(t, err)
+		assert.Equal(t, `cert identity "peers.enrollme2.ekspand.com" does not match apikey identity "Denis Issoupov"`, err.Error())
+
+		require.NoError(t, p.SetRequiredProviders([]string{
+			"/v1/admin:apikey,header",
+			"/v1/admin/users:header",
+		}))
+
+		_, _, err = identityFor(request("/v1/admin/teams", true, false, true))
+		require.Error(t, err)
+		assert.Equal(t, `header identity "denis" does not match apikey identity "Denis Issoupov"`, err.Error())
+
+		r := request("/v1/admin/teams", true, false, false)
+		r.Header.Set("X-Test-User", "denis issoupov")
+		id, prov, err := identityFor(r)
+		require.NoError(t, err)
+		assert.Equal(t, "admin/Denis Issoupov", id)
+		assert.Equal(t, "apikey+header", prov)
+
+		// the most specific path is used
+		id, prov, err = identityFor(request("/v1/admin/users/1", false, false, true))
+		require.NoError(t, err)
+		assert.Equal(t, "tester/denis", id)
+		assert.Equal(t, "header", prov)
+
+		// other paths use the order
+		id, prov, err = identityFor(request("/v1/administrators", true, false, false))
+		require.NoError(t, err)
+		assert.Equal(t, "apikey", prov)
+		assert.Equal(t, "admin/Denis Issoupov", id)
+
+		// not configured provider is never applicable
+		require.NoError(t, p.SetRequiredProviders([]string{"/v1/admin:jwt"}))
+		_, _, err = identityFor(request("/v1/admin", true, true, true))
+		require.Error(t, err)
+		assert.Equal(t, "jwt identity is required", err.Error())
+	})
+}
@@ -0,0 +1,10 @@
+package jwtmapper
+
+import "time"
+
+// SetMinKeysRefresh allows tests to fetch OIDC keys on every unknown kid
+func SetMinKeysRefresh(d time.Duration) time.Duration {
+	old := minKeysRefresh
+	minKeysRefresh = d
+	return old
+}
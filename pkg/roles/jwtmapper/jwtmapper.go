@@ -1,18 +1,21 @@
 package jwtmapper
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/algorithms/guid"
 	"github.com/go-phorce/dolly/xhttp/header"
 	"github.com/go-phorce/dolly/xhttp/identity"
 	"github.com/go-phorce/dolly/xlog"
-	"github.com/go-phorce/dolly/xpki/certutil"
+	"github.com/go-phorce/dolly/xpki/cryptoprov"
 	"github.com/juju/errors"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -25,8 +28,13 @@ const ProviderName = "jwt"
 // Key for JWT signature
 type Key struct {
 	// ID of the key
-	ID   string `json:"id" yaml:"id"`
+	ID string `json:"id" yaml:"id"`
+	// Seed specifies the secret for HS256 signature
 	Seed string `json:"seed" yaml:"seed"`
+	// KeyFile specifies the file with PEM-encoded RSA or ECDSA private key,
+	// or with PKCS#11 URI of the key in HSM, for RS256 or ES256 signature.
+	// Relative path is resolved against the folder of the configuration file.
+	KeyFile string `json:"key_file" yaml:"key_file"`
 }
 
 // Config provides OAuth2 configuration
@@ -41,8 +49,11 @@ type Config struct {
 	Keys []*Key `json:"keys" yaml:"keys"`
 	// DefaultRole specifies default role name
 	DefaultRole string `json:"default_role" yaml:"default_role"`
-	// RolesMap is a map of roles to list of users
+	// RolesMap is a map of roles to list of users,
+	// or to values of the role claim of OIDC tokens
 	RolesMap map[string][]string `json:"roles" yaml:"roles"`
+	// OIDC specifies external OpenID Connect issuer, whose tokens are trusted
+	OIDC *OIDCConfig `json:"oidc" yaml:"oidc"`
 }
 
 // Provider of OAuth2 identity
@@ -50,9 +61,19 @@ type Provider struct {
 	issuer   string
 	audience string
 	kid      string
-	keys     map[string][]byte
+	keys     map[string]*signingKey
 	role     string
 	roles    map[string]string
+	oidc     *oidcVerifier
+
+	revocations RevocationChecker
+}
+
+// RevocationChecker provides revocation list of tokens
+type RevocationChecker interface {
+	// IsTokenRevoked returns true if the token issued at the time
+	// is revoked by its ID, device or user
+	IsTokenRevoked(ctx context.Context, tokenID, deviceID, user string, issuedAt time.Time) (bool, error)
 }
 
 // LoadConfig returns configuration loaded from a file
@@ -72,6 +93,19 @@ func LoadConfig(file string) (*Config, error) {
 		return nil, errors.Annotatef(err, "unable to unmarshal %q", file)
 	}
 
+	if config.OIDC != nil {
+		if config.OIDC.Issuer == "" {
+			return nil, errors.Errorf("missing oidc issuer: %q", file)
+		}
+		if config.OIDC.Audience == "" {
+			return nil, errors.Errorf("missing oidc audience: %q", file)
+		}
+		// local keys are optional, if only OIDC tokens are accepted
+		if config.KeyID == "" && len(config.Keys) == 0 {
+			return &config, nil
+		}
+	}
+
 	if config.KeyID == "" {
 		return nil, errors.Errorf("missing kid: %q", file)
 	}
@@ -82,22 +116,31 @@ func LoadConfig(file string) (*Config, error) {
 	return &config, nil
 }
 
-// Load returns new Provider
-func Load(cfgfile string) (*Provider, error) {
+// Load returns new Provider,
+// the crypto provider is used to load keys from HSM and can be nil
+func Load(cfgfile string, crypto *cryptoprov.Crypto) (*Provider, error) {
 	cfg, err := LoadConfig(cfgfile)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	return New(cfg), nil
+
+	dir := filepath.Dir(cfgfile)
+	for _, key := range cfg.Keys {
+		if key.KeyFile != "" && !filepath.IsAbs(key.KeyFile) {
+			key.KeyFile = filepath.Join(dir, key.KeyFile)
+		}
+	}
+	return New(cfg, crypto)
 }
 
-// New returns new Provider
-func New(cfg *Config) *Provider {
+// New returns new Provider,
+// the crypto provider is used to load keys from HSM and can be nil
+func New(cfg *Config, crypto *cryptoprov.Crypto) (*Provider, error) {
 	p := &Provider{
 		issuer:   cfg.Issuer,
 		audience: cfg.Audience,
 		kid:      cfg.KeyID,
-		keys:     map[string][]byte{},
+		keys:     map[string]*signingKey{},
 		role:     cfg.DefaultRole,
 		roles:    map[string]string{},
 	}
@@ -114,7 +157,15 @@ func New(cfg *Config) *Provider {
 
 	if len(cfg.Keys) > 0 {
 		for _, key := range cfg.Keys {
-			p.keys[key.ID] = certutil.SHA256([]byte(key.Seed))
+			if key.KeyFile == "" {
+				p.keys[key.ID] = newHMACKey(key.ID, key.Seed)
+				continue
+			}
+			k, err := loadSignerKey(key.ID, key.KeyFile, crypto)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			p.keys[key.ID] = k
 		}
 
 		if p.kid == "" {
@@ -128,17 +179,36 @@ func New(cfg *Config) *Provider {
 		}
 	}
 
-	return p
+	if cfg.OIDC != nil && cfg.OIDC.Issuer != "" {
+		p.oidc = newOIDCVerifier(cfg.OIDC)
+	}
+
+	return p, nil
+}
+
+// SetRevocationChecker specifies the revocation list,
+// which is checked when the token is verified
+func (p *Provider) SetRevocationChecker(c RevocationChecker) {
+	p.revocations = c
 }
 
-func (p *Provider) currentKey() (string, []byte) {
+// OIDCMaxTokenLifetime returns the maximum lifetime of tokens accepted from OIDC issuer,
+// or zero if OIDC issuer is not configured
+func (p *Provider) OIDCMaxTokenLifetime() time.Duration {
+	if p.oidc == nil {
+		return 0
+	}
+	return p.oidc.maxLifetime
+}
+
+func (p *Provider) currentKey() *signingKey {
 	if key, ok := p.keys[p.kid]; ok {
-		return p.kid, key
+		return key
 	}
-	for id, key := range p.keys {
-		return id, key
+	for _, key := range p.keys {
+		return key
 	}
-	return "", nil
+	return nil
 }
 
 func (p *Provider) userRole(email string) string {
@@ -149,14 +219,23 @@ func (p *Provider) userRole(email string) string {
 	return role
 }
 
+// UserRole returns the role of the tokens issued for the email
+func (p *Provider) UserRole(email string) string {
+	return p.userRole(email)
+}
+
 // SignToken returns signed JWT token with custom claims
 func (p *Provider) SignToken(userInfo *v1.UserInfo, deviceID string, expiry time.Duration) (*v1.Authorization, error) {
-	kid, key := p.currentKey()
+	key := p.currentKey()
+	if key == nil {
+		return nil, errors.New("signing key is not configured")
+	}
 	expiresAt := time.Now().Add(expiry).UTC()
 	claims := customClaims{
 		userInfo,
 		deviceID,
 		jwt.StandardClaims{
+			Id:        guid.MustCreate(),
 			ExpiresAt: expiresAt.Unix(),
 			Issuer:    p.issuer,
 			IssuedAt:  time.Now().UTC().Unix(),
@@ -165,11 +244,11 @@ func (p *Provider) SignToken(userInfo *v1.UserInfo, deviceID string, expiry time
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token.Header["kid"] = kid
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
 
-	// Sign and get the complete encoded token as a string using the secret
-	tokenString, err := token.SignedString(key)
+	// Sign and get the complete encoded token as a string using the key
+	tokenString, err := token.SignedString(key.signKey)
 	if err != nil {
 		return nil, errors.Annotatef(err, "failed to sign token")
 	}
@@ -181,6 +260,7 @@ func (p *Provider) SignToken(userInfo *v1.UserInfo, deviceID string, expiry time
 		Email:       userInfo.Email,
 		UserName:    userInfo.Name,
 		Role:        p.userRole(claims.UserInfo.Email),
+		TokenID:     claims.Id,
 		TokenType:   "jwt",
 		AccessToken: tokenString,
 		ExpiresAt:   expiresAt,
@@ -202,6 +282,57 @@ func (p *Provider) IdentityMapper(r *http.Request) (identity.Identity, error) {
 		return nil, nil
 	}
 
+	if p.oidc != nil {
+		// tokens of OIDC issuer are verified with its keys
+		unverified := jwt.MapClaims{}
+		_, _, err := new(jwt.Parser).ParseUnverified(parts[1], unverified)
+		if err == nil && p.oidc.isIssuer(unverified["iss"]) {
+			return p.oidcIdentity(r, parts[1])
+		}
+	}
+
+	claims, err := p.verifyToken(r, parts[1])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	role := p.userRole(claims.UserInfo.Email)
+	return identity.NewIdentityWithUserInfo(role, claims.UserInfo.Email, "", claims.UserInfo), nil
+}
+
+// TokenInfo provides details of the token issued by the provider
+type TokenInfo struct {
+	ID        string
+	DeviceID  string
+	User      string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// VerifyToken returns details of the token from Authorization header,
+// the token must be issued by the provider and not revoked
+func (p *Provider) VerifyToken(r *http.Request) (*TokenInfo, error) {
+	parts := strings.Split(r.Header.Get(header.Authorization), " ")
+	if len(parts) != 2 || parts[0] != header.Bearer {
+		return nil, errors.Errorf("missing token")
+	}
+
+	claims, err := p.verifyToken(r, parts[1])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &TokenInfo{
+		ID:        claims.Id,
+		DeviceID:  claims.DeviceID,
+		User:      claims.UserInfo.Email,
+		IssuedAt:  time.Unix(claims.IssuedAt, 0).UTC(),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	}, nil
+}
+
+// verifyToken returns claims of the token issued by the provider
+func (p *Provider) verifyToken(r *http.Request, tokenString string) (*customClaims, error) {
 	deviceID := r.Header.Get(header.XDeviceID)
 	claims := &customClaims{
 		nil,
@@ -212,11 +343,7 @@ func (p *Provider) IdentityMapper(r *http.Request) (identity.Identity, error) {
 		},
 	}
 
-	token, err := jwt.ParseWithClaims(parts[1], claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		if kid, ok := token.Header["kid"]; ok {
 			var id string
 			switch t := kid.(type) {
@@ -227,7 +354,11 @@ func (p *Provider) IdentityMapper(r *http.Request) (identity.Identity, error) {
 			}
 
 			if key, ok := p.keys[id]; ok {
-				return key, nil
+				// the algorithm is defined by the key, not by the token
+				if token.Method.Alg() != key.method.Alg() {
+					return nil, errors.Errorf("unexpected signing method: %v", token.Header["alg"])
+				}
+				return key.verifyKey, nil
 			}
 			return nil, errors.Errorf("unexpected kid")
 		}
@@ -247,15 +378,37 @@ func (p *Provider) IdentityMapper(r *http.Request) (identity.Identity, error) {
 		if claims.Audience != p.audience {
 			return nil, errors.Errorf("invalid audience: %s", claims.Audience)
 		}
+		if claims.UserInfo == nil {
+			return nil, errors.Errorf("missing user info")
+		}
 
-		role := p.userRole(claims.UserInfo.Email)
-
-		return identity.NewIdentityWithUserInfo(role, claims.UserInfo.Email, "", claims.UserInfo), nil
+		err = p.checkRevoked(r, claims.Id, claims.DeviceID, claims.UserInfo.Email, claims.IssuedAt)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return claims, nil
 	}
 
 	return nil, errors.Errorf("invalid token")
 }
 
+// checkRevoked returns error if the token is revoked,
+// or the revocation list is not available
+func (p *Provider) checkRevoked(r *http.Request, tokenID, deviceID, user string, issuedAt int64) error {
+	if p.revocations == nil {
+		return nil
+	}
+
+	revoked, err := p.revocations.IsTokenRevoked(r.Context(), tokenID, deviceID, user, time.Unix(issuedAt, 0))
+	if err != nil {
+		return errors.Annotatef(err, "failed to check revocation")
+	}
+	if revoked {
+		return errors.Errorf("token is revoked")
+	}
+	return nil
+}
+
 type customClaims struct {
 	UserInfo *v1.UserInfo `json:"sfu"`
 	DeviceID string
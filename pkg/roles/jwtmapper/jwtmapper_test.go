@@ -5,8 +5,9 @@ import (
 	"testing"
 	"time"
 
-	"github.com/certcentral/enrollme/api/v1"
-	"github.com/certcentral/enrollme/pkg/roles/jwtmapper"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/pkg/roles/jwtmapper"
 	"github.com/go-phorce/dolly/xhttp/header"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -45,29 +46,29 @@ func Test_Config(t *testing.T) {
 }
 
 func Test_Load(t *testing.T) {
-	_, err := jwtmapper.Load("testdata/missing.yaml")
+	_, err := jwtmapper.Load("testdata/missing.yaml", nil)
 	require.Error(t, err)
 	assert.Equal(t, "open testdata/missing.yaml: no such file or directory", err.Error())
 
-	_, err = jwtmapper.Load("testdata/roles_corrupted.1.yaml")
+	_, err = jwtmapper.Load("testdata/roles_corrupted.1.yaml", nil)
 	require.Error(t, err)
 
-	_, err = jwtmapper.Load("testdata/roles_corrupted.2.yaml")
+	_, err = jwtmapper.Load("testdata/roles_corrupted.2.yaml", nil)
 	require.Error(t, err)
 
-	_, err = jwtmapper.Load("testdata/roles.yaml")
+	_, err = jwtmapper.Load("testdata/roles.yaml", nil)
 	require.NoError(t, err)
 
-	_, err = jwtmapper.Load("")
+	_, err = jwtmapper.Load("", nil)
 	require.NoError(t, err)
 }
 
 func Test_Sign(t *testing.T) {
-	p, err := jwtmapper.Load("testdata/roles.yaml")
+	p, err := jwtmapper.Load("testdata/roles.yaml", nil)
 	require.NoError(t, err)
-	p1, err := jwtmapper.Load("testdata/roles.1.yaml")
+	p1, err := jwtmapper.Load("testdata/roles.1.yaml", nil)
 	require.NoError(t, err)
-	p2, err := jwtmapper.Load("testdata/roles.2.yaml")
+	p2, err := jwtmapper.Load("testdata/roles.2.yaml", nil)
 	require.NoError(t, err)
 
 	t.Run("default role", func(t *testing.T) {
@@ -150,7 +151,7 @@ func Test_Sign(t *testing.T) {
 }
 
 func Test_Verify(t *testing.T) {
-	p, err := jwtmapper.Load("testdata/roles.yaml")
+	p, err := jwtmapper.Load("testdata/roles.yaml", nil)
 	require.NoError(t, err)
 
 	userInfo := &v1.UserInfo{
@@ -193,3 +194,112 @@ func setAuthorizationHeader(r *http.Request, token, deviceID string) {
 		r.Header.Set(header.XDeviceID, deviceID)
 	}
 }
+
+func Test_LoadSignerKeys(t *testing.T) {
+	_, err := jwtmapper.Load("testdata/roles_missing_key.yaml", nil)
+	require.Error(t, err)
+
+	_, err = jwtmapper.Load("testdata/roles_unsupported_key.yaml", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "P-384 curve not supported")
+
+	_, err = jwtmapper.New(&jwtmapper.Config{
+		Keys: []*jwtmapper.Key{{ID: "1", KeyFile: "testdata/roles.yaml"}},
+	}, nil)
+	require.Error(t, err)
+}
+
+func Test_SignAsymmetric(t *testing.T) {
+	prsa, err := jwtmapper.Load("testdata/roles.rsa.yaml", nil)
+	require.NoError(t, err)
+	pec, err := jwtmapper.Load("testdata/roles.ec.yaml", nil)
+	require.NoError(t, err)
+	phs, err := jwtmapper.Load("testdata/roles.yaml", nil)
+	require.NoError(t, err)
+
+	userInfo := &v1.UserInfo{
+		ID:    "123",
+		OrgID: "456",
+		Email: "denis@ekspand.com",
+	}
+
+	tcases := []struct {
+		name string
+		p    *jwtmapper.Provider
+		alg  string
+	}{
+		{"RS256", prsa, "RS256"},
+		{"ES256", pec, "ES256"},
+	}
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			auth, err := tc.p.SignToken(userInfo, "device123", time.Minute)
+			require.NoError(t, err)
+
+			token, _, err := new(jwt.Parser).ParseUnverified(auth.AccessToken, &jwt.StandardClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.alg, token.Header["alg"])
+
+			r, _ := http.NewRequest(http.MethodGet, "/", nil)
+			setAuthorizationHeader(r, auth.AccessToken, "device123")
+
+			id, err := tc.p.IdentityMapper(r)
+			require.NoError(t, err)
+			assert.Equal(t, "enrollme-admin", id.Role())
+			assert.Equal(t, userInfo.Email, id.Name())
+		})
+	}
+
+	t.Run("rotated", func(t *testing.T) {
+		// RS256 key "2" is not current in pec, but still can verify
+		auth, err := prsa.SignToken(userInfo, "device123", time.Minute)
+		require.NoError(t, err)
+
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		setAuthorizationHeader(r, auth.AccessToken, "device123")
+		_, err = pec.IdentityMapper(r)
+		require.NoError(t, err)
+	})
+
+	t.Run("alg_mismatch", func(t *testing.T) {
+		// HS256 token signed with kid of RS256 key must be rejected
+		claims := jwt.StandardClaims{
+			Issuer:    "enrollme",
+			Audience:  "enrollme",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"] = "2"
+		s, err := token.SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		setAuthorizationHeader(r, s, "device123")
+		_, err = prsa.IdentityMapper(r)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected signing method: HS256")
+	})
+
+	t.Run("JWKS", func(t *testing.T) {
+		assert.Empty(t, phs.JWKS().Keys)
+
+		keys := prsa.JWKS().Keys
+		require.Len(t, keys, 1)
+		assert.Equal(t, "RSA", keys[0].KeyType)
+		assert.Equal(t, "2", keys[0].KeyID)
+		assert.Equal(t, "RS256", keys[0].Algorithm)
+		assert.Equal(t, "sig", keys[0].Use)
+		assert.Equal(t, "AQAB", keys[0].E)
+		assert.NotEmpty(t, keys[0].N)
+
+		keys = pec.JWKS().Keys
+		require.Len(t, keys, 2)
+		assert.Equal(t, "2", keys[0].KeyID)
+		assert.Equal(t, "3", keys[1].KeyID)
+		assert.Equal(t, "EC", keys[1].KeyType)
+		assert.Equal(t, "ES256", keys[1].Algorithm)
+		assert.Equal(t, "P-256", keys[1].Curve)
+		assert.Len(t, keys[1].X, 43)
+		assert.Len(t, keys[1].Y, 43)
+	})
+}
@@ -0,0 +1,188 @@
+package jwtmapper
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/xpki/certutil"
+	"github.com/go-phorce/dolly/xpki/cryptoprov"
+	"github.com/juju/errors"
+)
+
+// signingKey provides the key to sign and verify tokens
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	// signKey is passed to method.Sign
+	signKey interface{}
+	// verifyKey is passed to method.Verify
+	verifyKey interface{}
+}
+
+func newHMACKey(id, seed string) *signingKey {
+	key := certutil.SHA256([]byte(seed))
+	return &signingKey{
+		id:        id,
+		method:    jwt.SigningMethodHS256,
+		signKey:   key,
+		verifyKey: key,
+	}
+}
+
+// loadSignerKey returns RS256 or ES256 key from the file,
+// which contains PEM-encoded private key or PKCS#11 URI of the key in HSM
+func loadSignerKey(id, file string, prov *cryptoprov.Crypto) (*signingKey, error) {
+	key, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	key = []byte(strings.TrimSpace(string(key)))
+
+	var pvk crypto.PrivateKey
+	if prov != nil {
+		_, pvk, err = prov.LoadPrivateKey(key)
+	} else if strings.HasPrefix(string(key), "pkcs11") {
+		err = errors.Errorf("crypto provider is required for PKCS#11 key")
+	} else {
+		pvk, err = cryptoprov.ParsePrivateKeyPEM(key)
+	}
+	if err != nil {
+		return nil, errors.Annotatef(err, "unable to load key %q from %q", id, file)
+	}
+
+	signer, ok := pvk.(crypto.Signer)
+	if !ok {
+		return nil, errors.NotSupportedf("key %q of %T type", id, pvk)
+	}
+	return newSignerKey(id, signer)
+}
+
+// newSignerKey returns RS256 key for RSA signer, or ES256 key for P-256 signer
+func newSignerKey(id string, signer crypto.Signer) (*signingKey, error) {
+	k := &signingKey{
+		id:        id,
+		signKey:   signer,
+		verifyKey: signer.Public(),
+	}
+
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		k.method = signerMethodRS256
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.NotSupportedf("key %q on %s curve", id, pub.Curve.Params().Name)
+		}
+		k.method = signerMethodES256
+	default:
+		return nil, errors.NotSupportedf("key %q of %T type", id, pub)
+	}
+	return k, nil
+}
+
+// jwk returns the public key in JWK format, or nil for symmetric key
+func (k *signingKey) jwk() *v1.JSONWebKey {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return &v1.JSONWebKey{
+			KeyType:   "RSA",
+			KeyID:     k.id,
+			Use:       "sig",
+			Algorithm: k.method.Alg(),
+			N:         encode(pub.N.Bytes()),
+			E:         encode(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return &v1.JSONWebKey{
+			KeyType:   "EC",
+			KeyID:     k.id,
+			Use:       "sig",
+			Algorithm: k.method.Alg(),
+			Curve:     pub.Curve.Params().Name,
+			X:         encode(padLeft(pub.X.Bytes(), size)),
+			Y:         encode(padLeft(pub.Y.Bytes(), size)),
+		}
+	}
+	return nil
+}
+
+// JWKS returns the public keys to verify tokens
+func (p *Provider) JWKS() *v1.JSONWebKeySet {
+	set := &v1.JSONWebKeySet{
+		Keys: []*v1.JSONWebKey{},
+	}
+	for _, k := range p.keys {
+		if jwk := k.jwk(); jwk != nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+	return set
+}
+
+// signerMethod signs with crypto.Signer, which allows to use keys in HSM,
+// and verifies with the standard method
+type signerMethod struct {
+	jwt.SigningMethod
+	hash crypto.Hash
+	// size specifies the size of R and S in bytes for ECDSA signature
+	size int
+}
+
+var (
+	signerMethodRS256 = &signerMethod{SigningMethod: jwt.SigningMethodRS256, hash: crypto.SHA256}
+	signerMethodES256 = &signerMethod{SigningMethod: jwt.SigningMethodES256, hash: crypto.SHA256, size: 32}
+)
+
+// Sign returns encoded signature
+func (m *signerMethod) Sign(signingString string, key interface{}) (string, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	h := m.hash.New()
+	h.Write([]byte(signingString))
+
+	sig, err := signer.Sign(rand.Reader, h.Sum(nil), m.hash)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+
+	if m.size > 0 {
+		// JWS requires R || S instead of ASN.1 encoded ECDSA signature
+		var es struct {
+			R, S *big.Int
+		}
+		if _, err = asn1.Unmarshal(sig, &es); err != nil {
+			return "", errors.Annotate(err, "invalid ECDSA signature")
+		}
+		sig = append(padLeft(es.R.Bytes(), m.size), padLeft(es.S.Bytes(), m.size)...)
+	}
+
+	return jwt.EncodeSegment(sig), nil
+}
+
+func padLeft(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
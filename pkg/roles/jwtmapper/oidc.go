@@ -0,0 +1,374 @@
+package jwtmapper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/juju/errors"
+)
+
+const (
+	// DefaultRoleClaim specifies the claim of OIDC token mapped to roles
+	DefaultRoleClaim = "email"
+	// DefaultKeysRefresh specifies how often OIDC issuer's keys are fetched
+	DefaultKeysRefresh = time.Hour
+	// DefaultMaxTokenLifetime specifies the maximum lifetime of OIDC tokens
+	DefaultMaxTokenLifetime = 24 * time.Hour
+	// wellKnownConfiguration is the path of OIDC discovery document
+	wellKnownConfiguration = "/.well-known/openid-configuration"
+)
+
+// minKeysRefresh limits how often the keys are fetched on unknown kid
+var minKeysRefresh = 10 * time.Second
+
+// OIDCConfig provides configuration of external OpenID Connect issuer
+type OIDCConfig struct {
+	// Issuer specifies URL of the issuer,
+	// the discovery document is fetched from Issuer/.well-known/openid-configuration
+	Issuer string `json:"issuer" yaml:"issuer"`
+	// Audience specifies expected audience claim, usually the client ID
+	Audience string `json:"audience" yaml:"audience"`
+	// RoleClaim specifies the claim mapped to roles by RolesMap,
+	// the claim can be a string, or a list of strings such as groups.
+	// Default is email.
+	RoleClaim string `json:"role_claim" yaml:"role_claim"`
+	// KeysRefresh specifies how often the issuer's keys are fetched,
+	// unknown kid forces the keys to be fetched earlier. Default is 1h.
+	KeysRefresh time.Duration `json:"keys_refresh" yaml:"keys_refresh"`
+	// MaxTokenLifetime specifies the maximum lifetime of accepted tokens,
+	// the revocations of users and devices are kept for this time. Default is 24h.
+	MaxTokenLifetime time.Duration `json:"max_token_lifetime" yaml:"max_token_lifetime"`
+}
+
+// oidcVerifier provides keys of OIDC issuer,
+// the discovery document and JWKS are fetched on demand and cached
+type oidcVerifier struct {
+	issuer      string
+	audience    string
+	claim       string
+	keysRefresh time.Duration
+	maxLifetime time.Duration
+	client      *http.Client
+
+	lock      sync.Mutex
+	jwksURI   string
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	// fetching is closed, when the keys being fetched are available
+	fetching chan struct{}
+	fetchErr error
+}
+
+func newOIDCVerifier(cfg *OIDCConfig) *oidcVerifier {
+	v := &oidcVerifier{
+		issuer:      strings.TrimSuffix(cfg.Issuer, "/"),
+		audience:    cfg.Audience,
+		claim:       cfg.RoleClaim,
+		keysRefresh: cfg.KeysRefresh,
+		maxLifetime: cfg.MaxTokenLifetime,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		keys:        map[string]interface{}{},
+	}
+	if v.claim == "" {
+		v.claim = DefaultRoleClaim
+	}
+	if v.keysRefresh == 0 {
+		v.keysRefresh = DefaultKeysRefresh
+	}
+	if v.maxLifetime == 0 {
+		v.maxLifetime = DefaultMaxTokenLifetime
+	}
+	return v
+}
+
+// isIssuer returns true if iss claim specifies the issuer
+func (v *oidcVerifier) isIssuer(iss interface{}) bool {
+	s, ok := iss.(string)
+	return ok && strings.TrimSuffix(s, "/") == v.issuer
+}
+
+// key returns the public key of the issuer,
+// the keys are fetched without the lock, so the cached keys are served meanwhile
+func (v *oidcVerifier) key(kid string) (interface{}, error) {
+	v.lock.Lock()
+	key, ok := v.keys[kid]
+	age := time.Since(v.fetchedAt)
+	if (ok && age < v.keysRefresh) || (!ok && age < minKeysRefresh) {
+		v.lock.Unlock()
+		if !ok {
+			return nil, errors.Errorf("unexpected kid")
+		}
+		return key, nil
+	}
+
+	done := v.fetching
+	if done == nil {
+		// the timestamp is updated on failure as well, to limit the requests rate
+		done = make(chan struct{})
+		v.fetching = done
+		v.fetchedAt = time.Now()
+		jwksURI := v.jwksURI
+		v.lock.Unlock()
+
+		keys, jwksURI, err := v.fetchKeys(jwksURI)
+
+		v.lock.Lock()
+		if err == nil {
+			v.keys = keys
+			v.jwksURI = jwksURI
+		}
+		v.fetchErr = err
+		v.fetching = nil
+		close(done)
+		v.lock.Unlock()
+	} else {
+		v.lock.Unlock()
+		if ok {
+			return key, nil
+		}
+		<-done
+	}
+
+	v.lock.Lock()
+	err := v.fetchErr
+	fetched, found := v.keys[kid]
+	v.lock.Unlock()
+
+	if err != nil {
+		if ok {
+			// the issuer is not reachable, the cached key is still trusted
+			logger.Errorf("api=oidc, issuer=%q, reason=fetchKeys, err=[%v]", v.issuer, errors.ErrorStack(err))
+			return key, nil
+		}
+		return nil, errors.Trace(err)
+	}
+	if !found {
+		return nil, errors.Errorf("unexpected kid")
+	}
+	return fetched, nil
+}
+
+// fetchKeys returns the keys of the issuer and jwks_uri,
+// the discovery document is fetched if jwksURI is not known yet
+func (v *oidcVerifier) fetchKeys(jwksURI string) (map[string]interface{}, string, error) {
+	if jwksURI == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JwksURI string `json:"jwks_uri"`
+		}
+		err := v.get(v.issuer+wellKnownConfiguration, &discovery)
+		if err != nil {
+			return nil, "", errors.Annotate(err, "failed to fetch OIDC configuration")
+		}
+		if strings.TrimSuffix(discovery.Issuer, "/") != v.issuer {
+			return nil, "", errors.Errorf("OIDC configuration is for unexpected issuer: %q", discovery.Issuer)
+		}
+		if discovery.JwksURI == "" {
+			return nil, "", errors.Errorf("OIDC configuration is missing jwks_uri")
+		}
+		jwksURI = discovery.JwksURI
+	}
+
+	var set v1.JSONWebKeySet
+	err := v.get(jwksURI, &set)
+	if err != nil {
+		return nil, "", errors.Annotate(err, "failed to fetch OIDC keys")
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := publicKey(jwk)
+		if err != nil {
+			logger.Warningf("api=oidc, issuer=%q, kid=%q, err=[%v]", v.issuer, jwk.KeyID, err)
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	logger.Infof("api=oidc, issuer=%q, keys=%d", v.issuer, len(keys))
+	return keys, jwksURI, nil
+}
+
+func (v *oidcVerifier) get(url string, res interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return errors.Trace(json.NewDecoder(resp.Body).Decode(res))
+}
+
+// oidcIdentity returns identity from the token issued by OIDC issuer
+func (p *Provider) oidcIdentity(r *http.Request, tokenString string) (identity.Identity, error) {
+	v := p.oidc
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		default:
+			return nil, errors.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.Errorf("missing kid")
+		}
+		return v.key(kid)
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "failed to verify token")
+	}
+
+	// MapClaims checks exp only when present, the external token must expire
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now.Unix(), true) {
+		return nil, errors.Errorf("missing or invalid exp")
+	}
+	// the revocations are kept for the max lifetime only
+	if claims.VerifyExpiresAt(now.Add(v.maxLifetime).Unix(), false) {
+		return nil, errors.Errorf("token lifetime exceeds %v", v.maxLifetime)
+	}
+	if !v.isIssuer(claims["iss"]) {
+		return nil, errors.Errorf("invalid issuer: %v", claims["iss"])
+	}
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return nil, errors.Errorf("invalid audience: %v", claims["aud"])
+	}
+
+	iat, _ := claims["iat"].(float64)
+	err = p.checkRevoked(r, stringClaim(claims, "jti"), "", stringClaim(claims, "email"), int64(iat))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	userInfo := &v1.UserInfo{
+		ID:                stringClaim(claims, "sub"),
+		Email:             stringClaim(claims, "email"),
+		Name:              stringClaim(claims, "name"),
+		PreferredUserName: stringClaim(claims, "preferred_username"),
+		Active:            true,
+	}
+	userInfo.EmailVerified, _ = claims["email_verified"].(bool)
+
+	// unverified email is not trusted to map the role or the local user
+	role := claims[v.claim]
+	if !userInfo.EmailVerified {
+		if v.claim == "email" {
+			role = nil
+		}
+		userInfo.Email = ""
+	}
+
+	name := userInfo.Email
+	if name == "" {
+		name = userInfo.ID
+	}
+
+	return identity.NewIdentityWithUserInfo(p.claimRole(role), name, "", userInfo), nil
+}
+
+// claimRole returns the role mapped to the claim value,
+// for a list the first value found in the roles map is used
+func (p *Provider) claimRole(claim interface{}) string {
+	switch val := claim.(type) {
+	case string:
+		return p.userRole(val)
+	case []interface{}:
+		for _, v := range val {
+			if s, ok := v.(string); ok {
+				if role, ok := p.roles[s]; ok {
+					return role
+				}
+			}
+		}
+	}
+	return p.role
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// hasAudience returns true if aud claim, a string or a list, has the audience
+func hasAudience(aud interface{}, audience string) bool {
+	switch val := aud.(type) {
+	case string:
+		return val == audience
+	case []interface{}:
+		for _, v := range val {
+			if v == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// publicKey returns RSA or ECDSA public key from JWK
+func publicKey(jwk *v1.JSONWebKey) (interface{}, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, errors.NotValidf("JWK parameter")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.NotSupportedf("curve %q", jwk.Curve)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.NotValidf("EC point")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, errors.NotSupportedf("key type %q", jwk.KeyType)
+}
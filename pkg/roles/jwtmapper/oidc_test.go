@@ -0,0 +1,361 @@
+package jwtmapper_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/pkg/roles/jwtmapper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// oidcStub serves OIDC discovery document and JWKS
+type oidcStub struct {
+	*httptest.Server
+
+	lock      sync.Mutex
+	keys      map[string]*ecdsa.PrivateKey
+	jwksCalls int
+	// delay specifies how long JWKS response is delayed
+	delay time.Duration
+}
+
+func newOIDCStub(t *testing.T, kids ...string) *oidcStub {
+	s := &oidcStub{keys: map[string]*ecdsa.PrivateKey{}}
+	for _, kid := range kids {
+		s.addKey(t, kid)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   s.URL,
+			"jwks_uri": s.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		delay := s.delay
+		s.lock.Unlock()
+		time.Sleep(delay)
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.jwksCalls++
+
+		encode := base64.RawURLEncoding.EncodeToString
+		set := &v1.JSONWebKeySet{}
+		for kid, k := range s.keys {
+			set.Keys = append(set.Keys, &v1.JSONWebKey{
+				KeyType: "EC",
+				KeyID:   kid,
+				Use:     "sig",
+				Curve:   "P-256",
+				X:       encode(k.X.Bytes()),
+				Y:       encode(k.Y.Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *oidcStub) addKey(t *testing.T, kid string) {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.keys[kid] = k
+}
+
+func (s *oidcStub) calls() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.jwksCalls
+}
+
+func (s *oidcStub) token(t *testing.T, kid string, claims jwt.MapClaims) string {
+	s.lock.Lock()
+	key := s.keys[kid]
+	s.lock.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	ts, err := token.SignedString(key)
+	require.NoError(t, err)
+	return ts
+}
+
+func (s *oidcStub) claims(email string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            s.URL,
+		"aud":            []string{"dolly", "other"},
+		"sub":            "u123",
+		"email":          email,
+		"email_verified": true,
+		"name":           "Denis",
+		"groups":         []string{"staff", "dolly-admins"},
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func Test_LoadConfigOIDC(t *testing.T) {
+	_, err := jwtmapper.LoadConfig("testdata/roles_oidc_no_audience.yaml")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing oidc audience")
+
+	cfg, err := jwtmapper.LoadConfig("testdata/roles_oidc.yaml")
+	require.NoError(t, err)
+	require.NotNil(t, cfg.OIDC)
+	assert.Equal(t, "https://login.example.com", cfg.OIDC.Issuer)
+	assert.Equal(t, "groups", cfg.OIDC.RoleClaim)
+	assert.Equal(t, 30*time.Minute, cfg.OIDC.KeysRefresh)
+	assert.Empty(t, cfg.Keys)
+}
+
+func Test_OIDC(t *testing.T) {
+	stub := newOIDCStub(t, "k1")
+	defer stub.Close()
+
+	p, err := jwtmapper.New(&jwtmapper.Config{
+		DefaultRole: "dolly-client",
+		RolesMap: map[string][]string{
+			"dolly-admin": {"denis@ekspand.com", "dolly-admins"},
+		},
+		OIDC: &jwtmapper.OIDCConfig{
+			Issuer:   stub.URL,
+			Audience: "dolly",
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	identityFor := func(token string) (string, string, error) {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		setAuthorizationHeader(r, token, "")
+		id, err := p.IdentityMapper(r)
+		if err != nil {
+			return "", "", err
+		}
+		return id.Role(), id.Name(), nil
+	}
+
+	t.Run("email", func(t *testing.T) {
+		role, name, err := identityFor(stub.token(t, "k1", stub.claims("denis@ekspand.com")))
+		require.NoError(t, err)
+		assert.Equal(t, "dolly-admin", role)
+		assert.Equal(t, "denis@ekspand.com", name)
+
+		role, _, err = identityFor(stub.token(t, "k1", stub.claims("guest@ekspand.com")))
+		require.NoError(t, err)
+		assert.Equal(t, "dolly-client", role)
+		assert.Equal(t, 1, stub.calls(), "keys must be cached")
+	})
+
+	t.Run("unverified_email", func(t *testing.T) {
+		claims := stub.claims("denis@ekspand.com")
+		claims["email_verified"] = false
+		role, name, err := identityFor(stub.token(t, "k1", claims))
+		require.NoError(t, err)
+		assert.Equal(t, "dolly-client", role)
+		assert.Equal(t, "u123", name)
+
+		delete(claims, "email_verified")
+		role, _, err = identityFor(stub.token(t, "k1", claims))
+		require.NoError(t, err)
+		assert.Equal(t, "dolly-client", role)
+	})
+
+	t.Run("invalid_audience", func(t *testing.T) {
+		claims := stub.claims("denis@ekspand.com")
+		claims["aud"] = "other"
+		_, _, err := identityFor(stub.token(t, "k1", claims))
+		require.Error(t, err)
+		assert.Equal(t, "invalid audience: other", err.Error())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		claims := stub.claims("denis@ekspand.com")
+		claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		_, _, err := identityFor(stub.token(t, "k1", claims))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Token is expired")
+	})
+
+	t.Run("no_exp", func(t *testing.T) {
+		claims := stub.claims("denis@ekspand.com")
+		delete(claims, "exp")
+		_, _, err := identityFor(stub.token(t, "k1", claims))
+		require.Error(t, err)
+		assert.Equal(t, "missing or invalid exp", err.Error())
+
+		claims["exp"] = "never"
+		_, _, err = identityFor(stub.token(t, "k1", claims))
+		require.Error(t, err)
+	})
+
+	t.Run("long_lifetime", func(t *testing.T) {
+		claims := stub.claims("denis@ekspand.com")
+		claims["exp"] = time.Now().Add(jwtmapper.DefaultMaxTokenLifetime + time.Minute).Unix()
+		_, _, err := identityFor(stub.token(t, "k1", claims))
+		require.Error(t, err)
+		assert.Equal(t, "token lifetime exceeds 24h0m0s", err.Error())
+		assert.Equal(t, jwtmapper.DefaultMaxTokenLifetime, p.OIDCMaxTokenLifetime())
+	})
+
+	t.Run("rotated", func(t *testing.T) {
+		old := jwtmapper.SetMinKeysRefresh(0)
+		defer jwtmapper.SetMinKeysRefresh(old)
+
+		stub.addKey(t, "k2")
+		calls := stub.calls()
+
+		_, _, err := identityFor(stub.token(t, "k2", stub.claims("denis@ekspand.com")))
+		require.NoError(t, err)
+		assert.Equal(t, calls+1, stub.calls())
+
+		_, _, err = identityFor(stub.token(t, "k2", stub.claims("denis@ekspand.com")))
+		require.NoError(t, err)
+		assert.Equal(t, calls+1, stub.calls())
+	})
+
+	t.Run("unknown_kid", func(t *testing.T) {
+		other := newOIDCStub(t, "k3")
+		defer other.Close()
+
+		claims := stub.claims("denis@ekspand.com")
+		_, _, err := identityFor(other.token(t, "k3", claims))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected kid")
+
+		// the rate of fetching keys is limited
+		calls := stub.calls()
+		_, _, err = identityFor(other.token(t, "k3", claims))
+		require.Error(t, err)
+		assert.Equal(t, calls, stub.calls())
+	})
+
+	t.Run("hmac", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, stub.claims("denis@ekspand.com"))
+		token.Header["kid"] = "k1"
+		ts, err := token.SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		_, _, err = identityFor(ts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected signing method: HS256")
+	})
+
+	t.Run("local", func(t *testing.T) {
+		// tokens of other issuers are verified with local keys
+		claims := stub.claims("denis@ekspand.com")
+		claims["iss"] = "jwt"
+		claims["aud"] = "jwt"
+		_, _, err := identityFor(stub.token(t, "k1", claims))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected kid")
+	})
+}
+
+func Test_OIDCGroups(t *testing.T) {
+	stub := newOIDCStub(t, "k1")
+	defer stub.Close()
+
+	p, err := jwtmapper.New(&jwtmapper.Config{
+		DefaultRole: "dolly-client",
+		RolesMap: map[string][]string{
+			"dolly-admin": {"dolly-admins"},
+		},
+		OIDC: &jwtmapper.OIDCConfig{
+			Issuer:    stub.URL + "/",
+			Audience:  "dolly",
+			RoleClaim: "groups",
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	setAuthorizationHeader(r, stub.token(t, "k1", stub.claims("someone@ekspand.com")), "")
+	id, err := p.IdentityMapper(r)
+	require.NoError(t, err)
+	assert.Equal(t, "dolly-admin", id.Role())
+	assert.Equal(t, "someone@ekspand.com", id.Name())
+
+	info, ok := id.UserInfo().(*v1.UserInfo)
+	require.True(t, ok)
+	assert.Equal(t, "u123", info.ID)
+	assert.Equal(t, "Denis", info.Name)
+}
+
+func Test_OIDCSlowIssuer(t *testing.T) {
+	stub := newOIDCStub(t, "k1")
+	defer stub.Close()
+
+	p, err := jwtmapper.New(&jwtmapper.Config{
+		OIDC: &jwtmapper.OIDCConfig{
+			Issuer:      stub.URL,
+			Audience:    "dolly",
+			KeysRefresh: 50 * time.Millisecond,
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	token := stub.token(t, "k1", stub.claims("denis@ekspand.com"))
+	verify := func() error {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		setAuthorizationHeader(r, token, "")
+		_, err := p.IdentityMapper(r)
+		return err
+	}
+	require.NoError(t, verify())
+
+	stub.lock.Lock()
+	stub.delay = 500 * time.Millisecond
+	stub.lock.Unlock()
+	time.Sleep(100 * time.Millisecond)
+
+	// the first request refreshes the keys
+	refreshed := make(chan error)
+	go func() {
+		refreshed <- verify()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// the other requests are served with the cached key meanwhile
+	started := time.Now()
+	require.NoError(t, verify())
+	assert.True(t, time.Since(started) < 200*time.Millisecond, "cached key must be served while the keys are fetched")
+	require.NoError(t, <-refreshed)
+	assert.Equal(t, 2, stub.calls())
+}
+
+func Test_OIDCUnreachable(t *testing.T) {
+	stub := newOIDCStub(t, "k1")
+	token := stub.token(t, "k1", stub.claims("denis@ekspand.com"))
+	stub.Close()
+
+	p, err := jwtmapper.New(&jwtmapper.Config{
+		OIDC: &jwtmapper.OIDCConfig{
+			Issuer:   stub.URL,
+			Audience: "dolly",
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	setAuthorizationHeader(r, token, "")
+	_, err = p.IdentityMapper(r)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to fetch OIDC configuration")
+}
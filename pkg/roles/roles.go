@@ -2,17 +2,25 @@ package roles
 
 import (
 	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-phorce/dolly-test/pkg/roles/apikeymapper"
 	"github.com/go-phorce/dolly-test/pkg/roles/certmapper"
 	"github.com/go-phorce/dolly-test/pkg/roles/jwtmapper"
 	"github.com/go-phorce/dolly/xhttp/identity"
 	"github.com/go-phorce/dolly/xlog"
+	"github.com/go-phorce/dolly/xpki/cryptoprov"
 	"github.com/juju/errors"
 )
 
 var logger = xlog.NewPackageLogger("github.com/certcentral/enrollme/pkg", "roles")
 
+// DefaultWatchInterval specifies how often the mapper files are checked for changes
+const DefaultWatchInterval = 5 * time.Second
+
 // IdentityProvider interface to extract identity from requests
 type IdentityProvider interface {
 	// Applicable returns true if the provider is applicable for the request
@@ -21,53 +29,349 @@ type IdentityProvider interface {
 	IdentityMapper(*http.Request) (identity.Identity, error)
 }
 
-// Provider for authz identity
+// Provider for authz identity,
+// the mappers are swapped when their files are reloaded
 type Provider struct {
-	CertMapper   *certmapper.Provider
-	JwtMapper    *jwtmapper.Provider
-	APIkeyMapper *apikeymapper.Provider
+	crypto *cryptoprov.Crypto
+	files  []*mapperFile
+
+	lock         sync.RWMutex
+	certMapper   *certmapper.Provider
+	jwtMapper    *jwtmapper.Provider
+	apiKeyMapper *apikeymapper.Provider
+	revocations  jwtmapper.RevocationChecker
+	keyStore     apikeymapper.KeyStore
+
+	// providers is the registry of identity providers by name
+	providers map[string]IdentityProvider
+	// order specifies the order of providers to check
+	order []string
+	// required specifies providers required for paths
+	required []*requiredProviders
+
+	// reloadLock serializes reloads from the watcher and Reload
+	reloadLock sync.Mutex
+	stop       chan struct{}
+}
+
+// mapperFile specifies the file of a mapper
+type mapperFile struct {
+	name string
+	file string
+	// parse loads the mapper from the file
+	parse func(file string) (interface{}, error)
+	// install replaces the mapper, it is called with the lock held
+	install func(m interface{})
+	modTime time.Time
 }
 
-// New returns Authz provider instance
-func New(jwtMapper, apiKeyMapper, certMapper string) (*Provider, error) {
-	var err error
-	prov := new(Provider)
+// New returns Authz provider instance,
+// the crypto provider is used to load JWT signing keys from HSM and can be nil
+func New(jwtMapper, apiKeyMapper, certMapper string, crypto *cryptoprov.Crypto) (*Provider, error) {
+	prov := &Provider{
+		crypto: crypto,
+	}
+	prov.registerMappers()
 
 	if certMapper != "" {
-		prov.CertMapper, err = certmapper.Load(certMapper)
-		if err != nil {
-			return nil, errors.Annotatef(err, "failed to load cert mapper")
-		}
+		prov.files = append(prov.files, prov.certMapperFile(certMapper))
 	}
 	if jwtMapper != "" {
-		prov.JwtMapper, err = jwtmapper.Load(jwtMapper)
-		if err != nil {
-			return nil, errors.Annotatef(err, "failed to load JWT mapper")
-		}
+		prov.files = append(prov.files, prov.jwtMapperFile(jwtMapper))
 	}
 	if apiKeyMapper != "" {
-		prov.APIkeyMapper, err = apikeymapper.Load(apiKeyMapper)
-		if err != nil {
-			return nil, errors.Annotatef(err, "failed to load API-Key mapper")
+		prov.files = append(prov.files, prov.apiKeyMapperFile(apiKeyMapper))
+	}
+
+	for _, f := range prov.files {
+		f.modTime = modTime(f.file)
+		if err := prov.load(f); err != nil {
+			return nil, errors.Annotatef(err, "failed to load %s", f.name)
 		}
 	}
 
 	return prov, nil
 }
 
-// IdentityMapper returns identity from the request
-func (p *Provider) IdentityMapper(r *http.Request) (identity.Identity, error) {
-	if p.JwtMapper != nil && p.JwtMapper.Applicable(r) {
-		return p.JwtMapper.IdentityMapper(r)
+// CertMapper returns the current cert mapper, or nil if not configured
+func (p *Provider) CertMapper() *certmapper.Provider {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.certMapper
+}
+
+// JwtMapper returns the current JWT mapper, or nil if not configured
+func (p *Provider) JwtMapper() *jwtmapper.Provider {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.jwtMapper
+}
+
+// APIKeyMapper returns the current API-Key mapper, or nil if not configured
+func (p *Provider) APIKeyMapper() *apikeymapper.Provider {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.apiKeyMapper
+}
+
+// SetRevocationChecker specifies the revocation list of JWT mapper,
+// it is applied to the reloaded mapper as well
+func (p *Provider) SetRevocationChecker(c jwtmapper.RevocationChecker) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.revocations = c
+	if p.jwtMapper != nil {
+		p.jwtMapper.SetRevocationChecker(c)
+	}
+}
+
+// SetAPIKeyStore specifies the store of API keys issued at runtime,
+// it is applied to the reloaded mapper as well.
+// If API-Key mapper is not configured, then the default one is created.
+func (p *Provider) SetAPIKeyStore(s apikeymapper.KeyStore) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.keyStore = s
+	if p.apiKeyMapper == nil {
+		p.apiKeyMapper = apikeymapper.New(&apikeymapper.Config{})
+	}
+	p.apiKeyMapper.SetKeyStore(s)
+}
+
+func (p *Provider) certMapperFile(file string) *mapperFile {
+	return &mapperFile{
+		name: "cert mapper",
+		file: file,
+		parse: func(file string) (interface{}, error) {
+			return certmapper.Load(file)
+		},
+		install: func(m interface{}) {
+			if p.certMapper != nil {
+				p.certMapper.Close()
+			}
+			p.certMapper = m.(*certmapper.Provider)
+		},
+	}
+}
+
+func (p *Provider) jwtMapperFile(file string) *mapperFile {
+	return &mapperFile{
+		name: "JWT mapper",
+		file: file,
+		parse: func(file string) (interface{}, error) {
+			return jwtmapper.Load(file, p.crypto)
+		},
+		install: func(m interface{}) {
+			jm := m.(*jwtmapper.Provider)
+			if p.revocations != nil {
+				jm.SetRevocationChecker(p.revocations)
+			}
+			p.jwtMapper = jm
+		},
+	}
+}
+
+func (p *Provider) apiKeyMapperFile(file string) *mapperFile {
+	return &mapperFile{
+		name: "API-Key mapper",
+		file: file,
+		parse: func(file string) (interface{}, error) {
+			return apikeymapper.Load(file)
+		},
+		install: func(m interface{}) {
+			am := m.(*apikeymapper.Provider)
+			if p.keyStore != nil {
+				am.SetKeyStore(p.keyStore)
+			}
+			p.apiKeyMapper = am
+		},
+	}
+}
+
+// load parses the mapper file, and replaces the mapper
+func (p *Provider) load(f *mapperFile) error {
+	m, err := f.parse(f.file)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	f.install(m)
+	return nil
+}
+
+// SetFiles specifies the mapper files, and loads the files, whose location is changed.
+// The mapper, whose file is not specified, is removed,
+// except JWT mapper, which is used by the auth service and requires restart to be removed.
+// On failure, the mappers keep the last good configuration.
+func (p *Provider) SetFiles(jwtMapper, apiKeyMapper, certMapper string) error {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+
+	current := map[string]*mapperFile{}
+	for _, f := range p.files {
+		current[f.name] = f
+	}
+
+	if jwtMapper == "" && current["JWT mapper"] != nil {
+		return errors.New("JWT mapper can not be removed without restart")
+	}
+
+	var files []*mapperFile
+	var changed []*mapperFile
+	for _, f := range []*mapperFile{
+		p.certMapperFile(certMapper),
+		p.jwtMapperFile(jwtMapper),
+		p.apiKeyMapperFile(apiKeyMapper),
+	} {
+		if f.file == "" {
+			continue
+		}
+		if c := current[f.name]; c != nil && c.file == f.file {
+			files = append(files, c)
+			continue
+		}
+		files = append(files, f)
+		changed = append(changed, f)
+	}
+
+	// parse all new files before any mapper is changed
+	mappers := make([]interface{}, len(changed))
+	for i, f := range changed {
+		f.modTime = modTime(f.file)
+		m, err := f.parse(f.file)
+		if err != nil {
+			return errors.Annotatef(err, "failed to load %s", f.name)
+		}
+		mappers[i] = m
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for i, f := range changed {
+		f.install(mappers[i])
+		logger.Noticef("api=SetFiles, mapper=%q, file=%q", f.name, f.file)
+	}
+	if certMapper == "" && p.certMapper != nil {
+		p.certMapper.Close()
+		p.certMapper = nil
+	}
+	if apiKeyMapper == "" && current["API-Key mapper"] != nil {
+		// the API keys issued at runtime are still served by the default mapper
+		p.apiKeyMapper = nil
+		if p.keyStore != nil {
+			p.apiKeyMapper = apikeymapper.New(&apikeymapper.Config{})
+			p.apiKeyMapper.SetKeyStore(p.keyStore)
+		}
+	}
+
+	p.files = files
+	return nil
+}
+
+// Reload loads all mapper files again.
+// The mapper, whose file fails to load, keeps the last good configuration.
+func (p *Provider) Reload() error {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+
+	var failed []string
+	for _, f := range p.files {
+		if err := p.reload(f); err != nil {
+			failed = append(failed, err.Error())
+		}
 	}
-	if p.APIkeyMapper != nil && p.APIkeyMapper.Applicable(r) {
-		return p.APIkeyMapper.IdentityMapper(r)
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
 	}
-	if p.CertMapper != nil && p.CertMapper.Applicable(r) {
-		return p.CertMapper.IdentityMapper(r)
+	return nil
+}
+
+func (p *Provider) reload(f *mapperFile) error {
+	// the time is updated on failure as well,
+	// so the broken file is not reloaded until changed again
+	f.modTime = modTime(f.file)
+
+	if err := p.load(f); err != nil {
+		logger.Errorf("api=reload, mapper=%q, file=%q, err=[%v]", f.name, f.file, err)
+		return errors.Annotatef(err, "failed to reload %s", f.name)
 	}
 
-	// if none of mappers are applicable or configured,
-	// then use default guest mapper
-	return identity.GuestIdentityMapper(r)
+	logger.Noticef("api=reload, mapper=%q, file=%q", f.name, f.file)
+	return nil
+}
+
+// Watch starts to check the mapper files for changes with the interval,
+// and reloads the changed files until the provider is closed
+func (p *Provider) Watch(interval time.Duration) {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+
+	if p.stop != nil || len(p.files) == 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	p.stop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.reloadChanged(stop)
+			}
+		}
+	}()
+}
+
+func (p *Provider) reloadChanged(stop chan struct{}) {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+
+	// the tick is received while Close is waiting for the lock
+	if p.stop != stop {
+		return
+	}
+	for _, f := range p.files {
+		if !modTime(f.file).Equal(f.modTime) {
+			p.reload(f)
+		}
+	}
+}
+
+// Close stops watching the mapper files, and background tasks of the mappers
+func (p *Provider) Close() error {
+	p.reloadLock.Lock()
+	defer p.reloadLock.Unlock()
+
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.certMapper != nil {
+		p.certMapper.Close()
+	}
+	return nil
+}
+
+// modTime returns modification time of the file,
+// or zero time if the file does not exist
+func modTime(file string) time.Time {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
 }
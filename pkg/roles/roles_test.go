@@ -4,18 +4,23 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
-	"github.com/certcentral/enrollme/pkg/roles"
-	"github.com/certcentral/enrollme/pkg/roles/apikeymapper"
+	"github.com/go-phorce/dolly-test/pkg/roles"
+	"github.com/go-phorce/dolly-test/pkg/roles/apikeymapper"
 	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xpki/certutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func Test_Empty(t *testing.T) {
-	p, err := roles.New("", "", "")
+	p, err := roles.New("", "", "", nil)
 	require.NoError(t, err)
 
 	r, _ := http.NewRequest(http.MethodGet, "/", nil)
@@ -26,15 +31,15 @@ func Test_Empty(t *testing.T) {
 }
 
 func Test_Notfound(t *testing.T) {
-	_, err := roles.New("missing_roles.yaml", "", "")
+	_, err := roles.New("missing_roles.yaml", "", "", nil)
 	require.Error(t, err)
 	assert.Equal(t, "failed to load JWT mapper: open missing_roles.yaml: no such file or directory", err.Error())
 
-	_, err = roles.New("", "missing_roles.yaml", "")
+	_, err = roles.New("", "missing_roles.yaml", "", nil)
 	require.Error(t, err)
 	assert.Equal(t, "failed to load API-Key mapper: open missing_roles.yaml: no such file or directory", err.Error())
 
-	_, err = roles.New("", "", "missing_roles.yaml")
+	_, err = roles.New("", "", "missing_roles.yaml", nil)
 	require.Error(t, err)
 	assert.Equal(t, "failed to load cert mapper: open missing_roles.yaml: no such file or directory", err.Error())
 }
@@ -43,7 +48,8 @@ func Test_All(t *testing.T) {
 	p, err := roles.New(
 		"jwtmapper/testdata/roles.yaml",
 		"apikeymapper/testdata/roles.yaml",
-		"certmapper/testdata/roles.yaml")
+		"certmapper/testdata/roles.yaml",
+		nil)
 	require.NoError(t, err)
 
 	t.Run("apikeymapper", func(t *testing.T) {
@@ -84,3 +90,111 @@ func Test_All(t *testing.T) {
 		assert.Equal(t, "enrollme-peer/peers.enrollme2.ekspand.com", id.String())
 	})
 }
+
+func Test_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "roles")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "roles-apikey.yaml")
+	writeRoles := func(content string, modTime time.Time) {
+		require.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+	apikeyRoles := func(role string) string {
+		return "header: X-DC-AUTH\nkeys:\n  " + certutil.SHA256Hex([]byte("K1")) + ":\n    name: Denis\n    role: " + role + "\n"
+	}
+	corrupted, err := ioutil.ReadFile("apikeymapper/testdata/roles_corrupted.1.yaml")
+	require.NoError(t, err)
+
+	now := time.Now()
+	writeRoles(apikeyRoles("admin"), now)
+
+	p, err := roles.New("", file, "", nil)
+	require.NoError(t, err)
+	defer p.Close()
+
+	role := func() string {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(apikeymapper.APIKeyHeader, "K1")
+		id, err := p.IdentityMapper(r)
+		require.NoError(t, err)
+		return id.Role()
+	}
+	assert.Equal(t, "admin", role())
+
+	writeRoles(apikeyRoles("client"), now.Add(time.Second))
+	require.NoError(t, p.Reload())
+	assert.Equal(t, "client", role())
+
+	// the last good configuration is kept
+	writeRoles(string(corrupted), now.Add(2*time.Second))
+	err = p.Reload()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to reload API-Key mapper")
+	assert.Equal(t, "client", role())
+
+	t.Run("watch", func(t *testing.T) {
+		p.Watch(10 * time.Millisecond)
+
+		writeRoles(apikeyRoles("owner"), now.Add(3*time.Second))
+		for i := 0; i < 200 && role() != "owner"; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, "owner", role())
+
+		writeRoles(string(corrupted), now.Add(4*time.Second))
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, "owner", role())
+
+		require.NoError(t, p.Close())
+		writeRoles(apikeyRoles("admin"), now.Add(5*time.Second))
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, "owner", role())
+	})
+}
+
+func Test_SetFiles(t *testing.T) {
+	p, err := roles.New("", "", "", nil)
+	require.NoError(t, err)
+	defer p.Close()
+	assert.Nil(t, p.APIKeyMapper())
+	assert.Nil(t, p.CertMapper())
+
+	require.NoError(t, p.SetFiles("", "apikeymapper/testdata/roles.yaml", "certmapper/testdata/roles.yaml"))
+	assert.NotNil(t, p.APIKeyMapper())
+	assert.NotNil(t, p.CertMapper())
+	assert.Nil(t, p.JwtMapper())
+
+	// the mappers are not changed on failure
+	err = p.SetFiles("", "missing_roles.yaml", "")
+	require.Error(t, err)
+	assert.Equal(t, "failed to load API-Key mapper: open missing_roles.yaml: no such file or directory", err.Error())
+	assert.NotNil(t, p.APIKeyMapper())
+	assert.NotNil(t, p.CertMapper())
+
+	require.NoError(t, p.SetFiles("", "apikeymapper/testdata/roles.yaml", ""))
+	assert.NotNil(t, p.APIKeyMapper())
+	assert.Nil(t, p.CertMapper())
+
+	require.NoError(t, p.SetFiles("", "", ""))
+	assert.Nil(t, p.APIKeyMapper())
+	require.NoError(t, p.Reload())
+
+	// no mapper is changed, if any of the files fails to load
+	cm := p.CertMapper()
+	err = p.SetFiles("missing_roles.yaml", "", "certmapper/testdata/roles.yaml")
+	require.Error(t, err)
+	assert.Equal(t, "failed to load JWT mapper: open missing_roles.yaml: no such file or directory", err.Error())
+	assert.True(t, cm == p.CertMapper())
+	assert.Nil(t, p.JwtMapper())
+
+	// JWT mapper is not removed at runtime
+	require.NoError(t, p.SetFiles("jwtmapper/testdata/roles.yaml", "", ""))
+	jm := p.JwtMapper()
+	require.NotNil(t, jm)
+	err = p.SetFiles("", "", "")
+	require.Error(t, err)
+	assert.Equal(t, "JWT mapper can not be removed without restart", err.Error())
+	assert.True(t, jm == p.JwtMapper())
+}
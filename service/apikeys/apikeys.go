@@ -0,0 +1,207 @@
+package apikeys
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/go-phorce/dolly-test/pkg/audit"
+	"github.com/go-phorce/dolly-test/pkg/roles/apikeymapper"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/juju/errors"
+)
+
+// ServiceName provides the Service Name for this package
+const ServiceName = "apikeys"
+
+// Audit events of the service
+const (
+	EvtAPIKeyCreated = "api key created"
+	EvtAPIKeyRevoked = "api key revoked"
+	EvtAPIKeyRotated = "api key rotated"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/service", "apikeys")
+
+// Service defines the API keys management service
+type Service struct {
+	server rest.Server
+	db     datahub.APIKeysManager
+}
+
+// Factory returns a factory of the service
+func Factory(server rest.Server) interface{} {
+	if server == nil {
+		logger.Panic("apikeys.Factory: invalid parameter")
+	}
+
+	return func(db datahub.Datahub) {
+		svc := &Service{
+			server: server,
+			db:     db,
+		}
+
+		server.AddService(svc)
+	}
+}
+
+// Name returns the service name
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// IsReady indicates that the service is ready to serve its end-points
+func (s *Service) IsReady() bool {
+	return true
+}
+
+// Close cleans up background processes of subservices
+func (s *Service) Close() {
+}
+
+// audit records the event of the request
+func (s *Service) audit(r *http.Request, eventType string, key *v1.APIKey) {
+	audit.ForRequest(s.server, r, ServiceName, eventType,
+		fmt.Sprintf("id=%s, prefix=%s, owner=%q, role=%s", key.ID, key.Prefix, key.Owner, key.Role))
+}
+
+// Register adds the service endpoints to the overall URL router
+func (s *Service) Register(r rest.Router) {
+	r.GET(v1.URIForAPIKeys, listHandler(s))
+	r.POST(v1.URIForAPIKeys, createHandler(s))
+	r.GET(v1.URIForAPIKeyByID, getHandler(s))
+	r.DELETE(v1.URIForAPIKeyByID, revokeHandler(s))
+	r.POST(v1.URIForAPIKeyRotate, rotateHandler(s))
+}
+
+func listHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		list, err := s.db.ListAPIKeys(r.Context(), r.URL.Query().Get("owner"))
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to list API keys"))
+			return
+		}
+
+		res := &v1.ListAPIKeysResponse{
+			APIKeys: list,
+		}
+		marshal.WritePlainJSON(w, http.StatusOK, res, marshal.PrettyPrint)
+	}
+}
+
+func createHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		req := new(v1.CreateAPIKeyRequest)
+		if err := marshal.DecodeBody(w, r, req); err != nil {
+			return
+		}
+		if err := req.Validate(); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		secret, prefix, hash, err := apikeymapper.GenerateKey()
+		if err != nil {
+			marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to generate API key").WithCause(err))
+			return
+		}
+
+		key := &v1.APIKey{
+			Prefix:    prefix,
+			Owner:     req.Owner,
+			Role:      req.Role,
+			Scopes:    req.Scopes,
+			CreatedAt: time.Now().UTC(),
+		}
+		if req.ExpiresAt != nil {
+			expiresAt := req.ExpiresAt.UTC()
+			key.ExpiresAt = &expiresAt
+		}
+
+		key, err = s.db.CreateAPIKey(r.Context(), key, hash)
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to create API key"))
+			return
+		}
+
+		s.audit(r, EvtAPIKeyCreated, key)
+
+		res := &v1.APIKeyResponse{
+			APIKey: key,
+			Key:    secret,
+		}
+		marshal.WritePlainJSON(w, http.StatusCreated, res, marshal.PrettyPrint)
+	}
+}
+
+func getHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		key, err := s.db.GetAPIKey(r.Context(), p.ByName("key_id"))
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to get API key"))
+			return
+		}
+
+		res := &v1.APIKeyResponse{
+			APIKey: key,
+		}
+		marshal.WritePlainJSON(w, http.StatusOK, res, marshal.PrettyPrint)
+	}
+}
+
+func revokeHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		key, err := s.db.RevokeAPIKey(r.Context(), p.ByName("key_id"), time.Now().UTC())
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to revoke API key"))
+			return
+		}
+
+		s.audit(r, EvtAPIKeyRevoked, key)
+
+		res := &v1.APIKeyResponse{
+			APIKey: key,
+		}
+		marshal.WritePlainJSON(w, http.StatusOK, res, marshal.PrettyPrint)
+	}
+}
+
+func rotateHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		secret, prefix, hash, err := apikeymapper.GenerateKey()
+		if err != nil {
+			marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to generate API key").WithCause(err))
+			return
+		}
+
+		key, err := s.db.RotateAPIKey(r.Context(), p.ByName("key_id"), prefix, hash)
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to rotate API key"))
+			return
+		}
+
+		s.audit(r, EvtAPIKeyRotated, key)
+
+		res := &v1.APIKeyResponse{
+			APIKey: key,
+			Key:    secret,
+		}
+		marshal.WritePlainJSON(w, http.StatusOK, res, marshal.PrettyPrint)
+	}
+}
+
+func datahubError(err error, msg string) *httperror.Error {
+	switch {
+	case errors.IsNotFound(err):
+		return httperror.WithNotFound("%s: %s", msg, err.Error())
+	case errors.IsAlreadyExists(err), errors.IsNotValid(err):
+		return httperror.WithInvalidRequest("%s: %s", msg, err.Error())
+	default:
+		return httperror.WithUnexpected("%s", msg).WithCause(err)
+	}
+}
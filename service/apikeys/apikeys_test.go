@@ -0,0 +1,144 @@
+package apikeys
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub/inmemory"
+	"github.com/go-phorce/dolly-test/pkg/roles/apikeymapper"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newService(t *testing.T) *Service {
+	db, err := inmemory.New()
+	require.NoError(t, err)
+	return &Service{db: db}
+}
+
+func call(h rest.Handle, method, uri string, body interface{}, p rest.Params) *httptest.ResponseRecorder {
+	var b []byte
+	if body != nil {
+		b, _ = json.Marshal(body)
+	}
+	r := httptest.NewRequest(method, uri, bytes.NewReader(b))
+	r = identity.WithTestIdentity(r, identity.NewIdentity("dolly-admin", "denis@ekspand.com", ""))
+	w := httptest.NewRecorder()
+	h(w, r, p)
+	return w
+}
+
+func keyParams(id string) rest.Params {
+	return rest.Params{{Key: "key_id", Value: id}}
+}
+
+func Test_APIKeys(t *testing.T) {
+	s := newService(t)
+	p := apikeymapper.New(&apikeymapper.Config{})
+	p.SetKeyStore(s.db)
+
+	identityFor := func(secret string) (string, error) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/teams", nil)
+		r.Header.Set(apikeymapper.APIKeyHeader, secret)
+		id, err := p.IdentityMapper(r)
+		if err != nil {
+			return "", err
+		}
+		return id.String(), nil
+	}
+
+	w := call(createHandler(s), http.MethodPost, v1.URIForAPIKeys, &v1.CreateAPIKeyRequest{Owner: "ci-bot"}, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = call(createHandler(s), http.MethodPost, v1.URIForAPIKeys, &v1.CreateAPIKeyRequest{
+		Owner:  "ci-bot",
+		Role:   "dolly-client",
+		Scopes: []string{"/v1/teams"},
+	}, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var created v1.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.NotNil(t, created.APIKey)
+	require.NotEmpty(t, created.Key)
+	assert.Equal(t, created.APIKey.Prefix, created.Key[:len(created.APIKey.Prefix)])
+	id := created.APIKey.ID
+
+	name, err := identityFor(created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "dolly-client/ci-bot", name)
+
+	t.Run("get", func(t *testing.T) {
+		w := call(getHandler(s), http.MethodGet, v1.URIForAPIKeys+"/"+id, nil, keyParams(id))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res v1.APIKeyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Empty(t, res.Key, "the secret is returned only once")
+		assert.Equal(t, id, res.APIKey.ID)
+
+		w = call(getHandler(s), http.MethodGet, v1.URIForAPIKeys+"/missing", nil, keyParams("missing"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		w := call(listHandler(s), http.MethodGet, v1.URIForAPIKeys+"?owner=ci-bot", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res v1.ListAPIKeysResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		require.Len(t, res.APIKeys, 1)
+		assert.Equal(t, id, res.APIKeys[0].ID)
+
+		w = call(listHandler(s), http.MethodGet, v1.URIForAPIKeys+"?owner=other", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Empty(t, res.APIKeys)
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		w := call(rotateHandler(s), http.MethodPost, v1.URIForAPIKeys+"/"+id+"/rotate", nil, keyParams(id))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res v1.APIKeyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		require.NotEmpty(t, res.Key)
+		assert.NotEqual(t, created.Key, res.Key)
+		assert.Equal(t, id, res.APIKey.ID)
+
+		_, err := identityFor(created.Key)
+		require.Error(t, err)
+		assert.Equal(t, "invalid access key", err.Error())
+
+		name, err := identityFor(res.Key)
+		require.NoError(t, err)
+		assert.Equal(t, "dolly-client/ci-bot", name)
+		created = res
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		w := call(revokeHandler(s), http.MethodDelete, v1.URIForAPIKeys+"/"+id, nil, keyParams(id))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res v1.APIKeyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.NotNil(t, res.APIKey.RevokedAt)
+
+		_, err := identityFor(created.Key)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is revoked")
+
+		// revoked key can not be rotated
+		w = call(rotateHandler(s), http.MethodPost, v1.URIForAPIKeys+"/"+id+"/rotate", nil, keyParams(id))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = call(revokeHandler(s), http.MethodDelete, v1.URIForAPIKeys+"/missing", nil, keyParams("missing"))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,314 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/config"
+	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/go-phorce/dolly-test/pkg/audit"
+	"github.com/go-phorce/dolly-test/pkg/roles"
+	"github.com/go-phorce/dolly-test/pkg/roles/jwtmapper"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/tasks"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/juju/errors"
+)
+
+// ServiceName provides the Service Name for this package
+const ServiceName = "auth"
+
+// DefaultTokenExpiry specifies the lifetime of issued tokens,
+// if not specified in the configuration
+const DefaultTokenExpiry = time.Hour
+
+// PurgeRevocationsInterval specifies how often expired revocations are purged
+const PurgeRevocationsInterval = time.Hour
+
+// Audit events of the service
+const (
+	EvtTokenIssued   = "token issued"
+	EvtLogout        = "logout"
+	EvtTokensRevoked = "tokens revoked"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/service", "auth")
+
+// Service defines the Auth service
+type Service struct {
+	server rest.Server
+	db     datahub.Datahub
+	// jwt returns the current JWT mapper, which is swapped on reload
+	jwt    func() *jwtmapper.Provider
+	expiry time.Duration
+}
+
+// Factory returns a factory of the service
+func Factory(server rest.Server) interface{} {
+	if server == nil {
+		logger.Panic("auth.Factory: invalid parameter")
+	}
+
+	return func(cfg *config.Configuration, rp *roles.Provider, db datahub.Datahub) error {
+		if rp.JwtMapper() == nil {
+			return errors.New("auth service requires Authz.JWTMapper configuration")
+		}
+
+		svc := &Service{
+			server: server,
+			db:     db,
+			jwt:    rp.JwtMapper,
+			expiry: cfg.Authz.GetJWTExpiry().TimeDuration(),
+		}
+		if svc.expiry == 0 {
+			svc.expiry = DefaultTokenExpiry
+		}
+
+		task := tasks.NewTaskAtIntervals(uint64(PurgeRevocationsInterval/time.Minute), tasks.Minutes).
+			Do("purge_revocations", purgeRevocationsTask, svc)
+		server.Scheduler().Add(task)
+
+		server.AddService(svc)
+		return nil
+	}
+}
+
+// Name returns the service name
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// IsReady indicates that the service is ready to serve its end-points
+func (s *Service) IsReady() bool {
+	return true
+}
+
+// Close cleans up background processes of subservices
+func (s *Service) Close() {
+}
+
+// Register adds the service endpoints to the overall URL router
+func (s *Service) Register(r rest.Router) {
+	r.POST(v1.URIForAuthToken, tokenHandler(s))
+	r.POST(v1.URIForAuthTokenRefresh, refreshHandler(s))
+	r.GET(v1.URIForAuthJWKS, jwksHandler(s))
+	r.POST(v1.URIForAuthLogout, logoutHandler(s))
+	r.POST(v1.URIForAuthRevoke, revokeHandler(s))
+	r.GET(v1.URIForAuthRevocations, revocationsHandler(s))
+}
+
+// audit records the event of the request
+func (s *Service) audit(r *http.Request, eventType, format string, args ...interface{}) {
+	audit.ForRequest(s.server, r, ServiceName, eventType, fmt.Sprintf(format, args...))
+}
+
+func tokenHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		idn := identity.ForRequest(r).Identity()
+
+		if idn.Role() == identity.GuestRoleName {
+			marshal.WriteJSON(w, r, httperror.WithUnauthorized("client certificate or API key is required"))
+			return
+		}
+		if _, ok := idn.UserInfo().(*v1.UserInfo); ok {
+			marshal.WriteJSON(w, r, httperror.WithForbidden("use %s to refresh the token", v1.URIForAuthTokenRefresh))
+			return
+		}
+		// the token is not limited to the scopes of API key
+		if key, ok := idn.UserInfo().(*v1.APIKey); ok && len(key.Scopes) > 0 {
+			marshal.WriteJSON(w, r, httperror.WithForbidden("scoped API key can not be exchanged for a token"))
+			return
+		}
+
+		name := idn.UserID()
+		if name == "" {
+			name = idn.Name()
+		}
+
+		user, err := s.db.GetUser(r.Context(), name)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				marshal.WriteJSON(w, r, httperror.WithForbidden("user %q is not registered", name))
+			} else {
+				marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to find user").WithCause(err))
+			}
+			return
+		}
+
+		if err = s.checkRole(user, idn.Role()); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		user, err = s.db.RecordLogin(r.Context(), user.ID)
+		if err != nil {
+			marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to record login").WithCause(err))
+			return
+		}
+
+		s.writeToken(w, r, user)
+	}
+}
+
+func refreshHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		idn := identity.ForRequest(r).Identity()
+
+		info, ok := idn.UserInfo().(*v1.UserInfo)
+		if !ok || info == nil {
+			marshal.WriteJSON(w, r, httperror.WithUnauthorized("unexpired token is required"))
+			return
+		}
+
+		// the profile is reloaded, in case the user was updated or deleted
+		user, err := s.db.GetUser(r.Context(), info.Email)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				marshal.WriteJSON(w, r, httperror.WithForbidden("user %q is not registered", info.Email))
+			} else {
+				marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to find user").WithCause(err))
+			}
+			return
+		}
+		if err = s.checkRole(user, idn.Role()); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		s.writeToken(w, r, user)
+	}
+}
+
+func jwksHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		marshal.WritePlainJSON(w, http.StatusOK, s.jwt().JWKS(), marshal.PrettyPrint)
+	}
+}
+
+func logoutHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		token, err := s.jwt().VerifyToken(r)
+		if err != nil {
+			marshal.WriteJSON(w, r, httperror.WithUnauthorized("unexpired token is required"))
+			return
+		}
+
+		// the revocation is kept until the token expires
+		_, err = s.db.RevokeTokens(r.Context(), &v1.Revocation{
+			TokenID:   token.ID,
+			Reason:    "logout",
+			RevokedAt: time.Now().UTC(),
+			ExpiresAt: token.ExpiresAt,
+		})
+		if err != nil {
+			marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to revoke token").WithCause(err))
+			return
+		}
+
+		s.audit(r, EvtLogout, "user=%q, token=%s", token.User, token.ID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func revokeHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		req := new(v1.RevokeTokensRequest)
+		if err := marshal.DecodeBody(w, r, req); err != nil {
+			return
+		}
+		if err := req.Validate(); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		// the tokens issued before the revocation expire within the longest
+		// lifetime of the tokens issued by this server or OIDC issuer
+		expiry := s.expiry
+		if oidc := s.jwt().OIDCMaxTokenLifetime(); oidc > expiry {
+			expiry = oidc
+		}
+		now := time.Now().UTC()
+		rev, err := s.db.RevokeTokens(r.Context(), &v1.Revocation{
+			TokenID:   req.TokenID,
+			DeviceID:  req.DeviceID,
+			User:      req.User,
+			Reason:    req.Reason,
+			RevokedAt: now,
+			ExpiresAt: now.Add(expiry),
+		})
+		if err != nil {
+			marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to revoke tokens").WithCause(err))
+			return
+		}
+
+		s.audit(r, EvtTokensRevoked, "id=%s, token=%q, device=%q, user=%q, reason=%q",
+			rev.ID, rev.TokenID, rev.DeviceID, rev.User, rev.Reason)
+		marshal.WritePlainJSON(w, http.StatusCreated, rev, marshal.PrettyPrint)
+	}
+}
+
+func revocationsHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		list, err := s.db.ListRevocations(r.Context())
+		if err != nil {
+			marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to list revocations").WithCause(err))
+			return
+		}
+
+		res := &v1.RevocationsResponse{
+			Revocations: list,
+		}
+		marshal.WritePlainJSON(w, http.StatusOK, res, marshal.PrettyPrint)
+	}
+}
+
+func purgeRevocationsTask(s *Service) {
+	count, err := s.db.PurgeRevocations(context.Background(), time.Now().UTC())
+	if err != nil {
+		logger.Errorf("api=purgeRevocationsTask, err=[%v]", errors.ErrorStack(err))
+		return
+	}
+	if count > 0 {
+		logger.Infof("api=purgeRevocationsTask, purged=%d", count)
+	}
+}
+
+// checkRole returns error, if the role of the token issued for the user
+// differs from the caller's role, so the exchange does not escalate privileges
+func (s *Service) checkRole(user *v1.User, role string) error {
+	if tokenRole := s.jwt().UserRole(user.Email); tokenRole != role {
+		return httperror.WithForbidden("role %q of the caller does not match role %q of the token", role, tokenRole)
+	}
+	return nil
+}
+
+func (s *Service) writeToken(w http.ResponseWriter, r *http.Request, user *v1.User) {
+	profile := &v1.UserInfo{
+		ID:     user.ID,
+		Name:   user.Name,
+		Email:  user.Email,
+		Active: true,
+	}
+
+	auth, err := s.jwt().SignToken(profile, r.Header.Get(header.XDeviceID), s.expiry)
+	if err != nil {
+		marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to sign token").WithCause(err))
+		return
+	}
+
+	s.audit(r, EvtTokenIssued, "user=%q, role=%s, token=%s, device=%q, expires=%s",
+		user.Email, auth.Role, auth.TokenID, auth.DeviceID, auth.ExpiresAt.Format(time.RFC3339))
+
+	res := &v1.AuthTokenRefreshResponse{
+		Authorization: auth,
+		Profile:       profile,
+	}
+	marshal.WritePlainJSON(w, http.StatusOK, res, marshal.PrettyPrint)
+}
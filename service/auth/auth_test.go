@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub/inmemory"
+	"github.com/go-phorce/dolly-test/pkg/roles/jwtmapper"
+	"github.com/go-phorce/dolly/xhttp/header"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newService(t *testing.T) *Service {
+	db, err := inmemory.New()
+	require.NoError(t, err)
+
+	jwt, err := jwtmapper.New(&jwtmapper.Config{
+		Issuer:   "dolly-test",
+		Audience: "dolly-test",
+		KeyID:    "1",
+		Keys:     []*jwtmapper.Key{{ID: "1", Seed: "seed"}},
+		RolesMap: map[string][]string{"dolly-admin": {"denis@ekspand.com"}},
+	}, nil)
+	require.NoError(t, err)
+	jwt.SetRevocationChecker(db)
+
+	return &Service{
+		db:     db,
+		jwt:    func() *jwtmapper.Provider { return jwt },
+		expiry: time.Minute,
+	}
+}
+
+func Test_Token(t *testing.T) {
+	s := newService(t)
+
+	call := func(idn identity.Identity) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, v1.URIForAuthToken, nil)
+		r.Header.Set(header.XDeviceID, "device1")
+		r = identity.WithTestIdentity(r, idn)
+		w := httptest.NewRecorder()
+		tokenHandler(s)(w, r, nil)
+		return w
+	}
+
+	w := call(identity.NewIdentity(identity.GuestRoleName, "10.0.0.1", ""))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = call(identity.NewIdentity("dolly-client", "unknown", ""))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = call(identity.NewIdentityWithUserInfo("dolly-admin", "denis@ekspand.com", "", &v1.UserInfo{Email: "denis@ekspand.com"}))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = call(identity.NewIdentityWithUserInfo("dolly-admin", "denis@ekspand.com", "", &v1.APIKey{Scopes: []string{"/v1/teams"}}))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "scoped API key can not be exchanged for a token")
+
+	// the token role is not escalated above the caller's role
+	w = call(identity.NewIdentity("dolly-client", "denis@ekspand.com", ""))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `role \"dolly-client\" of the caller does not match role \"dolly-admin\" of the token`)
+
+	w = call(identity.NewIdentity("dolly-admin", "denis@ekspand.com", ""))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var res v1.AuthTokenRefreshResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.NotNil(t, res.Authorization)
+	require.NotNil(t, res.Profile)
+	assert.Equal(t, "a001", res.Profile.ID)
+	assert.Equal(t, "dolly-admin", res.Authorization.Role)
+	assert.Equal(t, "device1", res.Authorization.DeviceID)
+	assert.NotEmpty(t, res.Authorization.AccessToken)
+
+	user, err := s.db.GetUser(context.Background(), "a001")
+	require.NoError(t, err)
+	assert.Equal(t, 13, user.LoginCount)
+
+	t.Run("refresh", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, v1.URIForAuthTokenRefresh, nil)
+		r.Header.Set(header.Authorization, header.Bearer+" "+res.Authorization.AccessToken)
+		r.Header.Set(header.XDeviceID, "device1")
+
+		idn, err := s.jwt().IdentityMapper(r)
+		require.NoError(t, err)
+		r = identity.WithTestIdentity(r, idn)
+
+		w := httptest.NewRecorder()
+		refreshHandler(s)(w, r, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res2 v1.AuthTokenRefreshResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res2))
+		assert.Equal(t, res.Profile, res2.Profile)
+		assert.NotEmpty(t, res2.Authorization.AccessToken)
+
+		// refresh is not a login
+		user, err := s.db.GetUser(context.Background(), "a001")
+		require.NoError(t, err)
+		assert.Equal(t, 13, user.LoginCount)
+
+		r = identity.WithTestIdentity(r, identity.NewIdentity("dolly-client", "denis@ekspand.com", ""))
+		w = httptest.NewRecorder()
+		refreshHandler(s)(w, r, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func Test_JWKS(t *testing.T) {
+	s := newService(t)
+
+	r := httptest.NewRequest(http.MethodGet, v1.URIForAuthJWKS, nil)
+	w := httptest.NewRecorder()
+	jwksHandler(s)(w, r, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// HS256 keys are not published
+	var res v1.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.NotNil(t, res.Keys)
+	assert.Empty(t, res.Keys)
+}
+
+func Test_Revocation(t *testing.T) {
+	s := newService(t)
+
+	issue := func(deviceID string) string {
+		r := httptest.NewRequest(http.MethodPost, v1.URIForAuthToken, nil)
+		r.Header.Set(header.XDeviceID, deviceID)
+		r = identity.WithTestIdentity(r, identity.NewIdentity("dolly-admin", "denis@ekspand.com", ""))
+		w := httptest.NewRecorder()
+		tokenHandler(s)(w, r, nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var res v1.AuthTokenRefreshResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.NotEmpty(t, res.Authorization.TokenID)
+		return res.Authorization.AccessToken
+	}
+	request := func(method, uri, token, deviceID string) *http.Request {
+		r := httptest.NewRequest(method, uri, nil)
+		r.Header.Set(header.Authorization, header.Bearer+" "+token)
+		r.Header.Set(header.XDeviceID, deviceID)
+		return r
+	}
+	revoke := func(req *v1.RevokeTokensRequest) *httptest.ResponseRecorder {
+		js, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, v1.URIForAuthRevoke, bytes.NewReader(js))
+		r = identity.WithTestIdentity(r, identity.NewIdentity("dolly-admin", "admin", ""))
+		w := httptest.NewRecorder()
+		revokeHandler(s)(w, r, nil)
+		return w
+	}
+
+	t.Run("logout", func(t *testing.T) {
+		token := issue("device1")
+		other := issue("device1")
+
+		w := httptest.NewRecorder()
+		logoutHandler(s)(w, request(http.MethodPost, v1.URIForAuthLogout, token, "device1"), nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		_, err := s.jwt().IdentityMapper(request(http.MethodGet, "/", token, "device1"))
+		require.Error(t, err)
+		assert.Equal(t, "token is revoked", err.Error())
+
+		// only the caller's token is revoked
+		_, err = s.jwt().IdentityMapper(request(http.MethodGet, "/", other, "device1"))
+		require.NoError(t, err)
+
+		w = httptest.NewRecorder()
+		logoutHandler(s)(w, request(http.MethodPost, v1.URIForAuthLogout, token, "device1"), nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("device", func(t *testing.T) {
+		token := issue("device2")
+		other := issue("device3")
+
+		w := revoke(&v1.RevokeTokensRequest{})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = revoke(&v1.RevokeTokensRequest{DeviceID: "device2", Reason: "lost"})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var rev v1.Revocation
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rev))
+		assert.NotEmpty(t, rev.ID)
+		assert.Equal(t, "device2", rev.DeviceID)
+		assert.Equal(t, rev.RevokedAt.Add(s.expiry), rev.ExpiresAt)
+
+		_, err := s.jwt().IdentityMapper(request(http.MethodGet, "/", token, "device2"))
+		require.Error(t, err)
+		assert.Equal(t, "token is revoked", err.Error())
+
+		_, err = s.jwt().IdentityMapper(request(http.MethodGet, "/", other, "device3"))
+		require.NoError(t, err)
+	})
+
+	t.Run("user", func(t *testing.T) {
+		token := issue("device4")
+
+		w := revoke(&v1.RevokeTokensRequest{User: "denis@ekspand.com"})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		_, err := s.jwt().IdentityMapper(request(http.MethodGet, "/", token, "device4"))
+		require.Error(t, err)
+		assert.Equal(t, "token is revoked", err.Error())
+
+		// refresh is not allowed with revoked token
+		_, err = s.jwt().VerifyToken(request(http.MethodPost, v1.URIForAuthTokenRefresh, token, "device4"))
+		require.Error(t, err)
+	})
+
+	t.Run("list and purge", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, v1.URIForAuthRevocations, nil)
+		w := httptest.NewRecorder()
+		revocationsHandler(s)(w, r, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var res v1.RevocationsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Len(t, res.Revocations, 3)
+
+		// nothing is expired yet
+		purgeRevocationsTask(s)
+		list, err := s.db.ListRevocations(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, list, 3)
+
+		count, err := s.db.PurgeRevocations(context.Background(), time.Now().Add(s.expiry+time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	})
+
+	t.Run("oidc", func(t *testing.T) {
+		jwt, err := jwtmapper.New(&jwtmapper.Config{
+			Issuer:   "dolly-test",
+			Audience: "dolly-test",
+			KeyID:    "1",
+			Keys:     []*jwtmapper.Key{{ID: "1", Seed: "seed"}},
+			OIDC: &jwtmapper.OIDCConfig{
+				Issuer:   "https://login.example.com",
+				Audience: "dolly",
+			},
+		}, nil)
+		require.NoError(t, err)
+		s := &Service{
+			db:     s.db,
+			jwt:    func() *jwtmapper.Provider { return jwt },
+			expiry: s.expiry,
+		}
+
+		// the revocation is kept until OIDC tokens expire
+		w := httptest.NewRecorder()
+		js, _ := json.Marshal(&v1.RevokeTokensRequest{User: "oidc@ekspand.com"})
+		r := httptest.NewRequest(http.MethodPost, v1.URIForAuthRevoke, bytes.NewReader(js))
+		r = identity.WithTestIdentity(r, identity.NewIdentity("dolly-admin", "admin", ""))
+		revokeHandler(s)(w, r, nil)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var rev v1.Revocation
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rev))
+		assert.Equal(t, rev.RevokedAt.Add(jwtmapper.DefaultMaxTokenLifetime), rev.ExpiresAt)
+
+		count, err := s.db.PurgeRevocations(context.Background(), time.Now().Add(s.expiry+time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
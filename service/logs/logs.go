@@ -0,0 +1,315 @@
+package logs
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/config"
+	"github.com/go-phorce/dolly-test/pkg/audit"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp/httperror"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/juju/errors"
+)
+
+// ServiceName provides the Service Name for this package
+const ServiceName = "logs"
+
+// DefaultTTL specifies how long the changed log level is kept, if TTL is not specified
+const DefaultTTL = 15 * time.Minute
+
+// Audit events of the service
+const (
+	EvtLogLevelChanged = "log level changed"
+)
+
+// DefaultRepos specifies the repos of the packages used by the server,
+// xlog does not provide the list of registered repos
+var DefaultRepos = []string{
+	"github.com/go-phorce/dolly-test/cmd/dolly-test",
+	"github.com/go-phorce/dolly-test/datahub",
+	"github.com/go-phorce/dolly-test/pkg",
+	"github.com/go-phorce/dolly-test/service",
+	"github.com/certcentral/enrollme/pkg",
+	"github.com/go-phorce/dolly",
+	"github.com/go-phorce/dolly/xpki",
+}
+
+// levels in order from the most verbose
+var levels = []xlog.LogLevel{
+	xlog.DEBUG,
+	xlog.TRACE,
+	xlog.INFO,
+	xlog.NOTICE,
+	xlog.WARNING,
+	xlog.ERROR,
+	xlog.CRITICAL,
+}
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/service", "logs")
+
+// Service defines the log levels service
+type Service struct {
+	server rest.Server
+	repos  []string
+
+	lock sync.Mutex
+	// changed specifies the levels changed at runtime, by repo/package
+	changed map[string]*changedLevel
+}
+
+// changedLevel specifies the level to revert to
+type changedLevel struct {
+	repo     string
+	pkg      string
+	original xlog.LogLevel
+	revertAt time.Time
+	timer    *time.Timer
+}
+
+// Factory returns a factory of the service
+func Factory(server rest.Server) interface{} {
+	if server == nil {
+		logger.Panic("logs.Factory: invalid parameter")
+	}
+
+	return func(cfg *config.Configuration) {
+		svc := newService(server, cfg.LogLevels)
+		server.AddService(svc)
+	}
+}
+
+func newService(server rest.Server, configured []config.RepoLogLevel) *Service {
+	repos := append([]string{}, DefaultRepos...)
+	for _, ll := range configured {
+		if ll.Repo != "*" && !contains(repos, ll.Repo) {
+			repos = append(repos, ll.Repo)
+		}
+	}
+	sort.Strings(repos)
+
+	return &Service{
+		server:  server,
+		repos:   repos,
+		changed: map[string]*changedLevel{},
+	}
+}
+
+// Name returns the service name
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// IsReady indicates that the service is ready to serve its end-points
+func (s *Service) IsReady() bool {
+	return true
+}
+
+// Close cleans up background processes of subservices
+func (s *Service) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, c := range s.changed {
+		c.timer.Stop()
+		delete(s.changed, key)
+	}
+}
+
+// Reset drops the levels changed at runtime without reverting them,
+// when the configured levels are applied again on reload,
+// so the pending reverts do not undo the configuration change
+func (s *Service) Reset(configured []config.RepoLogLevel) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, c := range s.changed {
+		c.timer.Stop()
+		delete(s.changed, key)
+	}
+	for _, ll := range configured {
+		if ll.Repo != "*" && !contains(s.repos, ll.Repo) {
+			s.repos = append(s.repos, ll.Repo)
+		}
+	}
+	sort.Strings(s.repos)
+}
+
+// Register adds the service endpoints to the overall URL router
+func (s *Service) Register(r rest.Router) {
+	r.GET(v1.URIForLogLevels, listHandler(s))
+	r.PUT(v1.URIForLogLevels, setHandler(s))
+}
+
+// list returns the current log levels of the packages
+func (s *Service) list() []*v1.LogLevel {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var list []*v1.LogLevel
+	for _, repo := range s.repos {
+		r, err := xlog.GetRepoLogger(repo)
+		if err != nil {
+			continue
+		}
+		pkgs := make([]string, 0, len(r))
+		for pkg := range r {
+			pkgs = append(pkgs, pkg)
+		}
+		sort.Strings(pkgs)
+
+		for _, pkg := range pkgs {
+			ll := &v1.LogLevel{
+				Repo:    repo,
+				Package: pkg,
+				Level:   levelOf(r[pkg]).String(),
+			}
+			if c := s.changed[repo+"/"+pkg]; c != nil {
+				revertAt := c.revertAt
+				ll.RevertAt = &revertAt
+			}
+			list = append(list, ll)
+		}
+	}
+	return list
+}
+
+// set changes the level of the package, or all packages of the repo,
+// and schedules the revert after TTL.
+// The repo * changes the level of all packages in known repos.
+func (s *Service) set(repo, pkg string, level xlog.LogLevel, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	repos := []string{repo}
+	if repo == "*" {
+		repos = s.repos
+		pkg = "*"
+	}
+
+	revertAt := time.Now().Add(ttl).UTC()
+	count := 0
+	for _, repo := range repos {
+		r, err := xlog.GetRepoLogger(repo)
+		if err != nil {
+			if len(repos) == 1 {
+				return errors.NotFoundf("repo %q", repo)
+			}
+			continue
+		}
+		if !contains(s.repos, repo) {
+			s.repos = append(s.repos, repo)
+			sort.Strings(s.repos)
+		}
+
+		for name, p := range r {
+			if pkg != "" && pkg != "*" && pkg != name {
+				continue
+			}
+			s.change(repo, name, levelOf(p), revertAt, ttl)
+			xlog.SetPackageLogLevel(repo, name, level)
+			count++
+		}
+	}
+	if count == 0 {
+		return errors.NotFoundf("package %q in repo %q", pkg, repo)
+	}
+	return nil
+}
+
+// change records the original level of the package,
+// the original level is kept if the level is changed again before the revert
+func (s *Service) change(repo, pkg string, current xlog.LogLevel, revertAt time.Time, ttl time.Duration) {
+	key := repo + "/" + pkg
+	c := s.changed[key]
+	if c != nil {
+		c.timer.Stop()
+	} else {
+		c = &changedLevel{repo: repo, pkg: pkg, original: current}
+		s.changed[key] = c
+	}
+	c.revertAt = revertAt
+	c.timer = time.AfterFunc(ttl, func() {
+		s.revert(key, c)
+	})
+}
+
+// revert sets the original level of the package
+func (s *Service) revert(key string, c *changedLevel) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the level is changed again, and the timer is stopped too late
+	if s.changed[key] != c || time.Now().Before(c.revertAt) {
+		return
+	}
+	delete(s.changed, key)
+
+	xlog.SetPackageLogLevel(c.repo, c.pkg, c.original)
+	logger.Noticef("api=revert, repo=%q, package=%q, level=%v", c.repo, c.pkg, c.original)
+}
+
+func listHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		res := &v1.LogLevelsResponse{
+			Levels: s.list(),
+		}
+		marshal.WritePlainJSON(w, http.StatusOK, res, marshal.PrettyPrint)
+	}
+}
+
+func setHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		req := new(v1.SetLogLevelRequest)
+		if err := marshal.DecodeBody(w, r, req); err != nil {
+			return
+		}
+		if err := req.Validate(); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		level, _ := xlog.ParseLevel(req.Level)
+		ttl := DefaultTTL
+		if req.TTL != "" {
+			ttl, _ = time.ParseDuration(req.TTL)
+		}
+
+		if err := s.set(req.Repo, req.Package, level, ttl); err != nil {
+			marshal.WriteJSON(w, r, httperror.WithNotFound("%s", err.Error()))
+			return
+		}
+
+		audit.ForRequest(s.server, r, ServiceName, EvtLogLevelChanged,
+			fmt.Sprintf("repo=%q, package=%q, level=%s, ttl=%v", req.Repo, req.Package, req.Level, ttl))
+
+		res := &v1.LogLevelsResponse{
+			Levels: s.list(),
+		}
+		marshal.WritePlainJSON(w, http.StatusOK, res, marshal.PrettyPrint)
+	}
+}
+
+// levelOf returns the current level of the package logger
+func levelOf(p *xlog.PackageLogger) xlog.LogLevel {
+	for _, l := range levels {
+		if p.LevelAt(l) {
+			return l
+		}
+	}
+	return xlog.CRITICAL
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
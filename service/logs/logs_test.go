@@ -0,0 +1,129 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/config"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp/identity"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRepo = "github.com/go-phorce/dolly-test/service/logs_test"
+
+var (
+	pkg1 = xlog.NewPackageLogger(testRepo, "pkg1")
+	pkg2 = xlog.NewPackageLogger(testRepo, "pkg2")
+)
+
+func call(h rest.Handle, method string, body interface{}) (*httptest.ResponseRecorder, *v1.LogLevelsResponse) {
+	var b []byte
+	if body != nil {
+		b, _ = json.Marshal(body)
+	}
+	r := httptest.NewRequest(method, v1.URIForLogLevels, bytes.NewReader(b))
+	r = identity.WithTestIdentity(r, identity.NewIdentity("dolly-admin", "denis@ekspand.com", ""))
+	w := httptest.NewRecorder()
+	h(w, r, nil)
+
+	res := new(v1.LogLevelsResponse)
+	json.Unmarshal(w.Body.Bytes(), res)
+	return w, res
+}
+
+func levelsOf(res *v1.LogLevelsResponse) map[string]*v1.LogLevel {
+	m := map[string]*v1.LogLevel{}
+	for _, ll := range res.Levels {
+		if ll.Repo == testRepo {
+			m[ll.Package] = ll
+		}
+	}
+	return m
+}
+
+func Test_LogLevels(t *testing.T) {
+	xlog.SetRepoLogLevel(testRepo, xlog.INFO)
+	defer xlog.SetRepoLogLevel(testRepo, xlog.INFO)
+
+	s := newService(nil, []config.RepoLogLevel{
+		{Repo: "*", Level: "INFO"},
+		{Repo: testRepo, Level: "INFO"},
+	})
+	defer s.Close()
+
+	w, res := call(listHandler(s), http.MethodGet, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	levels := levelsOf(res)
+	require.Len(t, levels, 2)
+	assert.Equal(t, "INFO", levels["pkg1"].Level)
+	assert.Nil(t, levels["pkg1"].RevertAt)
+
+	w, _ = call(setHandler(s), http.MethodPut, &v1.SetLogLevelRequest{Repo: testRepo, Level: "VERBOSE"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w, _ = call(setHandler(s), http.MethodPut, &v1.SetLogLevelRequest{Repo: "github.com/missing", Level: "DEBUG"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, _ = call(setHandler(s), http.MethodPut, &v1.SetLogLevelRequest{Repo: testRepo, Package: "missing", Level: "DEBUG"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w, res = call(setHandler(s), http.MethodPut, &v1.SetLogLevelRequest{Repo: testRepo, Package: "pkg1", Level: "DEBUG", TTL: "1h"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	levels = levelsOf(res)
+	assert.Equal(t, "DEBUG", levels["pkg1"].Level)
+	require.NotNil(t, levels["pkg1"].RevertAt)
+	assert.True(t, levels["pkg1"].RevertAt.After(time.Now().Add(59*time.Minute)))
+	assert.Equal(t, "INFO", levels["pkg2"].Level)
+	assert.True(t, pkg1.LevelAt(xlog.DEBUG))
+
+	// the level is changed again, and reverted to the original level
+	w, res = call(setHandler(s), http.MethodPut, &v1.SetLogLevelRequest{Repo: testRepo, Level: "ERROR", TTL: "100ms"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	levels = levelsOf(res)
+	assert.Equal(t, "ERROR", levels["pkg1"].Level)
+	assert.Equal(t, "ERROR", levels["pkg2"].Level)
+	assert.False(t, pkg2.LevelAt(xlog.INFO))
+
+	for i := 0; i < 100 && !pkg1.LevelAt(xlog.INFO); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// wait for both timers
+	time.Sleep(50 * time.Millisecond)
+
+	_, res = call(listHandler(s), http.MethodGet, nil)
+	levels = levelsOf(res)
+	assert.Equal(t, "INFO", levels["pkg1"].Level)
+	assert.Nil(t, levels["pkg1"].RevertAt)
+	assert.Equal(t, "INFO", levels["pkg2"].Level)
+	assert.Nil(t, levels["pkg2"].RevertAt)
+}
+
+func Test_Reset(t *testing.T) {
+	xlog.SetRepoLogLevel(testRepo, xlog.INFO)
+	defer xlog.SetRepoLogLevel(testRepo, xlog.INFO)
+
+	s := newService(nil, nil)
+	defer s.Close()
+
+	w, _ := call(setHandler(s), http.MethodPut, &v1.SetLogLevelRequest{Repo: testRepo, Package: "pkg1", Level: "DEBUG", TTL: "100ms"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// the configuration is reloaded with the new level
+	s.Reset([]config.RepoLogLevel{{Repo: testRepo, Package: "pkg1", Level: "ERROR"}})
+	xlog.SetPackageLogLevel(testRepo, "pkg1", xlog.ERROR)
+
+	time.Sleep(200 * time.Millisecond)
+
+	_, res := call(listHandler(s), http.MethodGet, nil)
+	levels := levelsOf(res)
+	assert.Equal(t, "ERROR", levels["pkg1"].Level, "the pending revert must not undo the configured level")
+	assert.Nil(t, levels["pkg1"].RevertAt)
+}
@@ -0,0 +1,68 @@
+package prometheus
+
+import (
+	"net/http"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/pkg/metrics"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xlog"
+	"github.com/juju/errors"
+)
+
+// ServiceName provides the Service Name for this package
+const ServiceName = "prometheus"
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/service", "prometheus")
+
+// Service defines the Prometheus exposition service
+type Service struct {
+	server rest.Server
+	sink   *metrics.PrometheusSink
+}
+
+// Factory returns a factory of the service
+func Factory(server rest.Server) interface{} {
+	if server == nil {
+		logger.Panic("prometheus.Factory: invalid parameter")
+	}
+
+	return func(p *metrics.Pipeline) error {
+		if p.Prometheus() == nil {
+			return errors.New("prometheus service requires Metrics.Provider to be prometheus")
+		}
+
+		svc := &Service{
+			server: server,
+			sink:   p.Prometheus(),
+		}
+
+		server.AddService(svc)
+		return nil
+	}
+}
+
+// Name returns the service name
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// IsReady indicates that the service is ready to serve its end-points
+func (s *Service) IsReady() bool {
+	return true
+}
+
+// Close cleans up background processes of subservices
+func (s *Service) Close() {
+}
+
+// Register adds the service endpoints to the overall URL router
+func (s *Service) Register(r rest.Router) {
+	r.GET(v1.URIForMetrics, metricsHandler(s))
+}
+
+func metricsHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		s.sink.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,22 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Metrics(t *testing.T) {
+	sink := metrics.NewPrometheusSink()
+	sink.IncrCounter([]string{"heartbeat"}, 1, nil)
+	s := &Service{sink: sink}
+
+	w := httptest.NewRecorder()
+	metricsHandler(s)(w, httptest.NewRequest(http.MethodGet, v1.URIForMetrics, nil), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "# TYPE heartbeat_total counter\nheartbeat_total 1\n", w.Body.String())
+}
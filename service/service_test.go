@@ -3,13 +3,23 @@ package service_test
 import (
 	"testing"
 
+	"github.com/go-phorce/dolly-test/service/apikeys"
+	"github.com/go-phorce/dolly-test/service/auth"
+	"github.com/go-phorce/dolly-test/service/logs"
+	"github.com/go-phorce/dolly-test/service/prometheus"
+	"github.com/go-phorce/dolly-test/service/status"
 	"github.com/go-phorce/dolly-test/service/teams"
 	"github.com/go-phorce/dolly/rest"
 	"github.com/stretchr/testify/require"
 )
 
 var serviceFactories = map[string]func(server rest.Server) interface{}{
-	teams.ServiceName: teams.Factory,
+	teams.ServiceName:      teams.Factory,
+	auth.ServiceName:       auth.Factory,
+	apikeys.ServiceName:    apikeys.Factory,
+	prometheus.ServiceName: prometheus.Factory,
+	status.ServiceName:     status.Factory,
+	logs.ServiceName:       logs.Factory,
 }
 
 func Test_invalidArgs(t *testing.T) {
@@ -0,0 +1,175 @@
+package status
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/go-phorce/dolly-test/pkg/drain"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/go-phorce/dolly/xhttp/marshal"
+	"github.com/go-phorce/dolly/xlog"
+)
+
+// ServiceName provides the Service Name for this package
+const ServiceName = "status"
+
+// ReadinessTimeout specifies the timeout of the datahub check
+const ReadinessTimeout = 2 * time.Second
+
+// Names of the readiness checks
+const (
+	CheckServices = "services"
+	CheckDraining = "draining"
+	CheckDatahub  = "datahub"
+)
+
+var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/service", "status")
+
+// Service defines the Status service
+type Service struct {
+	server  rest.Server
+	db      datahub.Datahub
+	tracker *drain.Tracker
+}
+
+// Factory returns a factory of the service
+func Factory(server rest.Server) interface{} {
+	if server == nil {
+		logger.Panic("status.Factory: invalid parameter")
+	}
+
+	return func(db datahub.Datahub, tracker *drain.Tracker) {
+		svc := &Service{
+			server:  server,
+			db:      db,
+			tracker: tracker,
+		}
+
+		server.AddService(svc)
+	}
+}
+
+// Name returns the service name
+func (s *Service) Name() string {
+	return ServiceName
+}
+
+// IsReady indicates that the service is ready to serve its end-points
+func (s *Service) IsReady() bool {
+	return true
+}
+
+// Close cleans up background processes of subservices
+func (s *Service) Close() {
+}
+
+// Register adds the service status endpoints to the overall URL router
+func (s *Service) Register(r rest.Router) {
+	r.GET(v1.URIForStatus, statusHandler(s))
+	r.GET(v1.URIForStatusServer, serverStatusHandler(s))
+	r.GET(v1.URIForStatusLive, liveHandler(s))
+	r.GET(v1.URIForStatusReady, readyHandler(s))
+}
+
+// status returns the status of the server without services and cluster info
+func (s *Service) status() *v1.ServerStatus {
+	return &v1.ServerStatus{
+		Name:      s.server.Name(),
+		NodeName:  s.server.NodeName(),
+		HostName:  s.server.HostName(),
+		Port:      s.server.Port(),
+		StartedAt: s.server.StartedAt(),
+		Uptime:    s.server.Uptime(),
+		Version:   s.server.Version(),
+	}
+}
+
+// services returns the status of the services configured for the server
+func (s *Service) services() []*v1.ServiceStatus {
+	names := s.server.HTTPConfig().GetServices()
+	list := make([]*v1.ServiceStatus, 0, len(names))
+	for _, name := range names {
+		svc := s.server.Service(name)
+		list = append(list, &v1.ServiceStatus{
+			Name:    name,
+			IsReady: svc != nil && svc.IsReady(),
+		})
+	}
+	return list
+}
+
+func statusHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		marshal.WritePlainJSON(w, http.StatusOK, &v1.StatusResponse{Status: s.status()}, marshal.PrettyPrint)
+	}
+}
+
+func serverStatusHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		status := s.status()
+		status.NodeID = s.server.NodeID()
+		status.LeaderID = s.server.LeaderID()
+		status.Services = s.services()
+
+		// the cluster is not supported by standalone server
+		if members, err := s.server.ClusterMembers(); err == nil {
+			for _, m := range members {
+				status.Peers = append(status.Peers, &v1.ClusterMember{
+					ID:             m.ID,
+					Name:           m.Name,
+					ListenPeerURLs: m.ListenPeerURLs,
+				})
+			}
+		}
+
+		marshal.WritePlainJSON(w, http.StatusOK, &v1.StatusResponse{Status: status}, marshal.PrettyPrint)
+	}
+}
+
+func liveHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		marshal.WritePlainJSON(w, http.StatusOK, &v1.HealthResponse{OK: true}, marshal.PrettyPrint)
+	}
+}
+
+func readyHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		res := &v1.HealthResponse{OK: true}
+
+		services := &v1.HealthCheck{Name: CheckServices, OK: s.server.IsReady()}
+		if !services.OK {
+			services.Error = "services are not ready"
+		}
+		res.Checks = append(res.Checks, services)
+
+		// the server is shutting down, and load balancers must stop routing requests
+		draining := &v1.HealthCheck{Name: CheckDraining, OK: !s.tracker.IsDraining()}
+		if !draining.OK {
+			draining.Error = "server is shutting down"
+		}
+		res.Checks = append(res.Checks, draining)
+
+		ctx, cancel := context.WithTimeout(r.Context(), ReadinessTimeout)
+		defer cancel()
+
+		db := &v1.HealthCheck{Name: CheckDatahub, OK: true}
+		if err := s.db.Ping(ctx); err != nil {
+			logger.Errorf("api=ready, reason=ping, err=[%v]", err)
+			db.OK = false
+			db.Error = "datahub is not reachable"
+		}
+		res.Checks = append(res.Checks, db)
+
+		code := http.StatusOK
+		for _, c := range res.Checks {
+			if !c.OK {
+				res.OK = false
+				code = http.StatusServiceUnavailable
+			}
+		}
+		marshal.WritePlainJSON(w, code, res, marshal.PrettyPrint)
+	}
+}
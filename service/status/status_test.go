@@ -0,0 +1,134 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-phorce/dolly-test/api/v1"
+	"github.com/go-phorce/dolly-test/config"
+	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/go-phorce/dolly-test/datahub/inmemory"
+	"github.com/go-phorce/dolly-test/pkg/drain"
+	"github.com/go-phorce/dolly/rest"
+	"github.com/juju/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unreachable is the datahub, which fails to ping
+type unreachable struct {
+	datahub.Datahub
+}
+
+func (u *unreachable) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+// readyServer overrides readiness of the server, which is not started
+type readyServer struct {
+	rest.Server
+}
+
+func (s *readyServer) IsReady() bool {
+	return true
+}
+
+func newService(t *testing.T, db datahub.Datahub) *Service {
+	server, err := rest.New("1.2.3", "127.0.0.1", &config.HTTPServer{
+		ServiceName: "health",
+		BindAddr:    "127.0.0.1:0",
+		Services:    []string{ServiceName, "teams"},
+	}, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	s := &Service{server: server, db: db, tracker: drain.New()}
+	server.AddService(s)
+	return s
+}
+
+func call(h rest.Handle, uri string, res interface{}) int {
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, uri, nil), nil)
+	json.Unmarshal(w.Body.Bytes(), res)
+	return w.Code
+}
+
+func Test_Status(t *testing.T) {
+	db, err := inmemory.New()
+	require.NoError(t, err)
+	s := newService(t, db)
+
+	var res v1.StatusResponse
+	require.Equal(t, http.StatusOK, call(statusHandler(s), v1.URIForStatus, &res))
+	require.NotNil(t, res.Status)
+	assert.Equal(t, "health", res.Status.Name)
+	assert.Equal(t, "1.2.3", res.Status.Version)
+	assert.NotEmpty(t, res.Status.HostName)
+	assert.Empty(t, res.Status.Services)
+
+	res = v1.StatusResponse{}
+	require.Equal(t, http.StatusOK, call(serverStatusHandler(s), v1.URIForStatusServer, &res))
+	require.NotNil(t, res.Status)
+	assert.Equal(t, "1.2.3", res.Status.Version)
+	assert.Empty(t, res.Status.Peers)
+	assert.Equal(t, []*v1.ServiceStatus{
+		{Name: ServiceName, IsReady: true},
+		{Name: "teams", IsReady: false},
+	}, res.Status.Services)
+}
+
+func Test_Health(t *testing.T) {
+	db, err := inmemory.New()
+	require.NoError(t, err)
+
+	s := newService(t, db)
+
+	var res v1.HealthResponse
+	require.Equal(t, http.StatusOK, call(liveHandler(s), v1.URIForStatusLive, &res))
+	assert.True(t, res.OK)
+
+	// the server is not serving
+	res = v1.HealthResponse{}
+	require.Equal(t, http.StatusServiceUnavailable, call(readyHandler(s), v1.URIForStatusReady, &res))
+	assert.False(t, res.OK)
+	assert.Equal(t, []*v1.HealthCheck{
+		{Name: CheckServices, OK: false, Error: "services are not ready"},
+		{Name: CheckDraining, OK: true},
+		{Name: CheckDatahub, OK: true},
+	}, res.Checks)
+
+	s.server = &readyServer{Server: s.server}
+
+	res = v1.HealthResponse{}
+	require.Equal(t, http.StatusOK, call(readyHandler(s), v1.URIForStatusReady, &res))
+	assert.True(t, res.OK)
+
+	s.db = &unreachable{Datahub: db}
+	res = v1.HealthResponse{}
+	require.Equal(t, http.StatusServiceUnavailable, call(readyHandler(s), v1.URIForStatusReady, &res))
+	assert.False(t, res.OK)
+	assert.Equal(t, []*v1.HealthCheck{
+		{Name: CheckServices, OK: true},
+		{Name: CheckDraining, OK: true},
+		{Name: CheckDatahub, OK: false, Error: "datahub is not reachable"},
+	}, res.Checks)
+
+	s.db = db
+	s.tracker.Start()
+	res = v1.HealthResponse{}
+	require.Equal(t, http.StatusServiceUnavailable, call(readyHandler(s), v1.URIForStatusReady, &res))
+	assert.False(t, res.OK)
+	assert.Equal(t, []*v1.HealthCheck{
+		{Name: CheckServices, OK: true},
+		{Name: CheckDraining, OK: false, Error: "server is shutting down"},
+		{Name: CheckDatahub, OK: true},
+	}, res.Checks)
+
+	// liveness is not affected by draining
+	res = v1.HealthResponse{}
+	require.Equal(t, http.StatusOK, call(liveHandler(s), v1.URIForStatusLive, &res))
+	assert.True(t, res.OK)
+}
@@ -1,21 +1,40 @@
 package teams
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/go-phorce/dolly-test/api/v1"
 	"github.com/go-phorce/dolly-test/datahub"
+	"github.com/go-phorce/dolly-test/pkg/audit"
+	"github.com/go-phorce/dolly-test/pkg/metrics"
 	"github.com/go-phorce/dolly/rest"
 	"github.com/go-phorce/dolly/xhttp/httperror"
 	"github.com/go-phorce/dolly/xhttp/identity"
 	"github.com/go-phorce/dolly/xhttp/marshal"
 	"github.com/go-phorce/dolly/xlog"
+	"github.com/juju/errors"
 )
 
 // ServiceName provides the Service Name for this package
 const ServiceName = "teams"
 
+// Audit events of the service
+const (
+	EvtTeamCreated       = "team created"
+	EvtTeamUpdated       = "team updated"
+	EvtTeamDeleted       = "team deleted"
+	EvtUserCreated       = "user created"
+	EvtUserUpdated       = "user updated"
+	EvtUserDeleted       = "user deleted"
+	EvtMembershipAdded   = "membership added"
+	EvtMembershipUpdated = "membership updated"
+	EvtMembershipDeleted = "membership deleted"
+)
+
 var logger = xlog.NewPackageLogger("github.com/go-phorce/dolly-test/service", "teams")
 
 // Service defines the Data service
@@ -54,10 +73,30 @@ func (s *Service) IsReady() bool {
 func (s *Service) Close() {
 }
 
+// audit records the event of the request
+func (s *Service) audit(r *http.Request, eventType, format string, args ...interface{}) {
+	audit.ForRequest(s.server, r, ServiceName, eventType, fmt.Sprintf(format, args...))
+}
+
 // Register adds the service status endpoints to the overall URL router
 func (s *Service) Register(r rest.Router) {
+	r = metrics.NewRouter(ServiceName, r)
+
 	r.GET(v1.URIForTeams, listTeamsHandler(s))
+	r.GET(v1.URIForTeamsMemberships, teamsMembershipHandler(s))
 	r.GET(v1.URIForUsers, listUsersHandler(s))
+
+	r.POST(v1.URIForTeam, createTeamHandler(s))
+	r.PUT(v1.URIForTeamByID, updateTeamHandler(s))
+	r.DELETE(v1.URIForTeamByID, deleteTeamHandler(s))
+
+	r.POST(v1.URIForUser, createUserHandler(s))
+	r.PUT(v1.URIForUserByID, updateUserHandler(s))
+	r.DELETE(v1.URIForUserByID, deleteUserHandler(s))
+
+	r.POST(v1.URIForMembership, addMembershipHandler(s))
+	r.PUT(v1.URIForMembershipByID, updateMembershipHandler(s))
+	r.DELETE(v1.URIForMembershipByID, deleteMembershipHandler(s))
 }
 
 func listTeamsHandler(s *Service) rest.Handle {
@@ -65,9 +104,22 @@ func listTeamsHandler(s *Service) rest.Handle {
 		ctx := identity.ForRequest(r)
 		_ = ctx.Identity()
 
-		res, err := s.db.ListTeams(r.Context())
+		params := r.URL.Query()
+		errs := v1.NewValidationError()
+
+		req := &v1.ListTeamsRequest{
+			Limit:  intParam(params, "limit", errs),
+			Cursor: params.Get("cursor"),
+			Sort:   params.Get("sort"),
+		}
+		if err := validateRequest(req, errs); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		res, err := s.db.ListTeams(r.Context(), req)
 		if err != nil {
-			marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to list team").WithCause(err))
+			marshal.WriteJSON(w, r, datahubError(err, "failed to list team"))
 			return
 		}
 
@@ -81,25 +133,31 @@ func listUsersHandler(s *Service) rest.Handle {
 		_ = ctx.Identity()
 
 		params := r.URL.Query()
-		minAge := params.Get("min_age")
-		maxAge := params.Get("max_age")
+		errs := v1.NewValidationError()
 
 		req := &v1.FindUserRequest{
-			Name: params.Get("name"),
-		}
-
-		if minAge != "" {
-			i, _ := strconv.Atoi(minAge)
-			req.MinAge = i
+			Name:            params.Get("name"),
+			MinAge:          intParam(params, "min_age", errs),
+			MaxAge:          intParam(params, "max_age", errs),
+			NamePrefix:      params.Get("name_prefix"),
+			NameContains:    params.Get("name_contains"),
+			EmailPrefix:     params.Get("email_prefix"),
+			EmailContains:   params.Get("email_contains"),
+			TeamID:          params.Get("team_id"),
+			LastLoginAfter:  timeParam(params, "last_login_after", errs),
+			LastLoginBefore: timeParam(params, "last_login_before", errs),
+			Limit:           intParam(params, "limit", errs),
+			Cursor:          params.Get("cursor"),
+			Sort:            params.Get("sort"),
 		}
-		if maxAge != "" {
-			i, _ := strconv.Atoi(maxAge)
-			req.MaxAge = i
+		if err := validateRequest(req, errs); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
 		}
 
 		res, err := s.db.FindUser(r.Context(), req)
 		if err != nil {
-			marshal.WriteJSON(w, r, httperror.WithUnexpected("failed to list team").WithCause(err))
+			marshal.WriteJSON(w, r, datahubError(err, "failed to find users"))
 			return
 		}
 
@@ -111,23 +169,285 @@ func teamsMembershipHandler(s *Service) rest.Handle {
 	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
 		ctx := identity.ForRequest(r)
 		idn := ctx.Identity()
-		userID := idn.UserID()
 
-		if userID == "" {
-			marshal.WriteJSON(w, r, httperror.WithForbidden("invalid user ID"))
+		user := callerUser(idn)
+		if user == "" {
+			marshal.WriteJSON(w, r, httperror.WithForbidden("unable to determine the caller"))
+			return
+		}
+
+		list, err := s.db.GetUserMemberships(r.Context(), user)
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to get memberships"))
+			return
+		}
+
+		res := &v1.GetTeamMembershipsResponse{
+			Memberships: list,
+		}
+
+		marshal.WritePlainJSON(w, http.StatusOK, res, marshal.PrettyPrint)
+	}
+}
+
+// callerUser returns user ID or email of the caller:
+// the email from JWT user info if present, otherwise
+// the user ID or the name of the cert or API-key identity
+func callerUser(idn identity.Identity) string {
+	if idn.Role() == identity.GuestRoleName {
+		return ""
+	}
+	if info, ok := idn.UserInfo().(*v1.UserInfo); ok && info != nil && info.Email != "" {
+		return info.Email
+	}
+	if userID := idn.UserID(); userID != "" {
+		return userID
+	}
+	return idn.Name()
+}
+
+func createTeamHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		req := new(v1.CreateTeamRequest)
+		if err := marshal.DecodeBody(w, r, req); err != nil {
 			return
 		}
 
-		_, ok := idn.UserInfo().(*v1.UserInfo)
-		if !ok {
-			marshal.WriteJSON(w, r, httperror.WithForbidden("failed to extract User Info from the token"))
+		if err := validateRequest(req, v1.NewValidationError()); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		team, err := s.db.CreateTeam(r.Context(), req)
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to create team"))
+			return
+		}
+
+		s.audit(r, EvtTeamCreated, "id=%s, name=%q", team.ID, team.Name)
+
+		marshal.WritePlainJSON(w, http.StatusCreated, &v1.TeamResponse{Team: team}, marshal.PrettyPrint)
+	}
+}
+
+func updateTeamHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		req := new(v1.UpdateTeamRequest)
+		if err := marshal.DecodeBody(w, r, req); err != nil {
+			return
+		}
+		req.ID = p.ByName("team_id")
+
+		if err := validateRequest(req, v1.NewValidationError()); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		team, err := s.db.UpdateTeam(r.Context(), req)
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to update team"))
+			return
+		}
+
+		s.audit(r, EvtTeamUpdated, "id=%s, name=%q", team.ID, team.Name)
+
+		marshal.WritePlainJSON(w, http.StatusOK, &v1.TeamResponse{Team: team}, marshal.PrettyPrint)
+	}
+}
+
+func deleteTeamHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		err := s.db.DeleteTeam(r.Context(), p.ByName("team_id"))
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to delete team"))
+			return
+		}
+
+		s.audit(r, EvtTeamDeleted, "id=%s", p.ByName("team_id"))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func createUserHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		req := new(v1.CreateUserRequest)
+		if err := marshal.DecodeBody(w, r, req); err != nil {
+			return
+		}
+
+		if err := validateRequest(req, v1.NewValidationError()); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		user, err := s.db.CreateUser(r.Context(), req)
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to create user"))
 			return
 		}
 
-		res := &v1.GetTeamMembershipsResponse{}
+		s.audit(r, EvtUserCreated, "id=%s, email=%q", user.ID, user.Email)
 
-		// TODO: response
+		marshal.WritePlainJSON(w, http.StatusCreated, &v1.UserResponse{User: user}, marshal.PrettyPrint)
+	}
+}
+
+func updateUserHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		req := new(v1.UpdateUserRequest)
+		if err := marshal.DecodeBody(w, r, req); err != nil {
+			return
+		}
+		req.ID = p.ByName("user_id")
+
+		if err := validateRequest(req, v1.NewValidationError()); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		user, err := s.db.UpdateUser(r.Context(), req)
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to update user"))
+			return
+		}
+
+		s.audit(r, EvtUserUpdated, "id=%s, email=%q", user.ID, user.Email)
+
+		marshal.WritePlainJSON(w, http.StatusOK, &v1.UserResponse{User: user}, marshal.PrettyPrint)
+	}
+}
+
+func deleteUserHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		err := s.db.DeleteUser(r.Context(), p.ByName("user_id"))
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to delete user"))
+			return
+		}
+
+		s.audit(r, EvtUserDeleted, "id=%s", p.ByName("user_id"))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func addMembershipHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, _ rest.Params) {
+		req := new(v1.AddMembershipRequest)
+		if err := marshal.DecodeBody(w, r, req); err != nil {
+			return
+		}
+
+		if err := validateRequest(req, v1.NewValidationError()); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		m, err := s.db.AddMembership(r.Context(), req)
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to add membership"))
+			return
+		}
+
+		s.audit(r, EvtMembershipAdded, "id=%s, team_id=%s, user_id=%s, role=%q", m.ID, m.TeamID, m.UserID, m.Role)
+
+		marshal.WritePlainJSON(w, http.StatusCreated, &v1.TeamMembershipResponse{Membership: m}, marshal.PrettyPrint)
+	}
+}
+
+func updateMembershipHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		req := new(v1.UpdateMembershipRequest)
+		if err := marshal.DecodeBody(w, r, req); err != nil {
+			return
+		}
+		req.ID = p.ByName("membership_id")
+
+		if err := validateRequest(req, v1.NewValidationError()); err != nil {
+			marshal.WriteJSON(w, r, err)
+			return
+		}
+
+		m, err := s.db.UpdateMembership(r.Context(), req)
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to update membership"))
+			return
+		}
+
+		s.audit(r, EvtMembershipUpdated, "id=%s, team_id=%s, user_id=%s, role=%q", m.ID, m.TeamID, m.UserID, m.Role)
+
+		marshal.WritePlainJSON(w, http.StatusOK, &v1.TeamMembershipResponse{Membership: m}, marshal.PrettyPrint)
+	}
+}
+
+func deleteMembershipHandler(s *Service) rest.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p rest.Params) {
+		err := s.db.DeleteMembership(r.Context(), p.ByName("membership_id"))
+		if err != nil {
+			marshal.WriteJSON(w, r, datahubError(err, "failed to delete membership"))
+			return
+		}
+
+		s.audit(r, EvtMembershipDeleted, "id=%s", p.ByName("membership_id"))
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// intParam returns integer value of the query parameter,
+// or adds the parameter to errs if the value is not a number
+func intParam(params url.Values, name string, errs *httperror.ManyError) int {
+	val := params.Get(name)
+	if val == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		errs.Add(name, httperror.WithInvalidParam("%s must be a number: %q", name, val))
+	}
+	return i
+}
+
+// timeParam returns RFC3339 time value of the query parameter,
+// or adds the parameter to errs if the value is not a valid time
+func timeParam(params url.Values, name string, errs *httperror.ManyError) *time.Time {
+	val := params.Get(name)
+	if val == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		errs.Add(name, httperror.WithInvalidParam("%s must be RFC3339 time: %q", name, val))
+		return nil
+	}
+	return &t
+}
+
+// validateRequest adds invalid fields of the request to errs,
+// and returns errs if any field is invalid
+func validateRequest(req v1.Validator, errs *httperror.ManyError) error {
+	if verr, ok := req.Validate().(*httperror.ManyError); ok {
+		for field, err := range verr.Errors {
+			if _, exists := errs.Errors[field]; !exists {
+				errs.Errors[field] = err
+			}
+		}
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
 
-		marshal.WriteJSON(w, r, res)
+// datahubError maps datahub errors to HTTP errors
+func datahubError(err error, msg string) *httperror.Error {
+	switch {
+	case errors.IsNotFound(err):
+		return httperror.WithNotFound("%s: %s", msg, err.Error())
+	case errors.IsAlreadyExists(err), errors.IsNotValid(err):
+		return httperror.WithInvalidRequest("%s: %s", msg, err.Error())
+	default:
+		return httperror.WithUnexpected("%s", msg).WithCause(err)
 	}
 }